@@ -0,0 +1,415 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// bearerPrefix is the prefix expected on the Authorization header value sent
+// by consumers presenting an access token.
+const bearerPrefix = "Bearer "
+
+// maxInputBodySize is the maximum size in bytes of a request body from which
+// the AccessHandler decodes inputs.
+const maxInputBodySize = 1024 * 1024
+
+// contextKey is an unexported type used for keys of values we store within a
+// request context, so that they can't collide with keys set by other packages.
+type contextKey int
+
+const (
+	// subscriberKey is the key under which we store the Subscriber extracted
+	// from a validated token.
+	subscriberKey contextKey = iota
+)
+
+// Subscriber contains the information we extract from a validated access token
+// presented by a consumer. It identifies the consumer subscription, the
+// offering the consumer has subscribed to, the provider whose secret the token
+// was signed with, and the ID (jti) of the token if it has one.
+type Subscriber struct {
+	ID         string
+	OfferingID string
+	ProviderID string
+	TokenID    string
+}
+
+// SubscriberFromContext returns the Subscriber stored in the given context by
+// the RequireToken middleware. The boolean return value reports whether a
+// Subscriber was present.
+func SubscriberFromContext(ctx context.Context) (Subscriber, bool) {
+	s, ok := ctx.Value(subscriberKey).(Subscriber)
+	return s, ok
+}
+
+// RequireToken is an http middleware that validates the access token presented
+// by a consumer in the Authorization header of the request. If the token is
+// valid the wrapped handler is invoked with a request context containing the
+// Subscriber for the token (see SubscriberFromContext), otherwise we respond
+// with a 401 Unauthorized error, or a 403 Forbidden error if the token has been
+// revoked (see WithRevocationChecker). If a Tracer has been configured, a
+// bigiot.access span is started for the request, continuing any trace sent by
+// the consumer, and carrying the subscriber and offering IDs as attributes.
+//
+// Example:
+//		http.Handle("/parking", provider.RequireToken(parkingHandler))
+func (p *Provider) RequireToken(next http.Handler) http.Handler {
+	return requireToken(p.tracer, p.validateSubscriber, next)
+}
+
+// validateSubscriber validates a token presented to the Provider, returning
+// the Subscriber it identifies, or ErrRevoked if the token has been revoked.
+func (p *Provider) validateSubscriber(ctx context.Context, tokenStr string) (Subscriber, error) {
+	cl, err := p.parseToken(tokenStr)
+	if err != nil {
+		return Subscriber{}, err
+	}
+
+	subscriber := Subscriber{
+		ID:         cl.SubscriberID,
+		OfferingID: cl.SubscribableID,
+		ProviderID: p.id,
+		TokenID:    cl.ID,
+	}
+
+	err = checkRevocation(ctx, p.revocation, subscriber)
+	if err != nil {
+		return Subscriber{}, err
+	}
+
+	return subscriber, nil
+}
+
+// requireToken is the implementation shared by the RequireToken middlewares of
+// Provider and TokenValidator, which differ only in how tokens are validated.
+func requireToken(tracer Tracer, validate func(ctx context.Context, tokenStr string) (Subscriber, error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracer.Extract(r.Context(), r.Header)
+
+		ctx, span := tracer.Start(ctx, "bigiot.access")
+		defer span.End()
+
+		tokenStr, err := extractToken(r)
+		if err != nil {
+			span.RecordError(err)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		subscriber, err := validate(ctx, tokenStr)
+		if err != nil {
+			span.RecordError(err)
+
+			if errors.Cause(err) == ErrRevoked {
+				writeError(w, http.StatusForbidden, err.Error())
+				return
+			}
+
+			writeError(w, http.StatusUnauthorized, "invalid access token")
+			return
+		}
+
+		span.SetAttribute(AttributeSubscriberID, subscriber.ID)
+		span.SetAttribute(AttributeOfferingID, subscriber.OfferingID)
+		span.SetAttribute(AttributeProviderID, subscriber.ProviderID)
+
+		ctx = context.WithValue(ctx, subscriberKey, subscriber)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Record is a single output record returned to a consumer. Records are keyed
+// by the names of the Outputs declared in the OfferingDescription.
+type Record map[string]interface{}
+
+// AccessRequest is the type passed to an AccessFunc for each validated request
+// made by a consumer. It identifies the consumer and the offering being
+// accessed, and contains the input parameters sent by the consumer, keyed by
+// the names of the Inputs declared in the OfferingDescription, and converted to
+// the Go type matching the datatype of each input.
+type AccessRequest struct {
+	OfferingID   string
+	SubscriberID string
+	Inputs       map[string]interface{}
+}
+
+// AccessResponse is the type returned by an AccessFunc. It contains the
+// records to be encoded and returned to the consumer. Records may be a slice
+// of Record values, or any other value accepted by EncodeRecords such as a
+// slice of structs.
+type AccessResponse struct {
+	Records interface{}
+}
+
+// BadGatewayError is a typed error returned by an AccessFunc when an upstream
+// service it depends on fails or responds unacceptably. The AccessHandler
+// responds with a 502 Bad Gateway rather than a 500 Internal Server Error. The
+// Reason is not sent to the consumer.
+type BadGatewayError struct {
+	Reason string
+}
+
+// Error is our implementation of the error interface.
+func (e *BadGatewayError) Error() string {
+	return e.Reason
+}
+
+// AccessFunc is the signature of the function a provider supplies in order to
+// serve requests for an offering. It is called with the request context and
+// the decoded AccessRequest, and should return the records to send back to the
+// consumer, or an error. InputErrors are returned to the consumer as a 400 Bad
+// Request and a BadGatewayError as a 502 Bad Gateway, while any other error
+// results in a 500 Internal Server Error.
+type AccessFunc func(ctx context.Context, req AccessRequest) (AccessResponse, error)
+
+// AccessHandler returns an http.Handler that implements the BIG IoT lib access
+// protocol for an offering registered on the marketplace, allowing offerings
+// registered with an AccessInterfaceType of BIGIoTLib to be accessed by
+// consumers using any of the BIG IoT libraries. The handler validates the
+// token presented by the consumer, checks it was issued for the given offering
+// ID, decodes the input parameters declared in the description's Inputs
+// (responding with a 400 Bad Request if any are missing or malformed), calls
+// the supplied AccessFunc, and then encodes the returned records according to
+// the description's Outputs (see EncodeRecords). Consumers sending an Accept
+// header of application/ld+json receive the records as a JSON-LD document
+// annotated with the RdfURI of each output.
+//
+// Example:
+//		offering, _ := provider.RegisterOffering(ctx, description)
+//
+//		http.Handle("/parking", provider.AccessHandler(offering.ID, description, fn))
+func (p *Provider) AccessHandler(offeringID string, description *OfferingDescription, fn AccessFunc, options ...AccessOption) http.Handler {
+	h := &accessHandler{
+		offeringID:  offeringID,
+		description: description,
+		fn:          fn,
+	}
+
+	for _, opt := range options {
+		opt(h)
+	}
+
+	return p.RequireToken(h)
+}
+
+// AccessOption is a functional configuration type used to configure optional
+// behaviour of the handler returned by AccessHandler.
+type AccessOption func(*accessHandler)
+
+// WithAccounting is an AccessOption that records every successful access in
+// the given AccountingStore, along with the number of bytes returned to the
+// consumer. This is required for offerings with a PricingModel of PerAccess
+// or PerByte.
+//
+// Example:
+//		store := bigiot.NewMemoryAccountingStore()
+//
+//		handler := provider.AccessHandler(offering.ID, description, fn, bigiot.WithAccounting(store))
+func WithAccounting(store AccountingStore) AccessOption {
+	return func(h *accessHandler) {
+		h.accounting = store
+	}
+}
+
+// accessHandler is our unexported http.Handler implementation that does the
+// work of serving an offering once the consumer's token has been validated.
+type accessHandler struct {
+	offeringID  string
+	description *OfferingDescription
+	fn          AccessFunc
+	accounting  AccountingStore
+
+	validateLocation bool
+}
+
+// ServeHTTP is our implementation of http.Handler.
+func (h *accessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.accounting == nil {
+		h.serve(w, r)
+		return
+	}
+
+	cw := &countingWriter{ResponseWriter: w, status: http.StatusOK}
+	h.serve(cw, r)
+
+	if cw.status < http.StatusMultipleChoices {
+		subscriber, _ := SubscriberFromContext(r.Context())
+
+		// the response has already been sent, so there is nothing useful we can
+		// do with an error here
+		_ = h.accounting.Record(r.Context(), subscriber.ID, h.offeringID, cw.bytes)
+	}
+}
+
+// serve does the work of handling an access request.
+func (h *accessHandler) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	subscriber, ok := SubscriberFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing access token")
+		return
+	}
+
+	if subscriber.OfferingID != h.offeringID {
+		writeError(w, http.StatusForbidden, "access token not valid for this offering")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxInputBodySize)
+
+	inputs, err := DecodeInputs(r, h.description.Inputs)
+	if err != nil {
+		writeInputErrors(w, err)
+		return
+	}
+
+	if h.validateLocation && !h.checkLocation(w, inputs) {
+		return
+	}
+
+	resp, err := h.fn(r.Context(), AccessRequest{
+		OfferingID:   subscriber.OfferingID,
+		SubscriberID: subscriber.ID,
+		Inputs:       inputs,
+	})
+	if err != nil {
+		if _, ok := errors.Cause(err).(InputErrors); ok {
+			writeInputErrors(w, err)
+			return
+		}
+
+		if _, ok := errors.Cause(err).(*BadGatewayError); ok {
+			writeError(w, http.StatusBadGateway, "error requesting upstream service")
+			return
+		}
+
+		writeError(w, http.StatusInternalServerError, "error handling access request")
+		return
+	}
+
+	records, err := EncodeRecords(h.description.Outputs, resp.Records)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error encoding records")
+		return
+	}
+
+	if acceptsJSONLD(r) {
+		w.Header().Set(contentTypeHeader, applicationJSONLD)
+		writeJSON(w, http.StatusOK, JSONLD(h.description.Outputs, records))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+// extractToken pulls the bearer token out of the Authorization header of the
+// request.
+func extractToken(r *http.Request) (string, error) {
+	header := r.Header.Get(authorizationHeader)
+	if header == "" {
+		return "", errors.New("missing access token")
+	}
+
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return "", errors.New("invalid authorization header")
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix)), nil
+}
+
+// acceptsJSONLD returns true if the consumer has asked for a JSON-LD response
+// via the Accept header of their request.
+func acceptsJSONLD(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get(acceptHeader), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == applicationJSONLD {
+			return true
+		}
+	}
+
+	return false
+}
+
+// countingWriter is an http.ResponseWriter that records the status code and
+// number of bytes written to the response.
+type countingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code before passing it on.
+func (c *countingWriter) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written before passing them on.
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.bytes += int64(n)
+	return n, err
+}
+
+// writeJSON writes the given value to the response as JSON with the given
+// status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	if w.Header().Get(contentTypeHeader) == "" {
+		w.Header().Set(contentTypeHeader, applicationJSON)
+	}
+
+	w.WriteHeader(status)
+
+	// by this point we have already written our status, so the best we can do
+	// with an error is to drop it
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response to the consumer, using the same
+// structure the marketplace uses for returning errors.
+func writeError(w http.ResponseWriter, status int, messages ...string) {
+	errorResp := ErrorResponse{
+		Errors: make([]Error, len(messages)),
+	}
+
+	for i, message := range messages {
+		errorResp.Errors[i] = Error{Message: message}
+	}
+
+	writeJSON(w, status, errorResp)
+}
+
+// writeInputErrors writes a 400 Bad Request response to the consumer, listing
+// each of the problems with their inputs if err is an InputErrors value.
+func writeInputErrors(w http.ResponseWriter, err error) {
+	if inputErrs, ok := errors.Cause(err).(InputErrors); ok {
+		writeError(w, http.StatusBadRequest, inputErrs.messages()...)
+		return
+	}
+
+	writeError(w, http.StatusBadRequest, err.Error())
+}
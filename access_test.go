@@ -0,0 +1,294 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/mocks"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const testSecret = "CF72ABfRTqy1FQS1zBaevw=="
+
+// signToken creates a signed token in the form the marketplace issues to
+// consumers, valid for a minute either side of now.
+func signToken(t *testing.T, secret, offeringID, subscriberID string, now time.Time) string {
+	t.Helper()
+
+	key, err := base64.StdEncoding.DecodeString(secret)
+	assert.Nil(t, err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: key}, nil)
+	assert.Nil(t, err)
+
+	cl := struct {
+		jwt.Claims
+		SubscribableID string `json:"subscribableId"`
+		SubscriberID   string `json:"subscriberId"`
+	}{
+		Claims: jwt.Claims{
+			Subject:   subscriberID + "==" + offeringID,
+			NotBefore: jwt.NewNumericDate(now.Add(-1 * time.Minute)),
+			Expiry:    jwt.NewNumericDate(now.Add(1 * time.Minute)),
+		},
+		SubscribableID: offeringID,
+		SubscriberID:   subscriberID,
+	}
+
+	tokenStr, err := jwt.Signed(signer).Claims(cl).CompactSerialize()
+	assert.Nil(t, err)
+
+	return tokenStr
+}
+
+func TestRequireToken(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+
+	p, err := bigiot.NewProvider("id", testSecret, bigiot.WithClock(mocks.Clock{T: now}))
+	assert.Nil(t, err)
+
+	handler := p.RequireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subscriber, ok := bigiot.SubscriberFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "Consumer-Query", subscriber.ID)
+		assert.Equal(t, "Provider-Offering", subscriber.OfferingID)
+	}))
+
+	testcases := []struct {
+		label    string
+		header   string
+		expected int
+	}{
+		{"valid", "Bearer " + signToken(t, testSecret, "Provider-Offering", "Consumer-Query", now), http.StatusOK},
+		{"missing", "", http.StatusUnauthorized},
+		{"not bearer", "Basic abc123", http.StatusUnauthorized},
+		{"wrong secret", "Bearer " + signToken(t, "CF72ABfRTqy1FOS1zBaevw==", "Provider-Offering", "Consumer-Query", now), http.StatusUnauthorized},
+		{"expired", "Bearer " + signToken(t, testSecret, "Provider-Offering", "Consumer-Query", now.Add(-1*time.Hour)), http.StatusUnauthorized},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/offering", nil)
+			if testcase.header != "" {
+				req.Header.Set("Authorization", testcase.header)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, testcase.expected, rec.Code)
+		})
+	}
+}
+
+func TestAccessHandler(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+
+	p, err := bigiot.NewProvider("id", testSecret, bigiot.WithClock(mocks.Clock{T: now}))
+	assert.Nil(t, err)
+
+	description := &bigiot.OfferingDescription{
+		LocalID: "Offering",
+		Inputs: []bigiot.DataField{
+			{Name: "longitude", RdfURI: "schema:longitude"},
+			{Name: "latitude", RdfURI: "schema:latitude"},
+			{Name: "fail", Datatype: bigiot.XSDBoolean},
+			{Name: "upstream", Datatype: bigiot.XSDBoolean},
+		},
+		Outputs: []bigiot.DataField{
+			{Name: "value", RdfURI: "schema:random"},
+		},
+	}
+
+	fn := func(ctx context.Context, req bigiot.AccessRequest) (bigiot.AccessResponse, error) {
+		if req.Inputs["fail"] == true {
+			return bigiot.AccessResponse{}, errors.New("boom")
+		}
+
+		if req.Inputs["upstream"] == true {
+			return bigiot.AccessResponse{}, &bigiot.BadGatewayError{Reason: "upstream timed out"}
+		}
+
+		if req.Inputs["latitude"] == 0.0 {
+			return bigiot.AccessResponse{}, bigiot.InputErrors{{Name: "latitude", Reason: "outside coverage"}}
+		}
+
+		if req.Inputs["latitude"] == -1.0 {
+			return bigiot.AccessResponse{
+				Records: []bigiot.Record{
+					{"other": 1},
+				},
+			}, nil
+		}
+
+		assert.Equal(t, "Provider-Offering", req.OfferingID)
+		assert.Equal(t, "Consumer-Query", req.SubscriberID)
+
+		return bigiot.AccessResponse{
+			Records: []bigiot.Record{
+				{"value": req.Inputs["longitude"], "internal": "secret"},
+			},
+		}, nil
+	}
+
+	handler := p.AccessHandler("Provider-Offering", description, fn)
+
+	testcases := []struct {
+		label          string
+		method         string
+		target         string
+		contentType    string
+		accept         string
+		body           string
+		offeringID     string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			label:          "get with query inputs",
+			method:         http.MethodGet,
+			target:         "/offering?longitude=2.33&latitude=54.5&ignored=true",
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":2.33}]`,
+		},
+		{
+			label:          "get as json-ld",
+			method:         http.MethodGet,
+			target:         "/offering?longitude=2.33&latitude=54.5",
+			accept:         "text/html, application/ld+json;q=0.9",
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"@context":{"schema":"http://schema.org/","value":"schema:random"},"@graph":[{"value":2.33}]}`,
+		},
+		{
+			label:          "missing declared output",
+			method:         http.MethodGet,
+			target:         "/offering?longitude=2.33&latitude=-1",
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"errors":[{"message":"error encoding records"}]}`,
+		},
+		{
+			label:          "post with json inputs",
+			method:         http.MethodPost,
+			target:         "/offering",
+			contentType:    "application/json; charset=utf-8",
+			body:           `{"longitude": 2.33, "latitude": 54.5}`,
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":2.33}]`,
+		},
+		{
+			label:          "post with invalid json",
+			method:         http.MethodPost,
+			target:         "/offering",
+			contentType:    "application/json",
+			body:           `{"longitude": `,
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input body: malformed JSON body"}]}`,
+		},
+		{
+			label:          "post with oversized body",
+			method:         http.MethodPost,
+			target:         "/offering",
+			contentType:    "application/json",
+			body:           `{"longitude": 2.33, "latitude": 54.5, "padding": "` + strings.Repeat("x", 2*1024*1024) + `"}`,
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input body: malformed JSON body"}]}`,
+		},
+		{
+			label:          "malformed inputs",
+			method:         http.MethodGet,
+			target:         "/offering?longitude=east&latitude=north",
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input longitude: expected a number"},{"message":"invalid input latitude: expected a number"}]}`,
+		},
+		{
+			label:          "input error from handler",
+			method:         http.MethodGet,
+			target:         "/offering?longitude=2.33&latitude=0",
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input latitude: outside coverage"}]}`,
+		},
+		{
+			label:          "token for another offering",
+			method:         http.MethodGet,
+			target:         "/offering",
+			offeringID:     "Provider-OtherOffering",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"errors":[{"message":"access token not valid for this offering"}]}`,
+		},
+		{
+			label:          "unsupported method",
+			method:         http.MethodDelete,
+			target:         "/offering",
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			label:          "handler error",
+			method:         http.MethodGet,
+			target:         "/offering?fail=true",
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"errors":[{"message":"error handling access request"}]}`,
+		},
+		{
+			label:          "upstream error from handler",
+			method:         http.MethodGet,
+			target:         "/offering?upstream=true",
+			offeringID:     "Provider-Offering",
+			expectedStatus: http.StatusBadGateway,
+			expectedBody:   `{"errors":[{"message":"error requesting upstream service"}]}`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			req := httptest.NewRequest(testcase.method, testcase.target, strings.NewReader(testcase.body))
+			req.Header.Set("Authorization", "Bearer "+signToken(t, testSecret, testcase.offeringID, "Consumer-Query", now))
+			if testcase.contentType != "" {
+				req.Header.Set("Content-Type", testcase.contentType)
+			}
+			if testcase.accept != "" {
+				req.Header.Set("Accept", testcase.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, testcase.expectedStatus, rec.Code)
+			if testcase.expectedBody != "" {
+				assert.Equal(t, testcase.expectedBody, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Usage is the accumulated usage of an offering by a single subscriber. This is
+// what providers need to track in order to charge for offerings with a
+// PricingModel of PerAccess or PerByte.
+type Usage struct {
+	SubscriberID string `json:"subscriberId"`
+	OfferingID   string `json:"offeringId"`
+	Accesses     int64  `json:"accesses"`
+	Bytes        int64  `json:"bytes"`
+}
+
+// AccountingStore is the interface implemented by types able to record the
+// usage of offerings by subscribers. Usage is recorded by the AccessHandler
+// when configured via the WithAccounting option, and read and deducted when
+// reporting usage to the marketplace.
+type AccountingStore interface {
+	// Record adds a single access of the given number of response bytes to the
+	// usage of the offering by the subscriber.
+	Record(ctx context.Context, subscriberID, offeringID string, bytes int64) error
+
+	// Usage returns the currently accumulated usage for every subscriber and
+	// offering.
+	Usage(ctx context.Context) ([]Usage, error)
+
+	// Deduct subtracts the given usage from the accumulated totals. This is
+	// called once usage has been successfully reported, so that accesses
+	// recorded while reporting are not lost.
+	Deduct(ctx context.Context, usage []Usage) error
+}
+
+// usageKey is the key we use to index usage within our stores.
+type usageKey struct {
+	subscriberID string
+	offeringID   string
+}
+
+// MemoryAccountingStore is an AccountingStore that keeps usage in memory. Usage
+// is lost when the process exits, so this is only suitable where occasional
+// loss of unreported usage is acceptable.
+type MemoryAccountingStore struct {
+	mu    sync.Mutex
+	usage map[usageKey]*Usage
+}
+
+// NewMemoryAccountingStore returns a new, empty MemoryAccountingStore.
+func NewMemoryAccountingStore() *MemoryAccountingStore {
+	return &MemoryAccountingStore{
+		usage: make(map[usageKey]*Usage),
+	}
+}
+
+// Record is our implementation of AccountingStore.
+func (m *MemoryAccountingStore) Record(ctx context.Context, subscriberID, offeringID string, bytes int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record(subscriberID, offeringID, bytes)
+
+	return nil
+}
+
+// Usage is our implementation of AccountingStore. Usage is returned ordered by
+// offering and then subscriber.
+func (m *MemoryAccountingStore) Usage(ctx context.Context) ([]Usage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.snapshot(), nil
+}
+
+// Deduct is our implementation of AccountingStore.
+func (m *MemoryAccountingStore) Deduct(ctx context.Context, usage []Usage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deduct(usage)
+
+	return nil
+}
+
+// record adds an access, the caller must hold the lock.
+func (m *MemoryAccountingStore) record(subscriberID, offeringID string, bytes int64) {
+	key := usageKey{subscriberID: subscriberID, offeringID: offeringID}
+
+	u, ok := m.usage[key]
+	if !ok {
+		u = &Usage{SubscriberID: subscriberID, OfferingID: offeringID}
+		m.usage[key] = u
+	}
+
+	u.Accesses++
+	u.Bytes += bytes
+}
+
+// snapshot returns a copy of the current usage, the caller must hold the lock.
+func (m *MemoryAccountingStore) snapshot() []Usage {
+	usage := make([]Usage, 0, len(m.usage))
+	for _, u := range m.usage {
+		usage = append(usage, *u)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].OfferingID != usage[j].OfferingID {
+			return usage[i].OfferingID < usage[j].OfferingID
+		}
+		return usage[i].SubscriberID < usage[j].SubscriberID
+	})
+
+	return usage
+}
+
+// deduct subtracts usage, removing entries that drop to zero. The caller must
+// hold the lock.
+func (m *MemoryAccountingStore) deduct(usage []Usage) {
+	for _, d := range usage {
+		key := usageKey{subscriberID: d.SubscriberID, offeringID: d.OfferingID}
+
+		u, ok := m.usage[key]
+		if !ok {
+			continue
+		}
+
+		u.Accesses -= d.Accesses
+		u.Bytes -= d.Bytes
+
+		if u.Accesses <= 0 && u.Bytes <= 0 {
+			delete(m.usage, key)
+		}
+	}
+}
+
+// DefaultAccountingFlushInterval is the default interval at which a
+// FileAccountingStore writes recorded usage to its file.
+const DefaultAccountingFlushInterval = 5 * time.Second
+
+// FileAccountingStore is an AccountingStore that persists usage to a JSON file
+// so that unreported usage survives restarts. Recorded usage is buffered in
+// memory and written to the file periodically, so that accesses don't wait on
+// disk I/O, while deducted usage is written immediately so that reported usage
+// isn't reported again after a restart. Usage recorded since the last write is
+// lost if the process crashes, so Close should be called before exiting. The
+// file is replaced atomically, and synced to disk before being replaced.
+type FileAccountingStore struct {
+	path     string
+	interval time.Duration
+	mem      *MemoryAccountingStore
+
+	// dirty is protected by mem.mu, and is true if usage has changed since the
+	// file was last written
+	dirty bool
+
+	// saveMu serialises writes to the file, so an older snapshot of the usage
+	// never replaces a newer one
+	saveMu sync.Mutex
+
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// FileAccountingOption is a functional configuration type used to configure
+// optional behaviour of a FileAccountingStore.
+type FileAccountingOption func(*FileAccountingStore)
+
+// WithFlushInterval is a FileAccountingOption setting the interval at which
+// recorded usage is written to the file. The default is
+// DefaultAccountingFlushInterval.
+func WithFlushInterval(interval time.Duration) FileAccountingOption {
+	return func(f *FileAccountingStore) {
+		f.interval = interval
+	}
+}
+
+// NewFileAccountingStore returns a FileAccountingStore persisting usage to the
+// file at the given path. Any usage already stored in the file is loaded. The
+// store writes usage to the file in the background until Close is called.
+func NewFileAccountingStore(path string, options ...FileAccountingOption) (*FileAccountingStore, error) {
+	f := &FileAccountingStore{
+		path:     path,
+		interval: DefaultAccountingFlushInterval,
+		mem:      NewMemoryAccountingStore(),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	for _, opt := range options {
+		opt(f)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "error reading accounting file")
+	}
+
+	if err == nil {
+		var usage []Usage
+		err = json.Unmarshal(b, &usage)
+		if err != nil {
+			return nil, errors.Wrap(err, "error unmarshalling accounting file")
+		}
+
+		for _, u := range usage {
+			u := u
+			f.mem.usage[usageKey{subscriberID: u.SubscriberID, offeringID: u.OfferingID}] = &u
+		}
+	}
+
+	go f.run()
+
+	return f, nil
+}
+
+// Record is our implementation of AccountingStore. The usage is written to the
+// file by the next periodic flush.
+func (f *FileAccountingStore) Record(ctx context.Context, subscriberID, offeringID string, bytes int64) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+
+	f.mem.record(subscriberID, offeringID, bytes)
+	f.dirty = true
+
+	return nil
+}
+
+// Usage is our implementation of AccountingStore.
+func (f *FileAccountingStore) Usage(ctx context.Context) ([]Usage, error) {
+	return f.mem.Usage(ctx)
+}
+
+// Deduct is our implementation of AccountingStore. The remaining usage is
+// written to the file before returning.
+func (f *FileAccountingStore) Deduct(ctx context.Context, usage []Usage) error {
+	f.mem.mu.Lock()
+	f.mem.deduct(usage)
+	f.dirty = true
+	f.mem.mu.Unlock()
+
+	return f.Flush()
+}
+
+// Flush writes the current usage to the file if it has changed since it was
+// last written.
+func (f *FileAccountingStore) Flush() error {
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+
+	f.mem.mu.Lock()
+	if !f.dirty {
+		f.mem.mu.Unlock()
+		return nil
+	}
+
+	usage := f.mem.snapshot()
+	f.dirty = false
+	f.mem.mu.Unlock()
+
+	err := f.save(usage)
+	if err != nil {
+		// try again on the next flush
+		f.mem.mu.Lock()
+		f.dirty = true
+		f.mem.mu.Unlock()
+	}
+
+	return err
+}
+
+// Close stops the background writes and writes any unsaved usage to the file.
+func (f *FileAccountingStore) Close() error {
+	f.closeOnce.Do(func() {
+		close(f.done)
+		<-f.stopped
+	})
+
+	return f.Flush()
+}
+
+// run flushes usage to the file every interval until the store is closed.
+// Failed writes are retried on the next interval, and reported by Close.
+func (f *FileAccountingStore) run() {
+	defer close(f.stopped)
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+			_ = f.Flush()
+		}
+	}
+}
+
+// save writes the given usage to a temporary file which is synced to disk and
+// then renamed over the accounting file. The caller must hold saveMu.
+func (f *FileAccountingStore) save(usage []Usage) error {
+	b, err := json.Marshal(usage)
+	if err != nil {
+		return errors.Wrap(err, "error marshalling usage")
+	}
+
+	dir := filepath.Dir(f.path)
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(f.path))
+	if err != nil {
+		return errors.Wrap(err, "error creating temporary accounting file")
+	}
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "error writing accounting file")
+	}
+
+	err = os.Rename(tmp.Name(), f.path)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "error replacing accounting file")
+	}
+
+	syncDir(dir)
+
+	return nil
+}
+
+// syncDir syncs a directory so that a file renamed within it survives a crash.
+// Not every platform supports syncing directories, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// ReportUsage reads the usage accumulated in the store and reports it to the
+// marketplace. Once the marketplace has accepted the report, the reported
+// usage is deducted from the store.
+func (p *Provider) ReportUsage(ctx context.Context, store AccountingStore) error {
+	usage, err := store.Usage(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error reading usage")
+	}
+
+	if len(usage) == 0 {
+		return nil
+	}
+
+	_, err = p.query(ctx, &usageReport{usage: usage})
+	if err != nil {
+		return errors.Wrap(err, "error reporting usage")
+	}
+
+	err = store.Deduct(ctx, usage)
+	if err != nil {
+		return errors.Wrap(err, "error deducting reported usage")
+	}
+
+	return nil
+}
+
+// RunUsageReports calls ReportUsage every interval until the context is
+// cancelled, at which point it returns the context's error. If a report fails
+// the usage remains in the store to be included in the next report, and the
+// error is passed to errFn if it is not nil.
+func (p *Provider) RunUsageReports(ctx context.Context, store AccountingStore, interval time.Duration, errFn func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			err := p.ReportUsage(ctx, store)
+			if err != nil && errFn != nil {
+				errFn(err)
+			}
+		}
+	}
+}
+
+// usageReport is the unexported input type used to report usage to the
+// marketplace's accounting API.
+type usageReport struct {
+	usage []Usage
+}
+
+// operationName is our implementation of operation for usageReport.
+func (u *usageReport) operationName() string {
+	return OperationTrackAccesses
+}
+
+// serialize is our implementation of the serializable interface.
+func (u *usageReport) serialize(clock Clock) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(`mutation trackAccesses { trackAccesses ( input: { time: `)
+	buf.WriteString(ToEpochMs(clock.Now()))
+	buf.WriteString(`, accesses: [`)
+	for i, usage := range u.usage {
+		buf.WriteString(`{ subscriberId: "`)
+		buf.WriteString(usage.SubscriberID)
+		buf.WriteString(`", subscribableId: "`)
+		buf.WriteString(usage.OfferingID)
+		buf.WriteString(`", accesses: `)
+		buf.WriteString(strconv.FormatInt(usage.Accesses, 10))
+		buf.WriteString(`, bytes: `)
+		buf.WriteString(strconv.FormatInt(usage.Bytes, 10))
+		buf.WriteString(` }`)
+		if i < len(u.usage)-1 {
+			buf.WriteString(`, `)
+		}
+	}
+	buf.WriteString(`] } ) { time } }`)
+
+	return buf.String()
+}
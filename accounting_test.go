@@ -0,0 +1,231 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/mocks"
+	"github.com/thingful/simular"
+)
+
+func TestMemoryAccountingStore(t *testing.T) {
+	ctx := context.Background()
+	store := bigiot.NewMemoryAccountingStore()
+
+	assert.Nil(t, store.Record(ctx, "Consumer-B", "Provider-Offering", 10))
+	assert.Nil(t, store.Record(ctx, "Consumer-A", "Provider-Offering", 20))
+	assert.Nil(t, store.Record(ctx, "Consumer-A", "Provider-Offering", 5))
+
+	usage, err := store.Usage(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []bigiot.Usage{
+		{SubscriberID: "Consumer-A", OfferingID: "Provider-Offering", Accesses: 2, Bytes: 25},
+		{SubscriberID: "Consumer-B", OfferingID: "Provider-Offering", Accesses: 1, Bytes: 10},
+	}, usage)
+
+	// record another access after taking our snapshot, then deduct the snapshot
+	assert.Nil(t, store.Record(ctx, "Consumer-A", "Provider-Offering", 7))
+	assert.Nil(t, store.Deduct(ctx, usage))
+
+	usage, err = store.Usage(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []bigiot.Usage{
+		{SubscriberID: "Consumer-A", OfferingID: "Provider-Offering", Accesses: 1, Bytes: 7},
+	}, usage)
+}
+
+func TestFileAccountingStore(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "bigiot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "usage.json")
+
+	store, err := bigiot.NewFileAccountingStore(path, bigiot.WithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+
+	assert.Nil(t, store.Record(ctx, "Consumer-A", "Provider-Offering", 20))
+	assert.Nil(t, store.Record(ctx, "Consumer-A", "Provider-Offering", 5))
+
+	// recorded usage is buffered until the store is flushed
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, store.Close())
+
+	reloaded, err := bigiot.NewFileAccountingStore(path, bigiot.WithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+	defer reloaded.Close()
+
+	usage, err := reloaded.Usage(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []bigiot.Usage{
+		{SubscriberID: "Consumer-A", OfferingID: "Provider-Offering", Accesses: 2, Bytes: 25},
+	}, usage)
+
+	// deducted usage is written immediately
+	assert.Nil(t, reloaded.Deduct(ctx, usage))
+
+	deducted, err := bigiot.NewFileAccountingStore(path)
+	assert.Nil(t, err)
+	defer deducted.Close()
+
+	usage, err = deducted.Usage(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, usage, 0)
+
+	// usage is flushed periodically
+	periodic, err := bigiot.NewFileAccountingStore(path, bigiot.WithFlushInterval(10*time.Millisecond))
+	assert.Nil(t, err)
+	defer periodic.Close()
+
+	assert.Nil(t, periodic.Record(ctx, "Consumer-B", "Provider-Offering", 10))
+
+	for i := 0; i < 100; i++ {
+		b, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		if strings.Contains(string(b), "Consumer-B") {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "Consumer-B")
+
+	err = ioutil.WriteFile(path, []byte("not json"), 0600)
+	assert.Nil(t, err)
+
+	_, err = bigiot.NewFileAccountingStore(path)
+	assert.NotNil(t, err)
+}
+
+func TestAccessHandlerWithAccounting(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	store := bigiot.NewMemoryAccountingStore()
+
+	p, err := bigiot.NewProvider("id", testSecret, bigiot.WithClock(mocks.Clock{T: now}))
+	assert.Nil(t, err)
+
+	description := &bigiot.OfferingDescription{
+		Inputs: []bigiot.DataField{
+			{Name: "latitude", RdfURI: "schema:latitude"},
+		},
+		Outputs: []bigiot.DataField{
+			{Name: "value", RdfURI: "schema:random"},
+		},
+	}
+
+	fn := func(ctx context.Context, req bigiot.AccessRequest) (bigiot.AccessResponse, error) {
+		return bigiot.AccessResponse{
+			Records: []bigiot.Record{{"value": 1}},
+		}, nil
+	}
+
+	handler := p.AccessHandler("Provider-Offering", description, fn, bigiot.WithAccounting(store))
+
+	var expectedBytes int64
+
+	for _, target := range []string{"/?latitude=1", "/?latitude=north", "/?latitude=2"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+signToken(t, testSecret, "Provider-Offering", "Consumer-Query", now))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code == http.StatusOK {
+			expectedBytes += int64(rec.Body.Len())
+		}
+	}
+
+	usage, err := store.Usage(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []bigiot.Usage{
+		{SubscriberID: "Consumer-Query", OfferingID: "Provider-Offering", Accesses: 2, Bytes: expectedBytes},
+	}, usage)
+}
+
+func TestReportUsage(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	simular.Activate()
+	defer simular.DeactivateAndReset()
+
+	expectedBody := `{"query":"mutation trackAccesses { trackAccesses ( input: { time: 1514797440000, accesses: [{ subscriberId: \"Consumer-Query\", subscribableId: \"Provider-Offering\", accesses: 2, bytes: 40 }] } ) { time } }"}`
+
+	provider, err := bigiot.NewProvider("Provider", "secret", bigiot.WithClock(mocks.Clock{T: now}))
+	assert.Nil(t, err)
+
+	store := bigiot.NewMemoryAccountingStore()
+	assert.Nil(t, store.Record(ctx, "Consumer-Query", "Provider-Offering", 15))
+	assert.Nil(t, store.Record(ctx, "Consumer-Query", "Provider-Offering", 25))
+
+	t.Run("with error response", func(t *testing.T) {
+		simular.RegisterStubRequests(
+			simular.NewStubRequest(
+				http.MethodPost,
+				"https://market.big-iot.org/graphql",
+				simular.NewStringResponder(400, `{"data":null,"errors":[{"message":"bad request"}]}`),
+				simular.WithBody(bytes.NewBufferString(expectedBody)),
+			),
+		)
+
+		err = provider.ReportUsage(ctx, store)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error reporting usage: bad request", err.Error())
+
+		usage, err := store.Usage(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, usage, 1)
+	})
+
+	t.Run("with valid response", func(t *testing.T) {
+		simular.RegisterStubRequests(
+			simular.NewStubRequest(
+				http.MethodPost,
+				"https://market.big-iot.org/graphql",
+				simular.NewStringResponder(200, `{"data": {"trackAccesses": {"time": 1514797440000}}}`),
+				simular.WithBody(bytes.NewBufferString(expectedBody)),
+			),
+		)
+
+		err = provider.ReportUsage(ctx, store)
+		assert.Nil(t, err)
+
+		usage, err := store.Usage(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, usage, 0)
+
+		// nothing left to report, so no request should be made
+		err = provider.ReportUsage(ctx, store)
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,347 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"bytes"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxExponent is the largest exponent we accept when parsing amounts written in
+// exponent notation, to prevent absurd inputs allocating huge numbers.
+const maxExponent = 1000
+
+// Amount is an exact decimal number used to represent amounts of money. It is
+// stored as a canonical decimal string (no exponent, no trailing fractional
+// zeros), so amounts such as the tiny per byte prices used by some offerings
+// are never subject to floating point rounding. The zero value is an amount of
+// zero, and two Amounts representing the same number are always equal when
+// compared with ==.
+//
+// Amounts are immutable: arithmetic methods return a new Amount.
+type Amount struct {
+	value string
+}
+
+// NewAmount returns the Amount unscaled * 10^-scale, i.e. NewAmount(125, 2) is
+// 1.25 and NewAmount(1, 5) is 0.00001.
+func NewAmount(unscaled int64, scale int) Amount {
+	return newAmount(big.NewInt(unscaled), scale)
+}
+
+// ParseAmount parses a decimal string such as "0.00001", "-12.5" or "1e-5" into
+// an Amount exactly.
+func ParseAmount(s string) (Amount, error) {
+	unscaled, scale, err := parseDecimal(s)
+	if err != nil {
+		return Amount{}, err
+	}
+
+	return newAmount(unscaled, scale), nil
+}
+
+// MustParseAmount is like ParseAmount but panics if the string cannot be
+// parsed. It is intended for use with constant amounts.
+func MustParseAmount(s string) Amount {
+	a, err := ParseAmount(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return a
+}
+
+// AmountFromFloat returns the Amount for the shortest decimal representation of
+// the given float, so AmountFromFloat(0.001) is exactly 0.001. It is provided
+// for compatibility with code that previously used float64 amounts. NaN and
+// infinite values return an amount of zero.
+func AmountFromFloat(f float64) Amount {
+	a, err := ParseAmount(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Amount{}
+	}
+
+	return a
+}
+
+// String returns the amount as a plain decimal string, never using exponent
+// notation.
+func (a Amount) String() string {
+	if a.value == "" {
+		return "0"
+	}
+
+	return a.value
+}
+
+// StringFixed returns the amount as a plain decimal string with at least the
+// given number of fractional digits, padding with zeros where required. The
+// amount is never rounded, so more digits are returned if needed to represent
+// it exactly.
+func (a Amount) StringFixed(digits int) string {
+	s := a.String()
+
+	fraction := 0
+	if idx := strings.Index(s, "."); idx != -1 {
+		fraction = len(s) - idx - 1
+	} else if digits > 0 {
+		s += "."
+	}
+
+	if fraction < digits {
+		s += strings.Repeat("0", digits-fraction)
+	}
+
+	return s
+}
+
+// Float64 returns the nearest float64 to the amount. This is only intended for
+// display or interoperability purposes, as the conversion may lose precision.
+func (a Amount) Float64() float64 {
+	f, _ := strconv.ParseFloat(a.String(), 64)
+	return f
+}
+
+// IsZero returns true if the amount is zero.
+func (a Amount) IsZero() bool {
+	return a.value == ""
+}
+
+// Sign returns -1, 0 or 1 depending on whether the amount is negative, zero or
+// positive.
+func (a Amount) Sign() int {
+	switch {
+	case a.value == "":
+		return 0
+	case a.value[0] == '-':
+		return -1
+	default:
+		return 1
+	}
+}
+
+// Cmp compares the amount to b, returning -1 if a < b, 0 if a == b, and 1 if
+// a > b.
+func (a Amount) Cmp(b Amount) int {
+	x, y, _ := align(a, b)
+	return x.Cmp(y)
+}
+
+// Add returns the sum a + b.
+func (a Amount) Add(b Amount) Amount {
+	x, y, scale := align(a, b)
+	return newAmount(x.Add(x, y), scale)
+}
+
+// Sub returns the difference a - b.
+func (a Amount) Sub(b Amount) Amount {
+	x, y, scale := align(a, b)
+	return newAmount(x.Sub(x, y), scale)
+}
+
+// Mul returns the product a * b.
+func (a Amount) Mul(b Amount) Amount {
+	x, xScale := a.parts()
+	y, yScale := b.parts()
+	return newAmount(x.Mul(x, y), xScale+yScale)
+}
+
+// MulInt returns the product a * n, for example the price of n accesses or n
+// bytes.
+func (a Amount) MulInt(n int64) Amount {
+	x, scale := a.parts()
+	return newAmount(x.Mul(x, big.NewInt(n)), scale)
+}
+
+// Neg returns the negation of the amount.
+func (a Amount) Neg() Amount {
+	x, scale := a.parts()
+	return newAmount(x.Neg(x), scale)
+}
+
+// MarshalJSON is our implementation of json.Marshaler. Amounts are encoded as
+// JSON numbers with their exact decimal representation.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON is our implementation of json.Unmarshaler. We accept either a
+// JSON number or a string containing a decimal number, and in both cases
+// parse the literal text exactly rather than going via a float.
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s := string(bytes.TrimSpace(b))
+	if s == "null" {
+		return nil
+	}
+
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return errors.Wrap(err, "error unmarshalling amount")
+	}
+
+	*a = parsed
+
+	return nil
+}
+
+// MarshalText is our implementation of encoding.TextMarshaler.
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText is our implementation of encoding.TextUnmarshaler.
+func (a *Amount) UnmarshalText(b []byte) error {
+	parsed, err := ParseAmount(string(b))
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+
+	return nil
+}
+
+// parts returns the unscaled value and scale of the amount.
+func (a Amount) parts() (*big.Int, int) {
+	if a.value == "" {
+		return new(big.Int), 0
+	}
+
+	digits := a.value
+	scale := 0
+
+	if idx := strings.Index(digits, "."); idx != -1 {
+		scale = len(digits) - idx - 1
+		digits = digits[:idx] + digits[idx+1:]
+	}
+
+	// our canonical form is always valid, so this cannot fail
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+
+	return unscaled, scale
+}
+
+// align returns the unscaled values of a and b adjusted to a common scale.
+func align(a, b Amount) (*big.Int, *big.Int, int) {
+	x, xScale := a.parts()
+	y, yScale := b.parts()
+
+	switch {
+	case xScale < yScale:
+		x.Mul(x, pow10(yScale-xScale))
+		return x, y, yScale
+	case yScale < xScale:
+		y.Mul(y, pow10(xScale-yScale))
+		return x, y, xScale
+	}
+
+	return x, y, xScale
+}
+
+// pow10 returns 10^n as a big.Int.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// newAmount returns the canonical Amount for unscaled * 10^-scale.
+func newAmount(unscaled *big.Int, scale int) Amount {
+	if scale < 0 {
+		unscaled = new(big.Int).Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+
+	digits := new(big.Int).Abs(unscaled).String()
+
+	// strip trailing fractional zeros
+	for scale > 0 && len(digits) > 1 && digits[len(digits)-1] == '0' {
+		digits = digits[:len(digits)-1]
+		scale--
+	}
+
+	if digits == "0" {
+		return Amount{}
+	}
+
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+
+	if unscaled.Sign() < 0 {
+		digits = "-" + digits
+	}
+
+	return Amount{value: digits}
+}
+
+// parseDecimal parses a decimal string with optional sign, fraction and
+// exponent into its unscaled value and scale.
+func parseDecimal(s string) (*big.Int, int, error) {
+	invalid := errors.Errorf("invalid decimal amount %q", s)
+
+	str := s
+	exponent := 0
+
+	if idx := strings.IndexAny(str, "eE"); idx != -1 {
+		exp, err := strconv.Atoi(str[idx+1:])
+		if err != nil || exp > maxExponent || exp < -maxExponent {
+			return nil, 0, invalid
+		}
+		exponent = exp
+		str = str[:idx]
+	}
+
+	neg := false
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+
+	integer, fraction := str, ""
+	if idx := strings.Index(str, "."); idx != -1 {
+		integer, fraction = str[:idx], str[idx+1:]
+	}
+
+	if integer == "" && fraction == "" {
+		return nil, 0, invalid
+	}
+
+	for _, r := range integer + fraction {
+		if r < '0' || r > '9' {
+			return nil, 0, invalid
+		}
+	}
+
+	unscaled, ok := new(big.Int).SetString("0"+integer+fraction, 10)
+	if !ok {
+		return nil, 0, invalid
+	}
+
+	if neg {
+		unscaled.Neg(unscaled)
+	}
+
+	return unscaled, len(fraction) - exponent, nil
+}
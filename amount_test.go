@@ -0,0 +1,134 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+func TestParseAmount(t *testing.T) {
+	testcases := []struct {
+		input    string
+		expected string
+	}{
+		{"0", "0"},
+		{"-0.000", "0"},
+		{"12", "12"},
+		{"+12.50", "12.5"},
+		{"-0.001", "-0.001"},
+		{"0.00000001", "0.00000001"},
+		{".5", "0.5"},
+		{"5.", "5"},
+		{"1e-5", "0.00001"},
+		{"1.5E3", "1500"},
+		{"123456789012345678901234567890.123456789", "123456789012345678901234567890.123456789"},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.input, func(t *testing.T) {
+			got, err := bigiot.ParseAmount(testcase.input)
+			assert.Nil(t, err)
+			assert.Equal(t, testcase.expected, got.String())
+		})
+	}
+}
+
+func TestParseAmountInvalid(t *testing.T) {
+	testcases := []string{"", "-", ".", "abc", "1.2.3", "1e", "1e5000", "0x10", "1,5"}
+
+	for _, testcase := range testcases {
+		t.Run(testcase, func(t *testing.T) {
+			_, err := bigiot.ParseAmount(testcase)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestAmountZeroValue(t *testing.T) {
+	var a bigiot.Amount
+
+	assert.True(t, a.IsZero())
+	assert.Equal(t, "0", a.String())
+	assert.Equal(t, bigiot.MustParseAmount("0.00"), a)
+	assert.Equal(t, bigiot.NewAmount(0, 3), a)
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	a := bigiot.MustParseAmount("0.1")
+	b := bigiot.MustParseAmount("0.2")
+
+	// the classic float64 rounding failure
+	assert.Equal(t, bigiot.MustParseAmount("0.3"), a.Add(b))
+	assert.Equal(t, bigiot.MustParseAmount("-0.1"), a.Sub(b))
+	assert.Equal(t, bigiot.MustParseAmount("0.02"), a.Mul(b))
+	assert.Equal(t, bigiot.MustParseAmount("-0.1"), a.Neg())
+
+	perByte := bigiot.NewAmount(1, 6)
+	assert.Equal(t, "0.000001", perByte.String())
+	assert.Equal(t, "1000", perByte.MulInt(1000000000).String())
+
+	total := bigiot.Amount{}
+	for i := 0; i < 1000; i++ {
+		total = total.Add(bigiot.AmountFromFloat(0.001))
+	}
+	assert.Equal(t, bigiot.NewAmount(1, 0), total)
+
+	assert.Equal(t, -1, a.Cmp(b))
+	assert.Equal(t, 1, b.Cmp(a))
+	assert.Equal(t, 0, a.Cmp(bigiot.MustParseAmount("0.10")))
+
+	assert.Equal(t, 1, a.Sign())
+	assert.Equal(t, -1, a.Neg().Sign())
+	assert.Equal(t, 0, bigiot.Amount{}.Sign())
+}
+
+func TestAmountFormatting(t *testing.T) {
+	assert.Equal(t, "1.50", bigiot.MustParseAmount("1.5").StringFixed(2))
+	assert.Equal(t, "0.001", bigiot.MustParseAmount("0.001").StringFixed(2))
+	assert.Equal(t, "10.00", bigiot.NewAmount(10, 0).StringFixed(2))
+	assert.Equal(t, "10", bigiot.NewAmount(10, 0).StringFixed(0))
+	assert.Equal(t, "0.000001", bigiot.AmountFromFloat(1e-6).String())
+	assert.Equal(t, 0.001, bigiot.MustParseAmount("0.001").Float64())
+}
+
+func TestAmountJSON(t *testing.T) {
+	money := bigiot.Money{
+		Amount:   bigiot.MustParseAmount("0.0000001"),
+		Currency: bigiot.EUR,
+	}
+
+	b, err := json.Marshal(money)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Amount":0.0000001,"Currency":"EUR"}`, string(b))
+
+	var decoded struct {
+		Number bigiot.Amount
+		String bigiot.Amount
+		Null   bigiot.Amount
+	}
+
+	err = json.Unmarshal([]byte(`{"Number": 0.30000000000000001, "String": "12.34", "Null": null}`), &decoded)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.30000000000000001", decoded.Number.String())
+	assert.Equal(t, "12.34", decoded.String.String())
+	assert.True(t, decoded.Null.IsZero())
+
+	err = json.Unmarshal([]byte(`{"String": "twelve"}`), &decoded)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,319 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// accessTokenPath is the path of the marketplace endpoint which issues
+	// access tokens
+	accessTokenPath = "/accessToken"
+
+	// formURLEncoded is a const value we use as a value for the Content-Type
+	// header when sending form bodies
+	formURLEncoded = "application/x-www-form-urlencoded"
+)
+
+// Credentials are the ID and secret a client uses to authenticate with the
+// marketplace.
+type Credentials struct {
+	ID     string
+	Secret string
+}
+
+// AuthenticationError is the error returned by an Authenticator when the
+// marketplace rejects the credentials it was sent.
+type AuthenticationError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error is our implementation of the error interface, returning the message
+// sent by the marketplace.
+func (e *AuthenticationError) Error() string {
+	return e.Message
+}
+
+// Authenticator is the interface used to obtain an access token from the
+// marketplace, which the client then sends with every request made to the
+// graphql endpoint. Implementations are given the http.Client configured for
+// the Provider, the URL of the marketplace and the credentials of the client,
+// and should return an *AuthenticationError if the credentials are rejected.
+//
+// The default Authenticator is QueryAuthenticator, which is kept as the default
+// for compatibility with every version of the marketplace, although it sends
+// the secret in the URL where it may be recorded in proxy or access logs. A
+// warning is logged the first time it is used. FormAuthenticator and
+// HeaderAuthenticator avoid this, and should be preferred where the
+// marketplace supports them.
+type Authenticator interface {
+	// AccessToken requests an access token for the given credentials.
+	AccessToken(ctx context.Context, client *http.Client, marketplaceURL *url.URL, credentials Credentials) (string, error)
+}
+
+// WithAuthenticator allows a caller to change the way the client obtains an
+// access token from the marketplace.
+//
+// Example:
+// 		provider, _ := bigiot.NewProvider(
+//			providerID,
+//			providerSecret,
+//			bigiot.WithAuthenticator(bigiot.FormAuthenticator{}),
+// 		)
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(b *base) error {
+		b.authenticator = authenticator
+
+		return nil
+	}
+}
+
+// WithAccessToken allows a caller to supply an access token which has already
+// been issued by the marketplace, rather than obtaining one using the client
+// secret. The token is used immediately, so there is no need to call
+// Authenticate, and calling it will not contact the marketplace.
+//
+// Example:
+// 		provider, _ := bigiot.NewProvider(
+//			providerID,
+//			"",
+//			bigiot.WithAccessToken(accessToken),
+// 		)
+func WithAccessToken(token string) Option {
+	return func(b *base) error {
+		if token == "" {
+			return errors.New("access token must not be empty")
+		}
+
+		b.authenticator = staticAuthenticator(token)
+		b.setToken(token)
+
+		return nil
+	}
+}
+
+// QueryAuthenticator obtains an access token by making a GET request to the
+// /accessToken endpoint of the marketplace, with the client ID and secret sent
+// as query parameters. This is the default Authenticator for compatibility
+// with older marketplaces, but the secret may be recorded in proxy or access
+// logs, so FormAuthenticator or HeaderAuthenticator should be used instead
+// where possible.
+type QueryAuthenticator struct{}
+
+// AccessToken is our implementation of Authenticator.
+func (QueryAuthenticator) AccessToken(ctx context.Context, client *http.Client, marketplaceURL *url.URL, credentials Credentials) (string, error) {
+	params := &url.Values{
+		"clientId":     []string{credentials.ID},
+		"clientSecret": []string{credentials.Secret},
+	}
+
+	authURL := accessTokenURL(marketplaceURL)
+	authURL.RawQuery = params.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, authURL.String(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "Error creating authentication request")
+	}
+
+	return requestAccessToken(ctx, client, req)
+}
+
+// FormAuthenticator obtains an access token by making a POST request to the
+// /accessToken endpoint of the marketplace, with the client ID and secret sent
+// in a form encoded body.
+type FormAuthenticator struct{}
+
+// AccessToken is our implementation of Authenticator.
+func (FormAuthenticator) AccessToken(ctx context.Context, client *http.Client, marketplaceURL *url.URL, credentials Credentials) (string, error) {
+	params := url.Values{
+		"clientId":     []string{credentials.ID},
+		"clientSecret": []string{credentials.Secret},
+	}
+
+	authURL := accessTokenURL(marketplaceURL)
+
+	req, err := http.NewRequest(http.MethodPost, authURL.String(), strings.NewReader(params.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "Error creating authentication request")
+	}
+
+	req.Header.Set(contentTypeHeader, formURLEncoded)
+
+	return requestAccessToken(ctx, client, req)
+}
+
+// HeaderAuthenticator obtains an access token by making a GET request to the
+// /accessToken endpoint of the marketplace, with the client ID and secret sent
+// using HTTP basic authentication.
+type HeaderAuthenticator struct{}
+
+// AccessToken is our implementation of Authenticator.
+func (HeaderAuthenticator) AccessToken(ctx context.Context, client *http.Client, marketplaceURL *url.URL, credentials Credentials) (string, error) {
+	authURL := accessTokenURL(marketplaceURL)
+
+	req, err := http.NewRequest(http.MethodGet, authURL.String(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "Error creating authentication request")
+	}
+
+	req.SetBasicAuth(credentials.ID, credentials.Secret)
+
+	return requestAccessToken(ctx, client, req)
+}
+
+// ClientCredentialsAuthenticator obtains an access token from an OAuth2
+// authorization server using the client credentials grant (RFC 6749 section
+// 4.4), for marketplaces deployed behind one. The client ID and secret are sent
+// using HTTP basic authentication.
+type ClientCredentialsAuthenticator struct {
+	// TokenURL is the URL of the token endpoint. Relative URLs are resolved
+	// against the URL of the marketplace.
+	TokenURL string
+
+	// Scopes are the optional scopes to request.
+	Scopes []string
+}
+
+// AccessToken is our implementation of Authenticator.
+func (a ClientCredentialsAuthenticator) AccessToken(ctx context.Context, client *http.Client, marketplaceURL *url.URL, credentials Credentials) (string, error) {
+	if a.TokenURL == "" {
+		return "", errors.New("Missing token url")
+	}
+
+	tokenURL, err := url.Parse(a.TokenURL)
+	if err != nil {
+		return "", errors.Wrap(err, "Error parsing token url")
+	}
+
+	params := url.Values{
+		"grant_type": []string{"client_credentials"},
+	}
+
+	if len(a.Scopes) > 0 {
+		params.Set("scope", strings.Join(a.Scopes, " "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, marketplaceURL.ResolveReference(tokenURL).String(), strings.NewReader(params.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "Error creating authentication request")
+	}
+
+	req.SetBasicAuth(url.QueryEscape(credentials.ID), url.QueryEscape(credentials.Secret))
+	req.Header.Set(contentTypeHeader, formURLEncoded)
+	req.Header.Set(acceptHeader, applicationJSON)
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrap(err, "Error making authentication request")
+	}
+
+	body, err := readAndClose(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "Error reading authentication response")
+	}
+
+	tokenResp := struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+
+	// error responses should be JSON too, but fall back to the raw body if not
+	jsonErr := json.Unmarshal(body, &tokenResp)
+
+	if resp.StatusCode != http.StatusOK {
+		message := string(body)
+		if jsonErr == nil && tokenResp.ErrorDescription != "" {
+			message = tokenResp.ErrorDescription
+		} else if jsonErr == nil && tokenResp.Error != "" {
+			message = tokenResp.Error
+		}
+
+		return "", &AuthenticationError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if jsonErr != nil {
+		return "", errors.Wrap(jsonErr, "Error unmarshalling token response")
+	}
+
+	if tokenResp.AccessToken == "" {
+		return "", errors.New("Token response did not contain an access token")
+	}
+
+	return tokenResp.AccessToken, nil
+}
+
+// staticAuthenticator is the Authenticator installed by WithAccessToken, which
+// always returns the same pre-issued token.
+type staticAuthenticator string
+
+// AccessToken is our implementation of Authenticator.
+func (a staticAuthenticator) AccessToken(ctx context.Context, client *http.Client, marketplaceURL *url.URL, credentials Credentials) (string, error) {
+	return string(a), nil
+}
+
+// accessTokenURL returns the URL of the /accessToken endpoint of the
+// marketplace.
+func accessTokenURL(marketplaceURL *url.URL) url.URL {
+	// deference to make sure we clone the marketplace URL rather than modifying
+	// the pointed to value
+	authURL := *marketplaceURL
+	authURL.Path = accessTokenPath
+
+	return authURL
+}
+
+// requestAccessToken sends a request to the /accessToken endpoint, the body of
+// a successful response being the access token.
+func requestAccessToken(ctx context.Context, client *http.Client, req *http.Request) (string, error) {
+	req.Header.Set(acceptHeader, textPlain)
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrap(err, "Error making authentication request")
+	}
+
+	body, err := readAndClose(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "Error reading authentication response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &AuthenticationError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	return string(body), nil
+}
+
+// readAndClose reads the whole of body before closing it.
+func readAndClose(body io.ReadCloser) (_ []byte, err error) {
+	defer func() {
+		if cerr := body.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	return ioutil.ReadAll(body)
+}
@@ -0,0 +1,279 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+// tokenServer returns a test server which issues the token "1234abcd" to
+// clients sending the ID "id" and secret "secret" in the way checked by
+// credentials, and records the Authorization header of graphql requests.
+func tokenServer(credentials func(r *http.Request) (string, string)) (*httptest.Server, *string) {
+	var graphqlAuth string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/accessToken":
+			id, secret := credentials(r)
+			if id != "id" || secret != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("ClientDoesNotExist: " + id))
+				return
+			}
+
+			w.Write([]byte("1234abcd"))
+		case "/graphql":
+			graphqlAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{"data": {"deleteOffering": {"id": "Provider-Offering"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return server, &graphqlAuth
+}
+
+func TestAuthenticators(t *testing.T) {
+	testcases := []struct {
+		name          string
+		authenticator bigiot.Authenticator
+		credentials   func(r *http.Request) (string, string)
+		warnings      int
+	}{
+		{
+			name:          "query",
+			authenticator: bigiot.QueryAuthenticator{},
+			credentials: func(r *http.Request) (string, string) {
+				assert.Equal(t, http.MethodGet, r.Method)
+				return r.URL.Query().Get("clientId"), r.URL.Query().Get("clientSecret")
+			},
+			warnings: 1,
+		},
+		{
+			name:          "form",
+			authenticator: bigiot.FormAuthenticator{},
+			credentials: func(r *http.Request) (string, string) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "", r.URL.RawQuery)
+				assert.Nil(t, r.ParseForm())
+				return r.PostForm.Get("clientId"), r.PostForm.Get("clientSecret")
+			},
+		},
+		{
+			name:          "header",
+			authenticator: bigiot.HeaderAuthenticator{},
+			credentials: func(r *http.Request) (string, string) {
+				assert.Equal(t, http.MethodGet, r.Method)
+				assert.Equal(t, "", r.URL.RawQuery)
+				id, secret, _ := r.BasicAuth()
+				return id, secret
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			server, graphqlAuth := tokenServer(testcase.credentials)
+			defer server.Close()
+
+			var buf bytes.Buffer
+
+			provider, err := bigiot.NewProvider(
+				"id",
+				"secret",
+				bigiot.WithMarketplace(server.URL),
+				bigiot.WithAuthenticator(testcase.authenticator),
+				bigiot.WithLogger(bigiot.NewStdLogger(log.New(&buf, "", 0), false)),
+			)
+			assert.Nil(t, err)
+
+			err = provider.Authenticate()
+			assert.Nil(t, err)
+
+			err = provider.DeleteOffering(context.Background(), &bigiot.DeleteOffering{ID: "Provider-Offering"})
+			assert.Nil(t, err)
+			assert.Equal(t, "Bearer 1234abcd", *graphqlAuth)
+
+			// the new credentials are sent when authenticating again, rather than
+			// the existing token
+			err = provider.Authenticate()
+			assert.Nil(t, err)
+
+			// sending the secret in the query string is only warned about once
+			assert.Equal(t, testcase.warnings, strings.Count(buf.String(), "client secret sent in the access token query string"))
+
+			provider, err = bigiot.NewProvider(
+				"id",
+				"wrong",
+				bigiot.WithMarketplace(server.URL),
+				bigiot.WithAuthenticator(testcase.authenticator),
+			)
+			assert.Nil(t, err)
+
+			err = provider.Authenticate()
+			assert.NotNil(t, err)
+			assert.Equal(t, "ClientDoesNotExist: id", err.Error())
+
+			authErr, ok := errors.Cause(err).(*bigiot.AuthenticationError)
+			assert.True(t, ok)
+			assert.Equal(t, http.StatusForbidden, authErr.StatusCode)
+		})
+	}
+}
+
+func TestClientCredentialsAuthenticator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/oauth/token", r.URL.Path)
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "offerings accounting", r.PostForm.Get("scope"))
+
+		w.Header().Set("Content-Type", "application/json")
+
+		// credentials are form encoded before being sent using basic auth
+		id, secret, _ := r.BasicAuth()
+		secret, _ = url.QueryUnescape(secret)
+
+		if id != "id" || secret != "s&cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"Client authentication failed"}`))
+			return
+		}
+
+		w.Write([]byte(`{"access_token":"1234abcd","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	authenticator := bigiot.ClientCredentialsAuthenticator{
+		TokenURL: "/oauth/token",
+		Scopes:   []string{"offerings", "accounting"},
+	}
+
+	provider, err := bigiot.NewProvider(
+		"id",
+		"s&cret",
+		bigiot.WithMarketplace(server.URL),
+		bigiot.WithAuthenticator(authenticator),
+	)
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.Nil(t, err)
+
+	provider, err = bigiot.NewProvider(
+		"id",
+		"wrong",
+		bigiot.WithMarketplace(server.URL),
+		bigiot.WithAuthenticator(authenticator),
+	)
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.NotNil(t, err)
+	assert.Equal(t, "Client authentication failed", err.Error())
+
+	provider, err = bigiot.NewProvider(
+		"id",
+		"secret",
+		bigiot.WithMarketplace(server.URL),
+		bigiot.WithAuthenticator(bigiot.ClientCredentialsAuthenticator{}),
+	)
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.NotNil(t, err)
+}
+
+func TestWithAccessToken(t *testing.T) {
+	server, graphqlAuth := tokenServer(func(r *http.Request) (string, string) {
+		t.Error("unexpected request for access token")
+		return "", ""
+	})
+	defer server.Close()
+
+	provider, err := bigiot.NewProvider(
+		"id",
+		"",
+		bigiot.WithMarketplace(server.URL),
+		bigiot.WithAccessToken("preissued"),
+	)
+	assert.Nil(t, err)
+
+	err = provider.DeleteOffering(context.Background(), &bigiot.DeleteOffering{ID: "Provider-Offering"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer preissued", *graphqlAuth)
+
+	err = provider.Authenticate()
+	assert.Nil(t, err)
+
+	_, err = bigiot.NewProvider("id", "", bigiot.WithAccessToken(""))
+	assert.NotNil(t, err)
+}
+
+func TestAuthenticateConcurrently(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/accessToken":
+			w.Write([]byte("1234abcd"))
+		case "/graphql":
+			if r.Header.Get("Authorization") != "Bearer 1234abcd" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"errors": [{"message": "unauthorized"}]}`))
+				return
+			}
+			w.Write([]byte(`{"data": {"deleteOffering": {"id": "Provider-Offering"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	provider, err := bigiot.NewProvider("id", "secret", bigiot.WithMarketplace(server.URL))
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.Nil(t, err)
+
+	// re-authenticating while other requests are in flight must be safe
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, provider.Authenticate())
+		}()
+
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, provider.DeleteOffering(context.Background(), &bigiot.DeleteOffering{ID: "Provider-Offering"}))
+		}()
+	}
+
+	wg.Wait()
+}
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -46,14 +47,43 @@ const (
 // they should use one of the functional configuration functions when
 // initializing an instance of the client.
 type base struct {
-	id          string
-	secret      string
-	userAgent   string
-	httpClient  *http.Client
-	baseURL     *url.URL
+	id            string
+	keys          *keyring
+	userAgent     string
+	httpClient    *http.Client
+	baseURL       *url.URL
+	graphqlURL    string
+	clock         Clock
+	metrics       Metrics
+	tracer        Tracer
+	logger        Logger
+	authenticator Authenticator
+	revocation    RevocationChecker
+
+	// tokenMu guards accessToken, which is replaced on re-authentication while
+	// other requests may be in flight.
+	tokenMu     sync.RWMutex
 	accessToken string
-	graphqlURL  string
-	clock       Clock
+
+	// queryWarning ensures we only warn once about sending the secret in the
+	// query string.
+	queryWarning sync.Once
+}
+
+// token returns the current access token.
+func (b *base) token() string {
+	b.tokenMu.RLock()
+	defer b.tokenMu.RUnlock()
+
+	return b.accessToken
+}
+
+// setToken replaces the current access token.
+func (b *base) setToken(token string) {
+	b.tokenMu.Lock()
+	defer b.tokenMu.Unlock()
+
+	b.accessToken = token
 }
 
 func newBase(id, secret string, options ...Option) (*base, error) {
@@ -67,12 +97,19 @@ func newBase(id, secret string, options ...Option) (*base, error) {
 	}
 
 	b := &base{
-		id:         id,
-		secret:     secret,
-		userAgent:  fmt.Sprintf("bigiot/%s (https://github.com/thingful/bigiot)", Version),
-		baseURL:    u,
-		httpClient: httpClient,
-		clock:      &realClock{},
+		id: id,
+		keys: &keyring{
+			source: StaticCredentials(secret),
+			grace:  DefaultRotationGracePeriod,
+		},
+		userAgent:     fmt.Sprintf("bigiot/%s (https://github.com/thingful/bigiot)", Version),
+		baseURL:       u,
+		httpClient:    httpClient,
+		clock:         &realClock{},
+		metrics:       noopMetrics{},
+		tracer:        noopTracer{},
+		logger:        noopLogger{},
+		authenticator: QueryAuthenticator{},
 	}
 
 	var err error
@@ -105,51 +142,52 @@ func newBase(id, secret string, options ...Option) (*base, error) {
 	return b, nil
 }
 
-// Authenticate makes a call to the /accessToken endpoint on the marketplace to
-// obtain an access token which the client will then be able to use when making
-// requests to the graphql endpoint. We make a GET request passing over our
-// client id and secret, and get back a token if our credentials are valid.
+// Authenticate obtains an access token from the marketplace which the client
+// will then be able to use when making requests to the graphql endpoint. By
+// default we make a GET request to the /accessToken endpoint passing over our
+// client id and secret, and get back a token if our credentials are valid. This
+// can be changed by means of the WithAuthenticator option. The secret is
+// obtained from the CredentialSource each time, so rotated secrets are used
+// when re-authenticating.
 func (b *base) Authenticate() (err error) {
-	// deference to make sure we clone our baseURL property rather than modifying
-	// the pointed to value
-	authURL := *b.baseURL
-	authURL.Path = "/accessToken"
-
-	params := &url.Values{
-		"clientId":     []string{b.id},
-		"clientSecret": []string{b.secret},
-	}
+	ctx, c := b.startCall(context.Background(), OperationAccessToken)
+	defer func() { c.end(err) }()
 
-	authURL.RawQuery = params.Encode()
-
-	req, err := http.NewRequest(http.MethodGet, authURL.String(), nil)
+	secret, err := b.keys.currentSecret(c.start)
 	if err != nil {
-		return errors.Wrap(err, "Error creating authentication request")
+		return err
 	}
 
-	req.Header.Set(acceptHeader, textPlain)
-
-	resp, err := b.httpClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "Error making authentication request")
+	credentials := Credentials{
+		ID:     b.id,
+		Secret: secret,
 	}
 
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil && err == nil {
-			err = cerr
-		}
-	}()
+	switch b.authenticator.(type) {
+	case QueryAuthenticator, *QueryAuthenticator:
+		b.queryWarning.Do(func() {
+			b.logger.Info("client secret sent in the access token query string, where it may be logged; use FormAuthenticator or HeaderAuthenticator if the marketplace supports them")
+		})
+	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return errors.Wrap(err, "Error reading authentication response")
+	// the authenticator makes its own requests, so log them as they are made
+	client := *b.httpClient
+	client.Transport = &loggingTransport{
+		logger:    b.logger,
+		operation: OperationAccessToken,
+		proxied:   b.httpClient.Transport,
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.New(string(body))
+	token, err := b.authenticator.AccessToken(ctx, &client, b.baseURL, credentials)
+	if err != nil {
+		if _, ok := errors.Cause(err).(*AuthenticationError); ok {
+			c.outcome = OutcomeRejected
+		}
+		return err
 	}
 
-	b.accessToken = string(body)
+	b.setToken(token)
+	c.outcome = OutcomeSuccess
 
 	return nil
 }
@@ -160,6 +198,20 @@ func (b *base) Authenticate() (err error) {
 // a slice of bytes which can then be unmarshalled by the caller to extract the
 // returned data.
 func (b *base) query(ctx context.Context, s serializable) (_ []byte, err error) {
+	ctx, c := b.startCall(ctx, operationName(s))
+	defer func() { c.end(err) }()
+
+	switch v := s.(type) {
+	case *OfferingDescription:
+		c.span.SetAttribute(AttributeOfferingLocalID, v.LocalID)
+	case *ActivateOffering:
+		c.span.SetAttribute(AttributeOfferingID, v.ID)
+	case *deactivateOffering:
+		c.span.SetAttribute(AttributeOfferingID, v.id)
+	case *DeleteOffering:
+		c.span.SetAttribute(AttributeOfferingID, v.ID)
+	}
+
 	q := &query{
 		Query: s.serialize(b.clock),
 	}
@@ -178,6 +230,8 @@ func (b *base) query(ctx context.Context, s serializable) (_ []byte, err error)
 
 	req = req.WithContext(ctx)
 
+	b.logger.Debug("sending request", "operation", c.operation, "query", q.Query)
+
 	resp, err := b.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -194,7 +248,11 @@ func (b *base) query(ctx context.Context, s serializable) (_ []byte, err error)
 		return nil, err
 	}
 
+	b.logger.Debug("received response", "operation", c.operation, "status", resp.StatusCode, "body", body)
+
 	if resp.StatusCode != http.StatusOK {
+		c.outcome = OutcomeRejected
+
 		errorResp := ErrorResponse{}
 		err = json.Unmarshal(body, &errorResp)
 		if err != nil {
@@ -208,6 +266,8 @@ func (b *base) query(ctx context.Context, s serializable) (_ []byte, err error)
 		return nil, errors.New(errorResp.Errors[0].Message)
 	}
 
+	c.outcome = OutcomeSuccess
+
 	return body, nil
 }
 
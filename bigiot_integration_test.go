@@ -67,7 +67,7 @@ func TestProviderIntegration(t *testing.T) {
 		License: bigiot.OpenDataLicense,
 		Price: bigiot.Price{
 			Money: bigiot.Money{
-				Amount:   0.001,
+				Amount:   bigiot.MustParseAmount("0.001"),
 				Currency: bigiot.EUR,
 			},
 			PricingModel: bigiot.PerAccess,
@@ -45,7 +45,7 @@ func TestRegisterOffering(t *testing.T) {
 			"https://market.big-iot.org/graphql",
 			simular.NewStringResponder(200, `{"data": {"addOffering": {"id": "Organization-Provider-TestOffering", "activation": { "status": true, "expirationTime": 1509983101577}}}}`),
 			simular.WithBody(
-				bytes.NewBufferString(`{"query":"mutation addOffering { addOffering ( input: { id: \"Provider\", localId: \"TestOffering\", name: \"Test Offering\", activation: { status: true, expirationTime: 1509983101577 }, rdfUri: \"urn:proposed:RandomValues\", outputs: [{ name: \"value\", rdfUri: \"schema:random\" }], endpoints: [{ uri: \"https://example.com/random\", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB }], license: OPEN_DATA_LICENSE, price: { money: { amount: 0.001, currency: EUR }, pricingModel: PER_ACCESS }, spatialExtent: { city: \"Berlin\", boundary: { l1: { lng: -2.25, lat: 54.53 }, l2: { lng: -2.26, lat: 54.96 } } } } ) { id name activation { status expirationTime } } }"}`),
+				bytes.NewBufferString(`{"query":"mutation addOffering { addOffering ( input: { id: \"Provider\", localId: \"TestOffering\", name: \"Test Offering\", activation: { status: true, expirationTime: 1509983101577 }, rdfUri: \"urn:proposed:RandomValues\", outputs: [{ name: \"value\", rdfUri: \"schema:random\" }], endpoints: [{ uri: \"https://example.com/random\", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB }], license: OPEN_DATA_LICENSE, price: { money: { amount: 0.001, currency: EUR }, pricingModel: PER_ACCESS }, spatialExtent: { city: \"Berlin\", boundary: { l1: { lng: -2.25, lat: 54.53 }, l2: { lng: -2.26, lat: 54.96 } } } } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }"}`),
 			),
 		),
 	)
@@ -76,7 +76,7 @@ func TestRegisterOffering(t *testing.T) {
 		License: bigiot.OpenDataLicense,
 		Price: bigiot.Price{
 			Money: bigiot.Money{
-				Amount:   0.001,
+				Amount:   bigiot.MustParseAmount("0.001"),
 				Currency: bigiot.EUR,
 			},
 			PricingModel: bigiot.PerAccess,
@@ -137,7 +137,7 @@ func TestRegisterOfferingWithDuration(t *testing.T) {
 		License: bigiot.OpenDataLicense,
 		Price: bigiot.Price{
 			Money: bigiot.Money{
-				Amount:   0.001,
+				Amount:   bigiot.MustParseAmount("0.001"),
 				Currency: bigiot.EUR,
 			},
 			PricingModel: bigiot.PerAccess,
@@ -163,7 +163,7 @@ func TestRegisterOfferingWithDuration(t *testing.T) {
 				"https://market.big-iot.org/graphql",
 				simular.NewStringResponder(200, `{"data": {"addOffering": {"id": "Organization-Provider-TestOffering", "activation": { "status": true, "expirationTime": 600000}}}}`),
 				simular.WithBody(
-					bytes.NewBufferString(`{"query":"mutation addOffering { addOffering ( input: { id: \"Provider\", localId: \"TestOffering\", name: \"Test Offering\", activation: { status: true, expirationTime: 600000 }, rdfUri: \"urn:proposed:RandomValues\", outputs: [{ name: \"value\", rdfUri: \"schema:random\" }], endpoints: [{ uri: \"https://example.com/random\", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB }], license: OPEN_DATA_LICENSE, price: { money: { amount: 0.001, currency: EUR }, pricingModel: PER_ACCESS }, spatialExtent: { city: \"Berlin\" } } ) { id name activation { status expirationTime } } }"}`),
+					bytes.NewBufferString(`{"query":"mutation addOffering { addOffering ( input: { id: \"Provider\", localId: \"TestOffering\", name: \"Test Offering\", activation: { status: true, expirationTime: 600000 }, rdfUri: \"urn:proposed:RandomValues\", outputs: [{ name: \"value\", rdfUri: \"schema:random\" }], endpoints: [{ uri: \"https://example.com/random\", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB }], license: OPEN_DATA_LICENSE, price: { money: { amount: 0.001, currency: EUR }, pricingModel: PER_ACCESS }, spatialExtent: { city: \"Berlin\" } } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }"}`),
 				),
 			),
 		)
@@ -197,7 +197,7 @@ func TestRegisterOfferingWithDuration(t *testing.T) {
 				"https://market.big-iot.org/graphql",
 				simular.NewStringResponder(400, `{"data":null,"errors":[{"message":"bad request"}]}`),
 				simular.WithBody(
-					bytes.NewBufferString(`{"query":"mutation addOffering { addOffering ( input: { id: \"Provider\", localId: \"TestOffering\", name: \"Test Offering\", activation: {status: true, expirationTime: 600000} , rdfUri: \"\", inputData: [], outputData: [{name: \"value\", rdfUri: \"schema:random\"} ], endpoints: [{uri: \"https://example.com/random\", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB} ], license: OPEN_DATA_LICENSE, price: {money: {amount: 0.001, currency: EUR}, pricingModel: PER_ACCESS}, extent: {city: \"Berlin\"} } ) { id name activation { status expirationTime } } }"}`),
+					bytes.NewBufferString(`{"query":"mutation addOffering { addOffering ( input: { id: \"Provider\", localId: \"TestOffering\", name: \"Test Offering\", activation: {status: true, expirationTime: 600000} , rdfUri: \"\", inputData: [], outputData: [{name: \"value\", rdfUri: \"schema:random\"} ], endpoints: [{uri: \"https://example.com/random\", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB} ], license: OPEN_DATA_LICENSE, price: {money: {amount: 0.001, currency: EUR}, pricingModel: PER_ACCESS}, extent: {city: \"Berlin\"} } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }"}`),
 				),
 			),
 		)
@@ -229,7 +229,7 @@ func TestRegisterOfferingWithDuration(t *testing.T) {
 				"https://market.big-iot.org/graphql",
 				simular.NewStringResponder(200, `{"data": {"addOffering": {"id": "Organization-Provider-TestOffering"`),
 				simular.WithBody(
-					bytes.NewBufferString(`{"query":"mutation addOffering { addOffering ( input: { id: \"Provider\", localId: \"TestOffering\", name: \"Test Offering\", activation: {status: true, expirationTime: 600000} , rdfUri: \"\", inputData: [], outputData: [{name: \"value\", rdfUri: \"schema:random\"} ], endpoints: [{uri: \"https://example.com/random\", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB} ], license: OPEN_DATA_LICENSE, price: {money: {amount: 0.001, currency: EUR}, pricingModel: PER_ACCESS}, extent: {city: \"Berlin\"} } ) { id name activation { status expirationTime } } }"}`),
+					bytes.NewBufferString(`{"query":"mutation addOffering { addOffering ( input: { id: \"Provider\", localId: \"TestOffering\", name: \"Test Offering\", activation: {status: true, expirationTime: 600000} , rdfUri: \"\", inputData: [], outputData: [{name: \"value\", rdfUri: \"schema:random\"} ], endpoints: [{uri: \"https://example.com/random\", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB} ], license: OPEN_DATA_LICENSE, price: {money: {amount: 0.001, currency: EUR}, pricingModel: PER_ACCESS}, extent: {city: \"Berlin\"} } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }"}`),
 				),
 			),
 		)
@@ -336,7 +336,7 @@ func TestActivatingOffering(t *testing.T) {
 			"https://market.big-iot.org/graphql",
 			simular.NewStringResponder(200, fmt.Sprintf(`{"data": {"activateOffering": {"id": "Organization-Provider-TestOffering", "activation": { "status": true, "expirationTime": %v}}}}`, bigiot.ToEpochMs(now.Add(10*time.Minute)))),
 			simular.WithBody(
-				bytes.NewBufferString(fmt.Sprintf(`{"query":"mutation activateOffering { activateOffering ( input: { id: \"Organization-Provider-TestOffering\", expirationTime: %v } ) { id activation { status expirationTime } } }"}`, bigiot.ToEpochMs(now.Add(10*time.Minute)))),
+				bytes.NewBufferString(fmt.Sprintf(`{"query":"mutation activateOffering { activateOffering ( input: { id: \"Organization-Provider-TestOffering\", expirationTime: %v } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }"}`, bigiot.ToEpochMs(now.Add(10*time.Minute)))),
 			),
 		),
 	)
@@ -358,3 +358,38 @@ func TestActivatingOffering(t *testing.T) {
 	_, err = provider.ActivateOffering(context.Background(), activateOffering)
 	assert.Nil(t, err)
 }
+
+func TestDeactivatingOffering(t *testing.T) {
+	now := time.Now()
+
+	simular.Activate()
+	defer simular.DeactivateAndReset()
+
+	simular.RegisterStubRequests(
+		simular.NewStubRequest(
+			http.MethodGet,
+			"https://market.big-iot.org/accessToken?clientId=Provider&clientSecret=secret",
+			simular.NewStringResponder(200, "1234abcd"),
+		),
+		simular.NewStubRequest(
+			http.MethodPost,
+			"https://market.big-iot.org/graphql",
+			simular.NewStringResponder(200, fmt.Sprintf(`{"data": {"deactivateOffering": {"id": "Organization-Provider-TestOffering", "activation": { "status": false, "expirationTime": %v}}}}`, bigiot.ToEpochMs(now.Add(10*time.Minute)))),
+			simular.WithBody(
+				bytes.NewBufferString(`{"query":"mutation deactivateOffering { deactivateOffering ( input: { id: \"Organization-Provider-TestOffering\" } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }"}`),
+			),
+		),
+	)
+
+	provider, err := bigiot.NewProvider("Provider", "secret")
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.Nil(t, err)
+
+	offering, err := provider.DeactivateOffering(context.Background(), "Organization-Provider-TestOffering")
+	assert.Nil(t, err)
+	assert.Equal(t, "Organization-Provider-TestOffering", offering.ID)
+	assert.False(t, offering.Activation.Status)
+	assert.False(t, offering.IsActive(mocks.Clock{T: now}))
+}
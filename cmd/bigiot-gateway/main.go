@@ -0,0 +1,156 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command bigiot-gateway exposes an existing HTTP API as a BIG IoT offering,
+// as described by a gateway config file (see gateway.ReadConfig).
+//
+// Usage:
+//		BIGIOT_PROVIDER_SECRET=... bigiot-gateway -provider Org-Provider -config parking.json
+//
+// The offering is served at the path of its first endpoint, or at / if it has
+// no endpoints. On SIGTERM or an interrupt the offering is deactivated on the
+// marketplace before exiting.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/gateway"
+)
+
+func main() {
+	var (
+		configPath     = flag.String("config", "gateway.json", "path of the gateway config file")
+		listen         = flag.String("listen", ":8080", "address on which to serve the offering")
+		providerID     = flag.String("provider", "", "ID of the provider registering the offering")
+		marketplace    = flag.String("marketplace", bigiot.DefaultMarketplaceURL, "URL of the marketplace")
+		secretEnv      = flag.String("secret-env", "BIGIOT_PROVIDER_SECRET", "environment variable containing the provider secret")
+		secretFile     = flag.String("secret-file", "", "file containing the provider secret, used instead of -secret-env")
+		accountingFile = flag.String("accounting-file", "", "file in which unreported usage is stored")
+		debug          = flag.Bool("debug", false, "log requests made to the marketplace")
+	)
+
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	if *providerID == "" {
+		logger.Fatal("missing provider ID, set it with -provider")
+	}
+
+	config, err := gateway.LoadConfig(*configPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	credentials := bigiot.EnvCredentials(*secretEnv)
+	if *secretFile != "" {
+		credentials = bigiot.FileCredentials(*secretFile)
+	}
+
+	provider, err := bigiot.NewProvider(
+		*providerID,
+		"",
+		bigiot.WithMarketplace(*marketplace),
+		bigiot.WithCredentialSource(credentials),
+		bigiot.WithLogger(bigiot.NewStdLogger(logger, *debug)),
+	)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	options := []gateway.Option{
+		gateway.WithErrorHandler(func(err error) {
+			logger.Println(err)
+		}),
+	}
+
+	var store *bigiot.FileAccountingStore
+
+	if *accountingFile != "" {
+		store, err = bigiot.NewFileAccountingStore(*accountingFile)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		options = append(options, gateway.WithAccountingStore(store))
+	}
+
+	lifecycle := bigiot.NewLifecycle(provider)
+
+	gw, err := gateway.New(provider, *config, options...)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(endpointPath(config), gw)
+
+	go func() {
+		logger.Fatal(http.ListenAndServe(*listen, mux))
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	err = gw.Run(ctx)
+	if err != nil && err != context.Canceled {
+		logger.Fatal(err)
+	}
+
+	// write any usage recorded since the last flush, so it is reported after we
+	// restart
+	if store != nil {
+		err = store.Close()
+		if err != nil {
+			logger.Println(err)
+		}
+	}
+
+	// deactivate the offering so consumers stop using it until we restart
+	_, err = lifecycle.Shutdown(context.Background())
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// endpointPath returns the path of the first endpoint of the offering, or / if
+// it has none.
+func endpointPath(config *gateway.Config) string {
+	if len(config.Offering.Endpoints) == 0 {
+		return "/"
+	}
+
+	u, err := url.Parse(config.Offering.Endpoints[0].URI)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+
+	return u.Path
+}
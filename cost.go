@@ -0,0 +1,393 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultResponseSizeEstimate is the default number of bytes a CostTracker
+// assumes an access of an offering priced PerByte returns before any responses
+// have been recorded for the subscription.
+const DefaultResponseSizeEstimate = 64 * 1024
+
+// ErrBudgetExceeded is the error returned by CostTracker.Check and
+// CostTracker.Reserve when the estimated cost of an access would take the
+// spend on a subscription over its budget.
+type ErrBudgetExceeded struct {
+	SubscriptionID string
+	Budget         Money
+	Spent          Money
+	Estimate       Money
+}
+
+// Error is our implementation of the error interface.
+func (e *ErrBudgetExceeded) Error() string {
+	return fmt.Sprintf(
+		"budget exceeded for subscription %s: spent %s, estimated cost %s, budget %s",
+		e.SubscriptionID,
+		e.Spent,
+		e.Estimate,
+		e.Budget,
+	)
+}
+
+// ErrCurrencyMismatch is the error returned by CostTracker.Check and
+// CostTracker.Reserve when the budget of a subscription is in a different
+// currency to the price of the offering, so the cost can't be compared with
+// the budget.
+type ErrCurrencyMismatch struct {
+	SubscriptionID string
+	Budget         Currency
+	Price          Currency
+}
+
+// Error is our implementation of the error interface.
+func (e *ErrCurrencyMismatch) Error() string {
+	return fmt.Sprintf(
+		"budget currency %s does not match price currency %s for subscription %s",
+		e.Budget,
+		e.Price,
+		e.SubscriptionID,
+	)
+}
+
+// CostTracker estimates and accumulates the spend on subscriptions based on
+// the Price of the subscribed offering, and enforces optional per
+// subscription budgets. Consumers call Reserve before each access of an
+// offering, which fails if the estimated cost would exceed the budget, and
+// then Commit the reservation with the size of the response once it has been
+// received, or Release it if the access failed. Subscription.Access does this
+// when given the WithCostTracker option.
+//
+// Offerings priced PerAccess cost their price for every access, offerings
+// priced PerByte cost their price for every byte of response (with the cost
+// of the next access estimated from the average response size seen so far,
+// or the response size estimate before the first access), and offerings
+// priced PerMonth cost their price for the first access in each calendar
+// month.
+type CostTracker struct {
+	clock        Clock
+	responseSize int64
+
+	mu            sync.Mutex
+	budgets       map[string]Money
+	subscriptions map[string]*subscriptionCost
+}
+
+// subscriptionCost is the accumulated cost of a single subscription.
+type subscriptionCost struct {
+	spent        Money
+	reserved     Amount
+	accesses     int64
+	bytes        int64
+	chargedMonth string
+}
+
+// CostTrackerOption is a functional configuration type used to configure
+// optional behaviour of a CostTracker.
+type CostTrackerOption func(*CostTracker)
+
+// WithResponseSizeEstimate is a CostTrackerOption setting the number of bytes
+// an access of an offering priced PerByte is assumed to return until a
+// response has been recorded for the subscription. The default is
+// DefaultResponseSizeEstimate.
+func WithResponseSizeEstimate(bytes int64) CostTrackerOption {
+	return func(c *CostTracker) {
+		c.responseSize = bytes
+	}
+}
+
+// NewCostTracker returns a new CostTracker. The clock is used to determine
+// calendar months for offerings priced PerMonth; if nil the real clock is
+// used.
+func NewCostTracker(clock Clock, options ...CostTrackerOption) *CostTracker {
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	c := &CostTracker{
+		clock:         clock,
+		responseSize:  DefaultResponseSizeEstimate,
+		budgets:       make(map[string]Money),
+		subscriptions: make(map[string]*subscriptionCost),
+	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return c
+}
+
+// SetBudget sets the maximum amount that may be spent on the subscription.
+func (c *CostTracker) SetBudget(subscriptionID string, budget Money) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.budgets[subscriptionID] = budget
+}
+
+// Spent returns the amount spent so far on the subscription.
+func (c *CostTracker) Spent(subscriptionID string) Money {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.subscriptions[subscriptionID]; ok {
+		return s.spent
+	}
+
+	return Money{}
+}
+
+// Estimate returns the estimated cost of the next access of an offering with
+// the given price on the subscription.
+func (c *CostTracker) Estimate(subscriptionID string, price Price) Money {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.cost(c.subscriptions[subscriptionID], price, -1)
+}
+
+// Check returns an ErrBudgetExceeded error if the estimated cost of the next
+// access of an offering with the given price would take the spend on the
+// subscription, including any outstanding reservations, over its budget. If
+// no budget has been set for the subscription, Check always returns nil.
+// Check reserves nothing, so concurrent callers enforcing a budget should use
+// Reserve instead.
+func (c *CostTracker) Check(subscriptionID string, price Price) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.check(subscriptionID, price)
+
+	return err
+}
+
+// Reserve checks the estimated cost of the next access of an offering with
+// the given price against the budget of the subscription exactly as Check
+// does, and if it is within budget holds the estimate against the budget
+// until the returned Reservation is committed or released. Checking and
+// reserving happen atomically, so concurrent accesses can't together exceed
+// the budget.
+//
+// Example:
+//		reservation, err := tracker.Reserve(subscriptionID, price)
+//		if err != nil {
+//			return err // budget exceeded
+//		}
+//
+//		bytes, err := access()
+//		if err != nil {
+//			reservation.Release()
+//			return err
+//		}
+//
+//		reservation.Commit(bytes)
+func (c *CostTracker) Reserve(subscriptionID string, price Price) (*Reservation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	estimate, err := c.check(subscriptionID, price)
+	if err != nil {
+		return nil, err
+	}
+
+	s := c.subscription(subscriptionID, price)
+	s.reserved = s.reserved.Add(estimate.Amount)
+
+	return &Reservation{
+		tracker:        c,
+		subscriptionID: subscriptionID,
+		price:          price,
+		estimate:       estimate,
+	}, nil
+}
+
+// Record adds the cost of an access of an offering with the given price, which
+// returned the given number of bytes, to the spend on the subscription. The
+// cost of the access is returned.
+func (c *CostTracker) Record(subscriptionID string, price Price, bytes int64) Money {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.record(subscriptionID, price, bytes)
+}
+
+// Reservation is an estimated cost held against the budget of a subscription
+// by CostTracker.Reserve while an access is made. Exactly one of Commit or
+// Release should be called once the access completes; subsequent calls have
+// no effect.
+type Reservation struct {
+	tracker        *CostTracker
+	subscriptionID string
+	price          Price
+	estimate       Money
+	done           bool
+}
+
+// Estimate returns the estimated cost held by the reservation.
+func (r *Reservation) Estimate() Money {
+	return r.estimate
+}
+
+// Commit releases the reservation and records the actual cost of the access,
+// which returned the given number of bytes, returning that cost. The actual
+// cost of an access of an offering priced PerByte may be more than the
+// estimate if the response was larger than expected.
+func (r *Reservation) Commit(bytes int64) Money {
+	c := r.tracker
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r.done {
+		return Money{Currency: r.price.Money.Currency}
+	}
+
+	r.release()
+
+	return c.record(r.subscriptionID, r.price, bytes)
+}
+
+// Release releases the reservation without recording any cost, for example
+// because the access failed.
+func (r *Reservation) Release() {
+	c := r.tracker
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !r.done {
+		r.release()
+	}
+}
+
+// release removes the reserved estimate from the subscription. The caller
+// must hold the tracker's lock.
+func (r *Reservation) release() {
+	r.done = true
+
+	s := r.tracker.subscriptions[r.subscriptionID]
+	s.reserved = s.reserved.Sub(r.estimate.Amount)
+}
+
+// check returns the estimated cost of the next access of an offering with the
+// given price, or an error if it would take the subscription over budget. The
+// caller must hold the lock.
+func (c *CostTracker) check(subscriptionID string, price Price) (Money, error) {
+	s := c.subscriptions[subscriptionID]
+	estimate := c.cost(s, price, -1)
+
+	budget, ok := c.budgets[subscriptionID]
+	if !ok {
+		return estimate, nil
+	}
+
+	if budget.Currency != price.Money.Currency && price.PricingModel != Free {
+		return estimate, &ErrCurrencyMismatch{
+			SubscriptionID: subscriptionID,
+			Budget:         budget.Currency,
+			Price:          price.Money.Currency,
+		}
+	}
+
+	spent := Money{Currency: budget.Currency}
+
+	var committed Amount
+	if s != nil {
+		spent = s.spent
+		committed = s.spent.Amount.Add(s.reserved)
+	}
+
+	if committed.Add(estimate.Amount).Cmp(budget.Amount) > 0 {
+		return estimate, &ErrBudgetExceeded{
+			SubscriptionID: subscriptionID,
+			Budget:         budget,
+			Spent:          spent,
+			Estimate:       estimate,
+		}
+	}
+
+	return estimate, nil
+}
+
+// record adds the cost of an access to the spend on the subscription. The
+// caller must hold the lock.
+func (c *CostTracker) record(subscriptionID string, price Price, bytes int64) Money {
+	s := c.subscription(subscriptionID, price)
+
+	cost := c.cost(s, price, bytes)
+
+	s.spent.Amount = s.spent.Amount.Add(cost.Amount)
+	s.accesses++
+	s.bytes += bytes
+
+	if price.PricingModel == PerMonth {
+		s.chargedMonth = c.month()
+	}
+
+	return cost
+}
+
+// subscription returns the accumulated cost of the subscription, creating it
+// if required. The caller must hold the lock.
+func (c *CostTracker) subscription(subscriptionID string, price Price) *subscriptionCost {
+	s, ok := c.subscriptions[subscriptionID]
+	if !ok {
+		s = &subscriptionCost{
+			spent: Money{Currency: price.Money.Currency},
+		}
+		c.subscriptions[subscriptionID] = s
+	}
+
+	return s
+}
+
+// cost returns the cost of an access on the subscription. If bytes is negative
+// the cost of a PerByte access is estimated from the average response size,
+// or the response size estimate if no responses have been recorded. The
+// caller must hold the lock.
+func (c *CostTracker) cost(s *subscriptionCost, price Price, bytes int64) Money {
+	cost := Money{Currency: price.Money.Currency}
+
+	switch price.PricingModel {
+	case PerAccess:
+		cost.Amount = price.Money.Amount
+
+	case PerByte:
+		if bytes < 0 {
+			if s == nil || s.accesses == 0 {
+				bytes = c.responseSize
+			} else {
+				bytes = s.bytes / s.accesses
+			}
+		}
+		cost.Amount = price.Money.Amount.MulInt(bytes)
+
+	case PerMonth:
+		if s == nil || s.chargedMonth != c.month() {
+			cost.Amount = price.Money.Amount
+		}
+	}
+
+	return cost
+}
+
+// month returns the current calendar month in UTC.
+func (c *CostTracker) month() string {
+	return c.clock.Now().UTC().Format("2006-01")
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/mocks"
+)
+
+func TestCostTrackerPerAccess(t *testing.T) {
+	tracker := bigiot.NewCostTracker(nil)
+	tracker.SetBudget("sub", bigiot.Money{Amount: bigiot.NewAmount(2, 0), Currency: bigiot.EUR})
+
+	price := bigiot.Price{
+		PricingModel: bigiot.PerAccess,
+		Money:        bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR},
+	}
+
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR}, tracker.Estimate("sub", price))
+
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, tracker.Check("sub", price))
+		tracker.Record("sub", price, 100)
+	}
+
+	err := tracker.Check("sub", price)
+	assert.NotNil(t, err)
+	assert.IsType(t, &bigiot.ErrBudgetExceeded{}, err)
+	assert.Equal(t, "budget exceeded for subscription sub: spent 2.00 EUR, estimated cost 1.00 EUR, budget 2.00 EUR", err.Error())
+
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(2, 0), Currency: bigiot.EUR}, tracker.Spent("sub"))
+
+	// subscriptions without a budget are never blocked
+	assert.Nil(t, tracker.Check("other", price))
+}
+
+func TestCostTrackerPerByte(t *testing.T) {
+	tracker := bigiot.NewCostTracker(nil, bigiot.WithResponseSizeEstimate(200))
+	tracker.SetBudget("sub", bigiot.Money{Amount: bigiot.NewAmount(250, 0), Currency: bigiot.EUR})
+
+	price := bigiot.Price{
+		PricingModel: bigiot.PerByte,
+		Money:        bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR},
+	}
+
+	// no history, so the response size estimate is used
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(200, 0), Currency: bigiot.EUR}, tracker.Estimate("sub", price))
+
+	assert.Nil(t, tracker.Check("sub", price))
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(100, 0), Currency: bigiot.EUR}, tracker.Record("sub", price, 100))
+
+	assert.Nil(t, tracker.Check("sub", price))
+	tracker.Record("sub", price, 50)
+
+	// average response is 75 bytes, and we've spent 150
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(75, 0), Currency: bigiot.EUR}, tracker.Estimate("sub", price))
+	assert.Nil(t, tracker.Check("sub", price))
+
+	tracker.Record("sub", price, 75)
+	assert.NotNil(t, tracker.Check("sub", price))
+}
+
+func TestCostTrackerPerMonth(t *testing.T) {
+	clock := &mocks.Clock{T: time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC)}
+	tracker := bigiot.NewCostTracker(clock)
+
+	price := bigiot.Price{
+		PricingModel: bigiot.PerMonth,
+		Money:        bigiot.Money{Amount: bigiot.NewAmount(10, 0), Currency: bigiot.EUR},
+	}
+
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(10, 0), Currency: bigiot.EUR}, tracker.Record("sub", price, 100))
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(0, 0), Currency: bigiot.EUR}, tracker.Record("sub", price, 100))
+
+	clock.T = time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(10, 0), Currency: bigiot.EUR}, tracker.Estimate("sub", price))
+	tracker.Record("sub", price, 100)
+
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(20, 0), Currency: bigiot.EUR}, tracker.Spent("sub"))
+}
+
+func TestCostTrackerCurrencyMismatch(t *testing.T) {
+	tracker := bigiot.NewCostTracker(nil)
+	tracker.SetBudget("sub", bigiot.Money{Amount: bigiot.NewAmount(10, 0), Currency: bigiot.CHF})
+
+	price := bigiot.Price{
+		PricingModel: bigiot.PerAccess,
+		Money:        bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR},
+	}
+
+	err := tracker.Check("sub", price)
+	assert.IsType(t, &bigiot.ErrCurrencyMismatch{}, err)
+	assert.Equal(t, "budget currency CHF does not match price currency EUR for subscription sub", err.Error())
+
+	_, err = tracker.Reserve("sub", price)
+	assert.IsType(t, &bigiot.ErrCurrencyMismatch{}, err)
+
+	assert.Nil(t, tracker.Check("sub", bigiot.Price{PricingModel: bigiot.Free}))
+}
+
+func TestCostTrackerPerByteFirstAccess(t *testing.T) {
+	tracker := bigiot.NewCostTracker(nil)
+	tracker.SetBudget("sub", bigiot.Money{Amount: bigiot.MustParseAmount("0.01"), Currency: bigiot.EUR})
+
+	price := bigiot.Price{
+		PricingModel: bigiot.PerByte,
+		Money:        bigiot.Money{Amount: bigiot.MustParseAmount("0.000001"), Currency: bigiot.EUR},
+	}
+
+	// the default estimate of 64KiB costs more than the budget, so even the
+	// first access is refused
+	err := tracker.Check("sub", price)
+	assert.IsType(t, &bigiot.ErrBudgetExceeded{}, err)
+}
+
+func TestCostTrackerReserve(t *testing.T) {
+	tracker := bigiot.NewCostTracker(nil)
+	tracker.SetBudget("sub", bigiot.Money{Amount: bigiot.NewAmount(2, 0), Currency: bigiot.EUR})
+
+	price := bigiot.Price{
+		PricingModel: bigiot.PerAccess,
+		Money:        bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR},
+	}
+
+	first, err := tracker.Reserve("sub", price)
+	assert.Nil(t, err)
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR}, first.Estimate())
+
+	second, err := tracker.Reserve("sub", price)
+	assert.Nil(t, err)
+
+	// both accesses are in flight, so a third would exceed the budget
+	_, err = tracker.Reserve("sub", price)
+	assert.IsType(t, &bigiot.ErrBudgetExceeded{}, err)
+	assert.IsType(t, &bigiot.ErrBudgetExceeded{}, tracker.Check("sub", price))
+
+	// a released reservation frees its share of the budget
+	second.Release()
+	second.Release()
+	assert.Nil(t, tracker.Check("sub", price))
+
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR}, first.Commit(100))
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(0, 0), Currency: bigiot.EUR}, first.Commit(100))
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR}, tracker.Spent("sub"))
+}
+
+func TestCostTrackerReserveConcurrent(t *testing.T) {
+	tracker := bigiot.NewCostTracker(nil)
+	tracker.SetBudget("sub", bigiot.Money{Amount: bigiot.NewAmount(5, 0), Currency: bigiot.EUR})
+
+	price := bigiot.Price{
+		PricingModel: bigiot.PerAccess,
+		Money:        bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR},
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			reservation, err := tracker.Reserve("sub", price)
+			if err == nil {
+				reservation.Commit(100)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(5, 0), Currency: bigiot.EUR}, tracker.Spent("sub"))
+}
@@ -0,0 +1,247 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRotationGracePeriod is the default length of time for which tokens
+// signed with the previous secret are still accepted after the secret
+// returned by a CredentialSource changes.
+const DefaultRotationGracePeriod = 5 * time.Minute
+
+// CredentialSource is the interface used to obtain the client secret, which is
+// used both to authenticate with the marketplace and to validate the tokens
+// presented by consumers. The secret is requested every time it is needed, so
+// implementations returning a new value allow secrets to be rotated without
+// restarting the client.
+type CredentialSource interface {
+	// Secret returns the current client secret. Implementations must be safe
+	// for concurrent use.
+	Secret() (string, error)
+}
+
+// WithCredentialSource allows a caller to supply the client secret by means of
+// a CredentialSource rather than as a fixed string, in which case the secret
+// passed to NewProvider is ignored.
+//
+// Example:
+// 		provider, _ := bigiot.NewProvider(
+//			providerID,
+//			"",
+//			bigiot.WithCredentialSource(bigiot.FileCredentials("/var/run/secrets/bigiot")),
+// 		)
+func WithCredentialSource(source CredentialSource) Option {
+	return func(b *base) error {
+		b.keys.source = source
+
+		return nil
+	}
+}
+
+// WithRotationGracePeriod allows a caller to set how long tokens signed with
+// the previous secret should still be accepted after the secret returned by
+// the CredentialSource changes. A period of zero means tokens signed with the
+// previous secret are rejected immediately.
+//
+// Example:
+// 		provider, _ := bigiot.NewProvider(
+//			providerID,
+//			"",
+//			bigiot.WithCredentialSource(source),
+//			bigiot.WithRotationGracePeriod(time.Hour),
+// 		)
+func WithRotationGracePeriod(period time.Duration) Option {
+	return func(b *base) error {
+		if period < 0 {
+			return errors.New("rotation grace period must not be negative")
+		}
+
+		b.keys.grace = period
+
+		return nil
+	}
+}
+
+// StaticCredentials returns a CredentialSource which always returns the given
+// secret. This is the source used for the secret passed to NewProvider.
+func StaticCredentials(secret string) CredentialSource {
+	return staticCredentials(secret)
+}
+
+// staticCredentials is our implementation of StaticCredentials.
+type staticCredentials string
+
+// Secret is our implementation of CredentialSource.
+func (c staticCredentials) Secret() (string, error) {
+	return string(c), nil
+}
+
+// EnvCredentials returns a CredentialSource which reads the secret from the
+// named environment variable every time it is requested.
+func EnvCredentials(name string) CredentialSource {
+	return envCredentials(name)
+}
+
+// envCredentials is our implementation of EnvCredentials.
+type envCredentials string
+
+// Secret is our implementation of CredentialSource.
+func (c envCredentials) Secret() (string, error) {
+	secret := os.Getenv(string(c))
+	if secret == "" {
+		return "", errors.Errorf("environment variable %s is not set", string(c))
+	}
+
+	return secret, nil
+}
+
+// FileCredentials returns a CredentialSource which reads the secret from the
+// file at the given path, ignoring any leading or trailing whitespace. The
+// file is read again whenever its modification time or size changes, so
+// secrets mounted from a vault are picked up when they are rotated.
+func FileCredentials(path string) CredentialSource {
+	return &fileCredentials{path: path}
+}
+
+// fileCredentials is our implementation of FileCredentials, caching the secret
+// along with the file info it was read with.
+type fileCredentials struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	secret  string
+}
+
+// Secret is our implementation of CredentialSource.
+func (c *fileCredentials) Secret() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading credentials file")
+	}
+
+	if c.secret != "" && info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return c.secret, nil
+	}
+
+	contents, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading credentials file")
+	}
+
+	secret := strings.TrimSpace(string(contents))
+	if secret == "" {
+		return "", errors.Errorf("credentials file %s is empty", c.path)
+	}
+
+	c.secret = secret
+	c.modTime = info.ModTime()
+	c.size = info.Size()
+
+	return secret, nil
+}
+
+// keyring tracks the secret returned by a CredentialSource, caching the
+// decoded key used to validate tokens, and retaining the previous key for the
+// grace period after the secret changes.
+type keyring struct {
+	mu     sync.Mutex
+	source CredentialSource
+	grace  time.Duration
+
+	secret      string
+	key         []byte
+	keyErr      error
+	previousKey []byte
+	rotatedAt   time.Time
+}
+
+// currentSecret returns the current secret from the source, recording a
+// rotation if it has changed since it was last requested.
+func (k *keyring) currentSecret(now time.Time) (string, error) {
+	secret, err := k.source.Secret()
+	if err != nil {
+		return "", errors.Wrap(err, "error obtaining secret")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// the first secret requested isn't a rotation
+	loaded := k.key != nil || k.keyErr != nil
+
+	if loaded && secret == k.secret {
+		return secret, nil
+	}
+
+	key, keyErr := base64.StdEncoding.DecodeString(secret)
+	if keyErr != nil {
+		key = nil
+	}
+
+	// the previous key is nil if the previous secret couldn't be decoded, so
+	// that no key older than the previous secret remains valid
+	if loaded {
+		k.previousKey = k.key
+		k.rotatedAt = now
+	}
+
+	k.secret = secret
+	k.key = key
+	k.keyErr = keyErr
+
+	return secret, nil
+}
+
+// validationKeys returns the keys with which tokens may currently be signed,
+// the key for the current secret first followed by the previous key if the
+// secret was rotated within the grace period.
+func (k *keyring) validationKeys(now time.Time) ([][]byte, error) {
+	_, err := k.currentSecret(now)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys := [][]byte{}
+
+	if k.keyErr == nil {
+		keys = append(keys, k.key)
+	}
+
+	if k.previousKey != nil && now.Before(k.rotatedAt.Add(k.grace)) {
+		keys = append(keys, k.previousKey)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.Wrap(k.keyErr, "decoding secret failed")
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,269 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/simular"
+)
+
+// rotatedSecret is a second valid secret to rotate testSecret to
+const rotatedSecret = "dGVzdHNlY3JldDI="
+
+// rotatingCredentials is a CredentialSource whose secret can be changed.
+type rotatingCredentials struct {
+	sync.Mutex
+	secret string
+}
+
+func (c *rotatingCredentials) Secret() (string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.secret, nil
+}
+
+func (c *rotatingCredentials) rotate(secret string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.secret = secret
+}
+
+// settableClock is a Clock whose time can be changed.
+type settableClock struct {
+	sync.Mutex
+	t time.Time
+}
+
+func (c *settableClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.t
+}
+
+func (c *settableClock) set(t time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.t = t
+}
+
+func TestStaticCredentials(t *testing.T) {
+	secret, err := bigiot.StaticCredentials("secret").Secret()
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", secret)
+}
+
+func TestEnvCredentials(t *testing.T) {
+	source := bigiot.EnvCredentials("BIGIOT_TEST_SECRET")
+
+	os.Unsetenv("BIGIOT_TEST_SECRET")
+
+	_, err := source.Secret()
+	assert.NotNil(t, err)
+
+	os.Setenv("BIGIOT_TEST_SECRET", "secret")
+	defer os.Unsetenv("BIGIOT_TEST_SECRET")
+
+	secret, err := source.Secret()
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", secret)
+
+	os.Setenv("BIGIOT_TEST_SECRET", "rotated")
+
+	secret, err = source.Secret()
+	assert.Nil(t, err)
+	assert.Equal(t, "rotated", secret)
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bigiot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "secret")
+	source := bigiot.FileCredentials(path)
+
+	_, err = source.Secret()
+	assert.NotNil(t, err)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("\n"), 0600))
+
+	_, err = source.Secret()
+	assert.NotNil(t, err)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("secret\n"), 0600))
+
+	secret, err := source.Secret()
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", secret)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("rotated\n"), 0600))
+
+	secret, err = source.Secret()
+	assert.Nil(t, err)
+	assert.Equal(t, "rotated", secret)
+}
+
+func TestCredentialRotation(t *testing.T) {
+	simular.Activate()
+	defer simular.DeactivateAndReset()
+
+	simular.RegisterStubRequests(
+		simular.NewStubRequest(
+			http.MethodGet,
+			"https://market.big-iot.org/accessToken?clientId=id&clientSecret=CF72ABfRTqy1FQS1zBaevw%3D%3D",
+			simular.NewStringResponder(200, "1234abcd"),
+		),
+		simular.NewStubRequest(
+			http.MethodGet,
+			"https://market.big-iot.org/accessToken?clientId=id&clientSecret=dGVzdHNlY3JldDI%3D",
+			simular.NewStringResponder(200, "5678efgh"),
+		),
+	)
+
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	clock := &settableClock{t: now}
+	source := &rotatingCredentials{secret: testSecret}
+
+	p, err := bigiot.NewProvider(
+		"id",
+		"ignored",
+		bigiot.WithCredentialSource(source),
+		bigiot.WithRotationGracePeriod(30*time.Second),
+		bigiot.WithClock(clock),
+	)
+	assert.Nil(t, err)
+
+	oldToken := signToken(t, testSecret, "Provider-Offering", "Consumer-Query", now)
+	newToken := signToken(t, rotatedSecret, "Provider-Offering", "Consumer-Query", now)
+
+	assert.Nil(t, p.Authenticate())
+
+	_, err = p.ValidateToken(oldToken)
+	assert.Nil(t, err)
+
+	_, err = p.ValidateToken(newToken)
+	assert.NotNil(t, err)
+
+	source.rotate(rotatedSecret)
+
+	assert.Nil(t, p.Authenticate())
+
+	// tokens signed with either secret are accepted within the grace period
+	clock.set(now.Add(20 * time.Second))
+
+	_, err = p.ValidateToken(oldToken)
+	assert.Nil(t, err)
+
+	offeringID, err := p.ValidateToken(newToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "Provider-Offering", offeringID)
+
+	// only tokens signed with the new secret are accepted afterwards
+	clock.set(now.Add(40 * time.Second))
+
+	_, err = p.ValidateToken(oldToken)
+	assert.NotNil(t, err)
+
+	_, err = p.ValidateToken(newToken)
+	assert.Nil(t, err)
+
+	assert.Nil(t, simular.AllStubsCalled())
+}
+
+func TestCredentialRotationThroughInvalidSecret(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	clock := &settableClock{t: now}
+	source := &rotatingCredentials{secret: testSecret}
+
+	p, err := bigiot.NewProvider(
+		"id",
+		"",
+		bigiot.WithCredentialSource(source),
+		bigiot.WithRotationGracePeriod(30*time.Second),
+		bigiot.WithClock(clock),
+	)
+	assert.Nil(t, err)
+
+	oldToken := signToken(t, testSecret, "Provider-Offering", "Consumer-Query", now)
+	newToken := signToken(t, rotatedSecret, "Provider-Offering", "Consumer-Query", now)
+
+	_, err = p.ValidateToken(oldToken)
+	assert.Nil(t, err)
+
+	// the old secret remains valid for the grace period after rotating to a
+	// secret which can't be decoded
+	source.rotate("not base64!")
+	clock.set(now.Add(5 * time.Second))
+
+	_, err = p.ValidateToken(oldToken)
+	assert.Nil(t, err)
+
+	// once the invalid secret is replaced, the secret before it is no longer
+	// accepted, even though its grace period hasn't passed
+	source.rotate(rotatedSecret)
+	clock.set(now.Add(10 * time.Second))
+
+	_, err = p.ValidateToken(oldToken)
+	assert.NotNil(t, err)
+
+	_, err = p.ValidateToken(newToken)
+	assert.Nil(t, err)
+}
+
+func TestCredentialRotationWithoutGracePeriod(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	source := &rotatingCredentials{secret: testSecret}
+
+	p, err := bigiot.NewProvider(
+		"id",
+		"",
+		bigiot.WithCredentialSource(source),
+		bigiot.WithRotationGracePeriod(0),
+		bigiot.WithClock(&settableClock{t: now}),
+	)
+	assert.Nil(t, err)
+
+	oldToken := signToken(t, testSecret, "Provider-Offering", "Consumer-Query", now)
+
+	_, err = p.ValidateToken(oldToken)
+	assert.Nil(t, err)
+
+	source.rotate(rotatedSecret)
+
+	_, err = p.ValidateToken(oldToken)
+	assert.NotNil(t, err)
+
+	// secrets which can't be decoded are reported when validating tokens
+	source.rotate("not base64!")
+
+	_, err = p.ValidateToken(oldToken)
+	assert.NotNil(t, err)
+
+	_, err = bigiot.NewProvider("id", "", bigiot.WithRotationGracePeriod(-time.Second))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"sort"
+)
+
+// Currency is a type alias for string used to represent currencies. Values are
+// ISO 4217 alphabetic codes.
+type Currency string
+
+const (
+	// EUR is a currency instance representing the Euro currency
+	EUR Currency = "EUR"
+
+	// CHF is a currency instance representing the Swiss Franc currency
+	CHF Currency = "CHF"
+
+	// GBP is a currency instance representing the Pound Sterling currency
+	GBP Currency = "GBP"
+
+	// USD is a currency instance representing the US Dollar currency
+	USD Currency = "USD"
+
+	// UnknownCurrency represents a currency returned by the marketplace which
+	// this library does not recognise.
+	UnknownCurrency Currency = unknownValue
+)
+
+// currencies maps every active ISO 4217 currency code to its minor unit
+// exponent, i.e. the number of decimal places conventionally used for amounts
+// in that currency. Precious metals and other codes without a minor unit are
+// not included.
+var currencies = map[Currency]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+	"AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3,
+	"BIF": 0, "BMD": 2, "BND": 2, "BOB": 2, "BOV": 2, "BRL": 2, "BSD": 2,
+	"BTN": 2, "BWP": 2, "BYN": 2, "BZD": 2, "CAD": 2, "CDF": 2, "CHE": 2,
+	"CHF": 2, "CHW": 2, "CLF": 4, "CLP": 0, "CNY": 2, "COP": 2, "COU": 2,
+	"CRC": 2, "CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2,
+	"DZD": 2, "EGP": 2, "ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2,
+	"GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2,
+	"GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2, "JOD": 3, "JPY": 0,
+	"KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0, "KWD": 3,
+	"KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2,
+	"LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2,
+	"MOP": 2, "MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MXV": 2,
+	"MYR": 2, "MZN": 2, "NAD": 2, "NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2,
+	"NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2, "PGK": 2, "PHP": 2, "PKR": 2,
+	"PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2, "RUB": 2, "RWF": 0,
+	"SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2, "SHP": 2,
+	"SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2,
+	"SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2,
+	"TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "USN": 2,
+	"UYI": 0, "UYU": 2, "UYW": 4, "UZS": 2, "VED": 2, "VES": 2, "VND": 0,
+	"VUV": 0, "WST": 2, "XAF": 0, "XCD": 2, "XCG": 2, "XOF": 0, "XPF": 0,
+	"YER": 2, "ZAR": 2, "ZMW": 2, "ZWG": 2,
+}
+
+// currencyEnum describes the valid values of Currency.
+var currencyEnum = enum{
+	name: "currency",
+	valid: func(code string) bool {
+		_, ok := currencies[Currency(code)]
+		return ok
+	},
+}
+
+// ParseCurrency returns the Currency for the given ISO 4217 code, or
+// UnknownCurrency and an error if the code is not a known currency.
+func ParseCurrency(code string) (Currency, error) {
+	c, err := currencyEnum.parse(code)
+	return Currency(c), err
+}
+
+// AllCurrencies returns every valid Currency, ordered by code.
+func AllCurrencies() []Currency {
+	all := make([]Currency, 0, len(currencies))
+	for c := range currencies {
+		all = append(all, c)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i] < all[j]
+	})
+
+	return all
+}
+
+// String is an implementation of Stringer for our Currency type.
+func (c Currency) String() string {
+	return string(c)
+}
+
+// Validate returns an error if the currency is not a known ISO 4217 currency.
+func (c Currency) Validate() error {
+	_, err := currencyEnum.parse(string(c))
+	return err
+}
+
+// Exponent returns the ISO 4217 minor unit exponent of the currency, i.e. the
+// number of decimal places conventionally used when formatting amounts. For
+// example EUR has an exponent of 2, JPY 0 and BHD 3. Unknown currencies return
+// 0.
+func (c Currency) Exponent() int {
+	return currencies[c]
+}
+
+// MarshalText is our implementation of encoding.TextMarshaler. Unset values
+// and UnknownCurrency are accepted so that they round-trip, while an error
+// is returned for any other value which is not a known currency code.
+func (c Currency) MarshalText() ([]byte, error) {
+	return currencyEnum.marshalText(string(c))
+}
+
+// UnmarshalText is our implementation of encoding.TextUnmarshaler. Empty text
+// and "UNKNOWN" are accepted so that they round-trip, while an error is
+// returned for any other text which is not a known currency code.
+func (c *Currency) UnmarshalText(b []byte) error {
+	v, err := currencyEnum.unmarshalText(b)
+	if err != nil {
+		return err
+	}
+
+	*c = Currency(v)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+func TestParseCurrency(t *testing.T) {
+	c, err := bigiot.ParseCurrency("CHF")
+	assert.Nil(t, err)
+	assert.Equal(t, bigiot.CHF, c)
+
+	for _, code := range []string{"", "chf", "XXX", "EURO"} {
+		_, err = bigiot.ParseCurrency(code)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestCurrencyExponent(t *testing.T) {
+	assert.Equal(t, 2, bigiot.EUR.Exponent())
+	assert.Equal(t, 2, bigiot.GBP.Exponent())
+	assert.Equal(t, 0, bigiot.Currency("JPY").Exponent())
+	assert.Equal(t, 3, bigiot.Currency("KWD").Exponent())
+	assert.Equal(t, 0, bigiot.Currency("XXX").Exponent())
+}
+
+func TestCurrencyText(t *testing.T) {
+	var price struct {
+		Currency bigiot.Currency `json:"currency"`
+	}
+
+	err := json.Unmarshal([]byte(`{"currency":"GBP"}`), &price)
+	assert.Nil(t, err)
+	assert.Equal(t, bigiot.GBP, price.Currency)
+
+	b, err := json.Marshal(price)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"currency":"GBP"}`, string(b))
+
+	err = json.Unmarshal([]byte(`{"currency":"ABC"}`), &price)
+	assert.NotNil(t, err)
+
+	price.Currency = bigiot.Currency("ABC")
+	_, err = json.Marshal(price)
+	assert.NotNil(t, err)
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "1.50 CHF", bigiot.Money{Amount: bigiot.MustParseAmount("1.5"), Currency: bigiot.CHF}.String())
+	assert.Equal(t, "0.0001 EUR", bigiot.Money{Amount: bigiot.MustParseAmount("0.0001"), Currency: bigiot.EUR}.String())
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+// OfferingQuery describes the offerings a consumer is looking for, and is used
+// to filter offerings returned by the marketplace. Criteria which are left
+// unset match every offering.
+type OfferingQuery struct {
+	// Category matches offerings with exactly this category.
+	Category string
+
+	// SpatialExtent matches offerings whose spatial extent intersects it.
+	// Offerings without a spatial extent don't match.
+	SpatialExtent *SpatialExtent
+
+	// TemporalExtent matches offerings whose temporal extent overlaps it, so
+	// a consumer looking for historical data can ask for offerings with data
+	// for a given period. Either end may be left open. Offerings without a
+	// temporal extent don't match.
+	TemporalExtent *TemporalExtent
+}
+
+// Matches returns true if the offering satisfies every criterion of the query.
+func (q *OfferingQuery) Matches(o *Offering) bool {
+	if q.Category != "" && o.Category != q.Category {
+		return false
+	}
+
+	if q.SpatialExtent != nil && (o.SpatialExtent == nil || !q.SpatialExtent.Intersects(o.SpatialExtent)) {
+		return false
+	}
+
+	if q.TemporalExtent != nil && (o.TemporalExtent == nil || !q.TemporalExtent.Overlaps(o.TemporalExtent)) {
+		return false
+	}
+
+	return true
+}
+
+// Filter returns the offerings which match the query, in their original order.
+//
+// Example:
+//		query := &bigiot.OfferingQuery{
+//			Category: "urn:big-iot:TrafficFlowCategory",
+//			TemporalExtent: &bigiot.TemporalExtent{
+//				From: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+//				To:   time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC),
+//			},
+//		}
+//
+//		historical := query.Filter(offerings)
+func (q *OfferingQuery) Filter(offerings []Offering) []Offering {
+	var matched []Offering
+
+	for i := range offerings {
+		if q.Matches(&offerings[i]) {
+			matched = append(matched, offerings[i])
+		}
+	}
+
+	return matched
+}
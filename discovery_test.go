@@ -0,0 +1,94 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+func TestOfferingQueryTemporalExtent(t *testing.T) {
+	jan := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	offerings := []bigiot.Offering{
+		{ID: "january", TemporalExtent: &bigiot.TemporalExtent{From: jan, To: feb}},
+		{ID: "march", TemporalExtent: &bigiot.TemporalExtent{From: mar, To: mar.AddDate(0, 1, 0)}},
+		{ID: "since-february", TemporalExtent: &bigiot.TemporalExtent{From: feb}},
+		{ID: "live"},
+	}
+
+	testcases := []struct {
+		label    string
+		extent   *bigiot.TemporalExtent
+		expected []string
+	}{
+		{"no criterion", nil, []string{"january", "march", "since-february", "live"}},
+		{"mid january", &bigiot.TemporalExtent{From: jan.AddDate(0, 0, 14), To: jan.AddDate(0, 0, 15)}, []string{"january"}},
+		{"up to february", &bigiot.TemporalExtent{To: feb}, []string{"january", "since-february"}},
+		{"from march", &bigiot.TemporalExtent{From: mar}, []string{"march", "since-february"}},
+		{"before january", &bigiot.TemporalExtent{To: jan.AddDate(0, 0, -1)}, nil},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			query := &bigiot.OfferingQuery{TemporalExtent: testcase.extent}
+
+			var ids []string
+			for _, offering := range query.Filter(offerings) {
+				ids = append(ids, offering.ID)
+			}
+
+			assert.Equal(t, testcase.expected, ids)
+		})
+	}
+}
+
+func TestOfferingQueryMatches(t *testing.T) {
+	offering := &bigiot.Offering{
+		Category: "urn:big-iot:ParkingSpaces",
+		SpatialExtent: &bigiot.SpatialExtent{
+			BoundingBox: &bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: 13.1, Lat: 52.3},
+				Location2: bigiot.Location{Lng: 13.7, Lat: 52.7},
+			},
+		},
+		TemporalExtent: &bigiot.TemporalExtent{From: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	berlin := &bigiot.SpatialExtent{
+		BoundingBox: &bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: 13.3, Lat: 52.4},
+			Location2: bigiot.Location{Lng: 13.5, Lat: 52.6},
+		},
+	}
+
+	barcelona := &bigiot.SpatialExtent{
+		BoundingBox: &bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: 2.0, Lat: 41.3},
+			Location2: bigiot.Location{Lng: 2.3, Lat: 41.5},
+		},
+	}
+
+	assert.True(t, (&bigiot.OfferingQuery{}).Matches(offering))
+	assert.True(t, (&bigiot.OfferingQuery{Category: "urn:big-iot:ParkingSpaces", SpatialExtent: berlin}).Matches(offering))
+	assert.False(t, (&bigiot.OfferingQuery{Category: "urn:big-iot:Weather"}).Matches(offering))
+	assert.False(t, (&bigiot.OfferingQuery{SpatialExtent: barcelona}).Matches(offering))
+	assert.False(t, (&bigiot.OfferingQuery{SpatialExtent: berlin}).Matches(&bigiot.Offering{}))
+}
@@ -25,6 +25,10 @@ Implemented functionality:
 	* delete or unregister an offering from the marketplace
 	* reactivating offerings from the marketplace
 	* validating tokens presented by offering subscribers
+	* serving offerings via the BIG IoT lib access interface
+	* streaming records to subscribers of WebSocket offerings
+	* receiving records from WebSocket offerings as a consumer
+	* accessing offerings as a consumer, with optional budgets
 
 Planned functionality:
   * discovering an offering in the marketplace
@@ -91,7 +95,7 @@ description of the offering.
 		},
 		Price: bigiot.Price{
 			Money: bigiot.Money{
-				Amount:   0.01,
+				Amount:   bigiot.MustParseAmount("0.01"),
 				Currency: bigiot.EUR,
 			},
 			PricingModel: bigiot.PerAccess,
@@ -134,6 +138,17 @@ can use the following method on the Provider:
 		panic(err) // handle error properly
 	}
 
+To pause an offering, for example during a maintenance window, it can be
+deactivated without deleting it, so that it keeps its ID and subscriptions
+until it is activated again:
+
+	offering, err = provider.DeactivateOffering(context.Background(), offering.ID)
+	if err != nil {
+		panic(err) // handle error properly
+	}
+
+	offering.IsActive(nil) // false
+
 To validate incoming tokens presented by a consumer, we expose a
 ValidateToken method. This takes as input a token string encoded via the
 compact JWT serialization form, and returns either the ID of the offering
@@ -143,5 +158,21 @@ being requested, or an error should the incoming token be invalid.
 	if err != nil {
 		panic(err) // handle error properly
 	}
+
+To serve an offering registered with an AccessInterfaceType of BIGIoTLib, a
+provider can use the AccessHandler method. This returns an http.Handler that
+validates the consumer's token, decodes the declared inputs, calls the
+supplied function, and encodes the returned records according to the
+declared outputs.
+
+	handler := provider.AccessHandler(offering.ID, addOfferingInput, func(ctx context.Context, req bigiot.AccessRequest) (bigiot.AccessResponse, error) {
+		return bigiot.AccessResponse{
+			Records: []bigiot.Record{
+				{"geoCoordinates": lookup(req.Inputs["longitude"], req.Inputs["latitude"])},
+			},
+		}, nil
+	})
+
+	http.Handle("/parking", handler)
 */
 package bigiot
@@ -14,6 +14,79 @@
 
 package bigiot
 
+import (
+	"github.com/pkg/errors"
+)
+
+// unknownValue is the value of the Unknown instance of each of our enumerated
+// types. Values received from the marketplace which we don't recognise are
+// mapped to this rather than causing decoding to fail.
+const unknownValue = "UNKNOWN"
+
+// enum describes one of our enumerated string types, and implements the
+// parsing and text marshalling shared by all of them. Both the zero value and
+// the Unknown value of a type are accepted when marshalling and unmarshalling
+// text, so that unset values and values decoded from marketplace responses
+// round-trip, while any other unrecognised value is rejected.
+type enum struct {
+	// name is the name of the type used in error messages, e.g. "license".
+	name string
+
+	// values is every valid value of the type, in order.
+	values []string
+
+	// valid reports whether a value is valid. If nil, values is searched
+	// instead.
+	valid func(string) bool
+}
+
+// parse returns s if it is a valid value of the type, or the Unknown value and
+// an error if not.
+func (e enum) parse(s string) (string, error) {
+	if e.valid != nil && e.valid(s) {
+		return s, nil
+	}
+
+	for _, v := range e.values {
+		if v == s {
+			return s, nil
+		}
+	}
+
+	return unknownValue, errors.Errorf("unknown %s %q", e.name, s)
+}
+
+// orUnknown returns s if it is a valid value of the type, or the Unknown value
+// if not. It is used when decoding marketplace responses.
+func (e enum) orUnknown(s string) string {
+	v, _ := e.parse(s)
+	return v
+}
+
+// marshalText returns the text of s. The zero value and the Unknown value are
+// accepted along with every valid value of the type, and an error is returned
+// for anything else.
+func (e enum) marshalText(s string) ([]byte, error) {
+	_, err := e.unmarshalText([]byte(s))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(s), nil
+}
+
+// unmarshalText returns the value of the type for b. Empty text and the
+// Unknown value are accepted along with every valid value of the type, and an
+// error is returned for anything else.
+func (e enum) unmarshalText(b []byte) (string, error) {
+	s := string(b)
+	if s == "" || s == unknownValue {
+		return s, nil
+	}
+
+	return e.parse(s)
+}
+
 // EndpointType represents the type of an Endpoint accessible via the BIGIoT
 // Marketplace.
 type EndpointType string
@@ -38,6 +111,54 @@ func (e EndpointType) String() string {
 	return string(e)
 }
 
+// UnknownEndpointType represents an endpoint type returned by the marketplace
+// which this library does not recognise.
+const UnknownEndpointType EndpointType = unknownValue
+
+// endpointTypeEnum describes the valid values of EndpointType.
+var endpointTypeEnum = enum{
+	name:   "endpoint type",
+	values: []string{string(HTTPGet), string(HTTPPost), string(WebSocket)},
+}
+
+// AllEndpointTypes returns every valid EndpointType.
+func AllEndpointTypes() []EndpointType {
+	all := make([]EndpointType, len(endpointTypeEnum.values))
+	for i, v := range endpointTypeEnum.values {
+		all[i] = EndpointType(v)
+	}
+
+	return all
+}
+
+// ParseEndpointType returns the EndpointType matching the given string, or
+// UnknownEndpointType and an error if the string is not a valid endpoint type.
+func ParseEndpointType(s string) (EndpointType, error) {
+	v, err := endpointTypeEnum.parse(s)
+	return EndpointType(v), err
+}
+
+// MarshalText is our implementation of encoding.TextMarshaler. Unset values
+// and UnknownEndpointType are accepted so that they round-trip, while an error
+// is returned for any other value which is not a valid endpoint type.
+func (e EndpointType) MarshalText() ([]byte, error) {
+	return endpointTypeEnum.marshalText(string(e))
+}
+
+// UnmarshalText is our implementation of encoding.TextUnmarshaler. Empty text
+// and "UNKNOWN" are accepted so that they round-trip, while an error is
+// returned for any other text which is not a valid endpoint type.
+func (e *EndpointType) UnmarshalText(b []byte) error {
+	v, err := endpointTypeEnum.unmarshalText(b)
+	if err != nil {
+		return err
+	}
+
+	*e = EndpointType(v)
+
+	return nil
+}
+
 // AccessInterfaceType is a type used to represent the type of an access
 // interface. This can be one of BIGIOT_LIB or EXTERNAL.
 type AccessInterfaceType string
@@ -55,6 +176,55 @@ func (a AccessInterfaceType) String() string {
 	return string(a)
 }
 
+// UnknownAccessInterfaceType represents an access interface type returned by
+// the marketplace which this library does not recognise.
+const UnknownAccessInterfaceType AccessInterfaceType = unknownValue
+
+// accessInterfaceTypeEnum describes the valid values of AccessInterfaceType.
+var accessInterfaceTypeEnum = enum{
+	name:   "access interface type",
+	values: []string{string(BIGIoTLib), string(External)},
+}
+
+// AllAccessInterfaceTypes returns every valid AccessInterfaceType.
+func AllAccessInterfaceTypes() []AccessInterfaceType {
+	all := make([]AccessInterfaceType, len(accessInterfaceTypeEnum.values))
+	for i, v := range accessInterfaceTypeEnum.values {
+		all[i] = AccessInterfaceType(v)
+	}
+
+	return all
+}
+
+// ParseAccessInterfaceType returns the AccessInterfaceType matching the given string, or
+// UnknownAccessInterfaceType and an error if the string is not a valid access interface type.
+func ParseAccessInterfaceType(s string) (AccessInterfaceType, error) {
+	v, err := accessInterfaceTypeEnum.parse(s)
+	return AccessInterfaceType(v), err
+}
+
+// MarshalText is our implementation of encoding.TextMarshaler. Unset values
+// and UnknownAccessInterfaceType are accepted so that they round-trip, while an
+// error is returned for any other value which is not a valid access interface
+// type.
+func (a AccessInterfaceType) MarshalText() ([]byte, error) {
+	return accessInterfaceTypeEnum.marshalText(string(a))
+}
+
+// UnmarshalText is our implementation of encoding.TextUnmarshaler. Empty text
+// and "UNKNOWN" are accepted so that they round-trip, while an error is
+// returned for any other text which is not a valid access interface type.
+func (a *AccessInterfaceType) UnmarshalText(b []byte) error {
+	v, err := accessInterfaceTypeEnum.unmarshalText(b)
+	if err != nil {
+		return err
+	}
+
+	*a = AccessInterfaceType(v)
+
+	return nil
+}
+
 // License is a type alias for string used to represent the license being
 // applied to an offering.
 type License string
@@ -76,6 +246,54 @@ func (l License) String() string {
 	return string(l)
 }
 
+// UnknownLicense represents a license returned by the marketplace which this
+// library does not recognise.
+const UnknownLicense License = unknownValue
+
+// licenseEnum describes the valid values of License.
+var licenseEnum = enum{
+	name:   "license",
+	values: []string{string(CreativeCommons), string(OpenDataLicense), string(NonCommercialDataLicense)},
+}
+
+// AllLicenses returns every valid License.
+func AllLicenses() []License {
+	all := make([]License, len(licenseEnum.values))
+	for i, v := range licenseEnum.values {
+		all[i] = License(v)
+	}
+
+	return all
+}
+
+// ParseLicense returns the License matching the given string, or
+// UnknownLicense and an error if the string is not a valid license.
+func ParseLicense(s string) (License, error) {
+	v, err := licenseEnum.parse(s)
+	return License(v), err
+}
+
+// MarshalText is our implementation of encoding.TextMarshaler. Unset values
+// and UnknownLicense are accepted so that they round-trip, while an error
+// is returned for any other value which is not a valid license.
+func (l License) MarshalText() ([]byte, error) {
+	return licenseEnum.marshalText(string(l))
+}
+
+// UnmarshalText is our implementation of encoding.TextUnmarshaler. Empty text
+// and "UNKNOWN" are accepted so that they round-trip, while an error is
+// returned for any other text which is not a valid license.
+func (l *License) UnmarshalText(b []byte) error {
+	v, err := licenseEnum.unmarshalText(b)
+	if err != nil {
+		return err
+	}
+
+	*l = License(v)
+
+	return nil
+}
+
 // PricingModel is a type alias for string used to represent pricing models to
 // be applied to BIGIoT offerings.
 type PricingModel string
@@ -99,15 +317,50 @@ func (p PricingModel) String() string {
 	return string(p)
 }
 
-// Currency is a type alias for string used to represent currencies
-type Currency string
+// UnknownPricingModel represents a pricing model returned by the marketplace
+// which this library does not recognise.
+const UnknownPricingModel PricingModel = unknownValue
 
-const (
-	// EUR is a currency instance representing the Euro currency
-	EUR Currency = "EUR"
-)
+// pricingModelEnum describes the valid values of PricingModel.
+var pricingModelEnum = enum{
+	name:   "pricing model",
+	values: []string{string(Free), string(PerMonth), string(PerAccess), string(PerByte)},
+}
+
+// AllPricingModels returns every valid PricingModel.
+func AllPricingModels() []PricingModel {
+	all := make([]PricingModel, len(pricingModelEnum.values))
+	for i, v := range pricingModelEnum.values {
+		all[i] = PricingModel(v)
+	}
+
+	return all
+}
+
+// ParsePricingModel returns the PricingModel matching the given string, or
+// UnknownPricingModel and an error if the string is not a valid pricing model.
+func ParsePricingModel(s string) (PricingModel, error) {
+	v, err := pricingModelEnum.parse(s)
+	return PricingModel(v), err
+}
+
+// MarshalText is our implementation of encoding.TextMarshaler. Unset values
+// and UnknownPricingModel are accepted so that they round-trip, while an error
+// is returned for any other value which is not a valid pricing model.
+func (p PricingModel) MarshalText() ([]byte, error) {
+	return pricingModelEnum.marshalText(string(p))
+}
+
+// UnmarshalText is our implementation of encoding.TextUnmarshaler. Empty text
+// and "UNKNOWN" are accepted so that they round-trip, while an error is
+// returned for any other text which is not a valid pricing model.
+func (p *PricingModel) UnmarshalText(b []byte) error {
+	v, err := pricingModelEnum.unmarshalText(b)
+	if err != nil {
+		return err
+	}
+
+	*p = PricingModel(v)
 
-// String is an implementation of Stringer for our Currency type.
-func (c Currency) String() string {
-	return string(c)
+	return nil
 }
@@ -1,6 +1,7 @@
 package bigiot_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,3 +18,129 @@ func TestAccessInterfaceTypeStringer(t *testing.T) {
 	assert.Equal(t, "BIGIOT_LIB", bigiot.BIGIoTLib.String())
 	assert.Equal(t, "EXTERNAL", bigiot.External.String())
 }
+
+func TestParseEnums(t *testing.T) {
+	e, err := bigiot.ParseEndpointType("HTTP_POST")
+	assert.Nil(t, err)
+	assert.Equal(t, bigiot.HTTPPost, e)
+
+	a, err := bigiot.ParseAccessInterfaceType("EXTERNAL")
+	assert.Nil(t, err)
+	assert.Equal(t, bigiot.External, a)
+
+	l, err := bigiot.ParseLicense("CREATIVE_COMMONS")
+	assert.Nil(t, err)
+	assert.Equal(t, bigiot.CreativeCommons, l)
+
+	p, err := bigiot.ParsePricingModel("PER_BYTE")
+	assert.Nil(t, err)
+	assert.Equal(t, bigiot.PerByte, p)
+
+	e, err = bigiot.ParseEndpointType("http_get")
+	assert.NotNil(t, err)
+	assert.Equal(t, bigiot.UnknownEndpointType, e)
+
+	a, err = bigiot.ParseAccessInterfaceType("UNKNOWN")
+	assert.NotNil(t, err)
+	assert.Equal(t, bigiot.UnknownAccessInterfaceType, a)
+
+	l, err = bigiot.ParseLicense("GPL")
+	assert.NotNil(t, err)
+	assert.Equal(t, "unknown license \"GPL\"", err.Error())
+	assert.Equal(t, bigiot.UnknownLicense, l)
+
+	p, err = bigiot.ParsePricingModel("")
+	assert.NotNil(t, err)
+	assert.Equal(t, bigiot.UnknownPricingModel, p)
+
+	c, err := bigiot.ParseCurrency("DOLLARS")
+	assert.NotNil(t, err)
+	assert.Equal(t, bigiot.UnknownCurrency, c)
+}
+
+func TestAllEnums(t *testing.T) {
+	assert.Equal(t, []bigiot.EndpointType{bigiot.HTTPGet, bigiot.HTTPPost, bigiot.WebSocket}, bigiot.AllEndpointTypes())
+	assert.Equal(t, []bigiot.AccessInterfaceType{bigiot.BIGIoTLib, bigiot.External}, bigiot.AllAccessInterfaceTypes())
+	assert.Equal(t, []bigiot.License{bigiot.CreativeCommons, bigiot.OpenDataLicense, bigiot.NonCommercialDataLicense}, bigiot.AllLicenses())
+	assert.Equal(t, []bigiot.PricingModel{bigiot.Free, bigiot.PerMonth, bigiot.PerAccess, bigiot.PerByte}, bigiot.AllPricingModels())
+
+	currencies := bigiot.AllCurrencies()
+	assert.Contains(t, currencies, bigiot.EUR)
+	assert.Contains(t, currencies, bigiot.CHF)
+	assert.NotContains(t, currencies, bigiot.UnknownCurrency)
+
+	// modifying the returned slice must not affect later calls
+	licenses := bigiot.AllLicenses()
+	licenses[0] = bigiot.UnknownLicense
+	assert.Equal(t, bigiot.CreativeCommons, bigiot.AllLicenses()[0])
+}
+
+func TestEnumText(t *testing.T) {
+	type config struct {
+		EndpointType        bigiot.EndpointType        `json:"endpointType"`
+		AccessInterfaceType bigiot.AccessInterfaceType `json:"accessInterfaceType"`
+		License             bigiot.License             `json:"license"`
+		PricingModel        bigiot.PricingModel        `json:"pricingModel"`
+		Currency            bigiot.Currency            `json:"currency"`
+	}
+
+	valid := `{"endpointType":"WEBSOCKET","accessInterfaceType":"BIGIOT_LIB","license":"OPEN_DATA_LICENSE","pricingModel":"PER_ACCESS","currency":"EUR"}`
+
+	var c config
+	err := json.Unmarshal([]byte(valid), &c)
+	assert.Nil(t, err)
+	assert.Equal(t, config{
+		EndpointType:        bigiot.WebSocket,
+		AccessInterfaceType: bigiot.BIGIoTLib,
+		License:             bigiot.OpenDataLicense,
+		PricingModel:        bigiot.PerAccess,
+		Currency:            bigiot.EUR,
+	}, c)
+
+	b, err := json.Marshal(c)
+	assert.Nil(t, err)
+	assert.Equal(t, valid, string(b))
+
+	invalid := []string{
+		`{"endpointType":"FTP"}`,
+		`{"accessInterfaceType":"INTERNAL"}`,
+		`{"license":"GPL"}`,
+		`{"pricingModel":"PER_YEAR"}`,
+		`{"currency":"DOLLARS"}`,
+	}
+
+	for _, input := range invalid {
+		err = json.Unmarshal([]byte(input), &c)
+		assert.NotNil(t, err, input)
+	}
+
+	// unset and unknown values round-trip
+	for _, v := range []config{
+		{},
+		{
+			EndpointType:        bigiot.UnknownEndpointType,
+			AccessInterfaceType: bigiot.UnknownAccessInterfaceType,
+			License:             bigiot.UnknownLicense,
+			PricingModel:        bigiot.UnknownPricingModel,
+			Currency:            bigiot.UnknownCurrency,
+		},
+	} {
+		b, err = json.Marshal(v)
+		assert.Nil(t, err)
+
+		var decoded config
+		err = json.Unmarshal(b, &decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, v, decoded)
+	}
+
+	b, err = json.Marshal(config{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"endpointType":"","accessInterfaceType":"","license":"","pricingModel":"","currency":""}`, string(b))
+
+	// values set directly must still be valid
+	_, err = json.Marshal(config{PricingModel: "PER_YEAR"})
+	assert.NotNil(t, err)
+	_, err = json.Marshal(config{Currency: "DOLLARS"})
+	assert.NotNil(t, err)
+}
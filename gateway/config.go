@@ -0,0 +1,219 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thingful/bigiot"
+)
+
+const (
+	// DefaultReportInterval is the default interval at which usage is reported
+	// to the marketplace.
+	DefaultReportInterval = time.Minute
+
+	// DefaultUpstreamTimeout is the default timeout of requests made to the
+	// upstream API.
+	DefaultUpstreamTimeout = 10 * time.Second
+
+	// DefaultMaxResponseSize is the default maximum size in bytes of a
+	// response from the upstream API.
+	DefaultMaxResponseSize = bigiot.DefaultMaxResponseSize
+)
+
+// Config describes an offering served by a Gateway, and how requests for it
+// are mapped to the upstream API.
+type Config struct {
+	// Offering is the description of the offering registered on the
+	// marketplace. Its Activation is managed by the Gateway, so any value set
+	// here is ignored.
+	Offering bigiot.OfferingDescription `json:"offering"`
+
+	// Upstream is the URL of the upstream API. Inputs sent by consumers are
+	// added to its query string.
+	Upstream string `json:"upstream"`
+
+	// Headers are sent with every request to the upstream API, for example to
+	// pass an API key.
+	Headers map[string]string `json:"headers"`
+
+	// Inputs maps the names of the offering's Inputs to the names of upstream
+	// query parameters. Inputs not listed are sent using their own name.
+	Inputs map[string]string `json:"inputs"`
+
+	// Records is the path of the array of records within the JSON returned by
+	// the upstream API, as dot separated keys (e.g. "data.items"). If empty,
+	// the response must be the array of records itself, or a single record.
+	Records string `json:"records"`
+
+	// Outputs maps the names of the offering's Outputs to the path of their
+	// values within each upstream record, as dot separated keys. Outputs not
+	// listed are read from the key with their own name.
+	Outputs map[string]string `json:"outputs"`
+
+	// ActivationDuration is how long each activation of the offering lasts.
+	// The offering is re-activated when half of the duration has passed. If
+	// zero, bigiot.DefaultActivationDuration is used.
+	ActivationDuration Duration `json:"activationDuration"`
+
+	// ReportInterval is the interval at which usage is reported to the
+	// marketplace. If zero, DefaultReportInterval is used.
+	ReportInterval Duration `json:"reportInterval"`
+
+	// Timeout is the timeout of requests to the upstream API. If zero,
+	// DefaultUpstreamTimeout is used.
+	Timeout Duration `json:"timeout"`
+
+	// MaxResponseSize is the maximum size in bytes of a response from the
+	// upstream API. Larger responses are rejected, and the consumer receives a
+	// 502 Bad Gateway. If zero, DefaultMaxResponseSize is used.
+	MaxResponseSize int64 `json:"maxResponseSize"`
+}
+
+// Duration is a time.Duration which is read from JSON as a string such as
+// "10m" or "1h30m".
+type Duration time.Duration
+
+// UnmarshalJSON is our implementation of json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return errors.Wrap(err, "durations must be strings such as \"10m\"")
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.Wrap(err, "invalid duration")
+	}
+
+	*d = Duration(duration)
+
+	return nil
+}
+
+// MarshalJSON is our implementation of json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ReadConfig reads a JSON encoded Config from r and validates it. The offering
+// description is encoded using the names of the fields of
+// bigiot.OfferingDescription.
+//
+// Example:
+//		{
+//			"offering": {
+//				"LocalID": "ParkingSpaces",
+//				"Name": "Parking spaces",
+//				"Category": "urn:big-iot:ParkingSpaceCategory",
+//				"Inputs": [{"Name": "city", "RdfURI": "schema:addressLocality", "Required": true}],
+//				"Outputs": [{"Name": "free", "RdfURI": "bigiot:freeSpaces", "Datatype": "xsd:integer"}],
+//				"Endpoints": [{"URI": "https://gateway.example.com/parking", "EndpointType": "HTTP_GET", "AccessInterfaceType": "BIGIOT_LIB"}],
+//				"License": "OPEN_DATA_LICENSE",
+//				"Price": {"PricingModel": "FREE"}
+//			},
+//			"upstream": "https://internal.example.com/api/parking",
+//			"inputs": {"city": "town"},
+//			"records": "data.spaces",
+//			"outputs": {"free": "availability.free"}
+//		}
+func ReadConfig(r io.Reader) (*Config, error) {
+	config := &Config{}
+
+	err := json.NewDecoder(r).Decode(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding gateway config")
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadConfig reads a Config from the JSON file at the given path.
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening gateway config")
+	}
+	defer f.Close()
+
+	return ReadConfig(f)
+}
+
+// Validate checks the config is complete, and that the inputs and outputs it
+// maps are declared by the offering.
+func (c *Config) Validate() error {
+	if c.Offering.LocalID == "" {
+		return errors.New("offering must have a LocalID")
+	}
+
+	if c.Upstream == "" {
+		return errors.New("missing upstream url")
+	}
+
+	u, err := url.Parse(c.Upstream)
+	if err != nil {
+		return errors.Wrap(err, "invalid upstream url")
+	}
+
+	if !u.IsAbs() {
+		return errors.Errorf("upstream url %s is not absolute", c.Upstream)
+	}
+
+	for name := range c.Inputs {
+		if !hasField(c.Offering.Inputs, name) {
+			return errors.Errorf("mapped input %s is not an input of the offering", name)
+		}
+	}
+
+	for name := range c.Outputs {
+		if !hasField(c.Offering.Outputs, name) {
+			return errors.Errorf("mapped output %s is not an output of the offering", name)
+		}
+	}
+
+	if c.ActivationDuration < 0 || c.ReportInterval < 0 || c.Timeout < 0 {
+		return errors.New("durations must not be negative")
+	}
+
+	if c.MaxResponseSize < 0 {
+		return errors.New("maxResponseSize must not be negative")
+	}
+
+	return nil
+}
+
+// hasField returns true if fields contains a field with the given name.
+func hasField(fields []bigiot.DataField, name string) bool {
+	for _, field := range fields {
+		if field.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
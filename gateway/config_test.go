@@ -0,0 +1,100 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/gateway"
+)
+
+const testConfig = `{
+	"offering": {
+		"LocalID": "ParkingSpaces",
+		"Name": "Parking spaces",
+		"Category": "urn:big-iot:ParkingSpaceCategory",
+		"Inputs": [{"Name": "city", "RdfURI": "schema:addressLocality", "Datatype": "xsd:string", "Required": true}],
+		"Outputs": [
+			{"Name": "id", "RdfURI": "schema:identifier", "Datatype": "xsd:string"},
+			{"Name": "free", "RdfURI": "bigiot:freeSpaces", "Datatype": "xsd:integer"}
+		],
+		"Endpoints": [{"URI": "https://gateway.example.com/parking", "EndpointType": "HTTP_GET", "AccessInterfaceType": "BIGIOT_LIB"}],
+		"License": "OPEN_DATA_LICENSE",
+		"Price": {"PricingModel": "FREE"}
+	},
+	"upstream": "https://internal.example.com/api/parking?format=json",
+	"headers": {"X-Api-Key": "key"},
+	"inputs": {"city": "town"},
+	"records": "data.spaces",
+	"outputs": {"free": "availability.free"},
+	"activationDuration": "1h",
+	"timeout": "5s",
+	"maxResponseSize": 1048576
+}`
+
+func TestReadConfig(t *testing.T) {
+	config, err := gateway.ReadConfig(strings.NewReader(testConfig))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "ParkingSpaces", config.Offering.LocalID)
+	assert.Equal(t, []bigiot.DataField{
+		{Name: "city", RdfURI: "schema:addressLocality", Datatype: bigiot.XSDString, Required: true},
+	}, config.Offering.Inputs)
+	assert.Equal(t, bigiot.HTTPGet, config.Offering.Endpoints[0].EndpointType)
+	assert.Equal(t, bigiot.Free, config.Offering.Price.PricingModel)
+	assert.Equal(t, "data.spaces", config.Records)
+	assert.Equal(t, map[string]string{"free": "availability.free"}, config.Outputs)
+	assert.Equal(t, gateway.Duration(time.Hour), config.ActivationDuration)
+	assert.Equal(t, gateway.Duration(0), config.ReportInterval)
+	assert.Equal(t, gateway.Duration(5*time.Second), config.Timeout)
+	assert.Equal(t, int64(1048576), config.MaxResponseSize)
+
+	b, err := json.Marshal(config.Timeout)
+	assert.Nil(t, err)
+	assert.Equal(t, `"5s"`, string(b))
+}
+
+func TestReadConfigInvalid(t *testing.T) {
+	testcases := []struct {
+		name    string
+		replace []string
+	}{
+		{"invalid json", []string{`"offering": {`, `"offering": [`}},
+		{"missing local id", []string{`"LocalID": "ParkingSpaces",`, ""}},
+		{"missing upstream", []string{`"upstream": "https://internal.example.com/api/parking?format=json",`, ""}},
+		{"relative upstream", []string{`https://internal.example.com`, ""}},
+		{"undeclared input", []string{`{"city": "town"}`, `{"town": "town"}`}},
+		{"undeclared output", []string{`{"free": "availability.free"}`, `{"spaces": "availability.free"}`}},
+		{"invalid duration", []string{`"1h"`, `"an hour"`}},
+		{"numeric duration", []string{`"1h"`, `3600`}},
+		{"negative duration", []string{`"1h"`, `"-1h"`}},
+		{"negative max response size", []string{`1048576`, `-1`}},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			input := strings.Replace(testConfig, testcase.replace[0], testcase.replace[1], 1)
+			assert.NotEqual(t, testConfig, input)
+
+			_, err := gateway.ReadConfig(strings.NewReader(input))
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -0,0 +1,467 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gateway exposes an existing HTTP API returning JSON as a BIG IoT
+// offering, without writing any code beyond a Config.
+//
+// A Gateway registers the offering on the marketplace and keeps it activated,
+// serves the offering's endpoint using the BIG IoT lib access protocol
+// (validating the tokens presented by consumers), forwards the inputs sent by
+// consumers to the upstream API as query parameters, maps the fields of the
+// upstream response to the offering's outputs, and records and reports the
+// usage of each consumer.
+//
+// Example:
+//		provider, _ := bigiot.NewProvider(providerID, providerSecret)
+//		config, _ := gateway.LoadConfig("parking.json")
+//
+//		gw, _ := gateway.New(provider, *config)
+//
+//		http.Handle("/parking", gw)
+//		go http.ListenAndServe(":8080", nil)
+//
+//		err := gw.Run(ctx)
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thingful/bigiot"
+)
+
+// Gateway serves an offering described by a Config by proxying requests to an
+// upstream API. It implements http.Handler, responding with 503 Service
+// Unavailable until the offering has been registered.
+type Gateway struct {
+	mu       sync.RWMutex
+	provider *bigiot.Provider
+	config   Config
+	upstream *url.URL
+	store    bigiot.AccountingStore
+	client   *http.Client
+	errFn    func(error)
+	offering *bigiot.Offering
+	handler  http.Handler
+}
+
+// Option is a functional configuration type used to configure optional
+// behaviour of a Gateway.
+type Option func(*Gateway)
+
+// WithAccountingStore is an Option allowing a caller to specify the store in
+// which the usage of the offering is recorded, for example a
+// bigiot.FileAccountingStore so that unreported usage survives a restart. The
+// default is a bigiot.MemoryAccountingStore.
+func WithAccountingStore(store bigiot.AccountingStore) Option {
+	return func(g *Gateway) {
+		g.store = store
+	}
+}
+
+// WithHTTPClient is an Option allowing a caller to specify the http.Client used
+// to make requests to the upstream API. The timeout of requests is set by the
+// Config rather than the client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(g *Gateway) {
+		g.client = client
+	}
+}
+
+// WithErrorHandler is an Option allowing a caller to be notified of errors
+// which occur while running the Gateway, such as failing to re-activate the
+// offering or to report usage, or requests to the upstream API failing.
+func WithErrorHandler(fn func(error)) Option {
+	return func(g *Gateway) {
+		g.errFn = fn
+	}
+}
+
+// New returns a Gateway serving the offering described by config on behalf of
+// the given provider, or an error if the config is invalid.
+func New(provider *bigiot.Provider, config Config, options ...Option) (*Gateway, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	upstream, err := url.Parse(config.Upstream)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid upstream url")
+	}
+
+	g := &Gateway{
+		provider: provider,
+		config:   config,
+		upstream: upstream,
+		store:    bigiot.NewMemoryAccountingStore(),
+		client:   http.DefaultClient,
+	}
+
+	for _, opt := range options {
+		opt(g)
+	}
+
+	return g, nil
+}
+
+// Register registers the offering on the marketplace, activating it for the
+// configured ActivationDuration, after which the Gateway starts serving
+// requests for it. The provider must already have been authenticated. Most
+// callers should use Run instead, which also keeps the offering activated.
+func (g *Gateway) Register(ctx context.Context) (*bigiot.Offering, error) {
+	description := g.config.Offering
+	description.Activation = &bigiot.Activation{
+		Status:   true,
+		Duration: g.activationDuration(),
+	}
+
+	offering, err := g.provider.RegisterOffering(ctx, &description)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := g.provider.AccessHandler(
+		offering.ID,
+		&g.config.Offering,
+		g.access,
+		bigiot.WithAccounting(g.store),
+	)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.offering = offering
+	g.handler = handler
+
+	return offering, nil
+}
+
+// Offering returns the offering registered on the marketplace, or nil if the
+// offering has not been registered yet.
+func (g *Gateway) Offering() *bigiot.Offering {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.offering
+}
+
+// ServeHTTP is our implementation of http.Handler.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.RLock()
+	handler := g.handler
+	g.mu.RUnlock()
+
+	if handler == nil {
+		http.Error(w, "offering not registered", http.StatusServiceUnavailable)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Run authenticates the provider and registers the offering, then keeps the
+// offering activated by re-activating it when half of its ActivationDuration
+// has passed, and reports usage every ReportInterval. If re-activating the
+// offering or reporting usage fails, the provider is authenticated again in
+// case its access token has expired, and the error is passed to the error
+// handler. Run blocks until the context is cancelled, at which point it returns
+// the context's error, or returns an error immediately if the offering can't
+// be registered.
+func (g *Gateway) Run(ctx context.Context) error {
+	err := g.provider.Authenticate()
+	if err != nil {
+		return errors.Wrap(err, "error authenticating with marketplace")
+	}
+
+	offering, err := g.Register(ctx)
+	if err != nil {
+		return err
+	}
+
+	activation := time.NewTicker(g.activationDuration() / 2)
+	defer activation.Stop()
+
+	report := time.NewTicker(g.reportInterval())
+	defer report.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-activation.C:
+			g.retry(ctx, func() error {
+				_, err := g.provider.ActivateOffering(ctx, &bigiot.ActivateOffering{
+					ID:       offering.ID,
+					Duration: g.activationDuration(),
+				})
+				return err
+			})
+		case <-report.C:
+			g.retry(ctx, func() error {
+				return g.provider.ReportUsage(ctx, g.store)
+			})
+		}
+	}
+}
+
+// retry calls fn, authenticating again and retrying once if it fails, and
+// passes any error to the error handler unless the context has been cancelled.
+func (g *Gateway) retry(ctx context.Context, fn func() error) {
+	err := fn()
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+
+	if authErr := g.provider.Authenticate(); authErr == nil {
+		err = fn()
+	}
+
+	if err != nil && ctx.Err() == nil && g.errFn != nil {
+		g.errFn(err)
+	}
+}
+
+// activationDuration returns the configured activation duration, or the
+// default.
+func (g *Gateway) activationDuration() time.Duration {
+	if g.config.ActivationDuration == 0 {
+		return bigiot.DefaultActivationDuration
+	}
+
+	return time.Duration(g.config.ActivationDuration)
+}
+
+// reportInterval returns the configured report interval, or the default.
+func (g *Gateway) reportInterval() time.Duration {
+	if g.config.ReportInterval == 0 {
+		return DefaultReportInterval
+	}
+
+	return time.Duration(g.config.ReportInterval)
+}
+
+// timeout returns the configured upstream timeout, or the default.
+func (g *Gateway) timeout() time.Duration {
+	if g.config.Timeout == 0 {
+		return DefaultUpstreamTimeout
+	}
+
+	return time.Duration(g.config.Timeout)
+}
+
+// maxResponseSize returns the configured maximum upstream response size, or
+// the default.
+func (g *Gateway) maxResponseSize() int64 {
+	if g.config.MaxResponseSize == 0 {
+		return DefaultMaxResponseSize
+	}
+
+	return g.config.MaxResponseSize
+}
+
+// access is our bigiot.AccessFunc. It proxies the request to the upstream API,
+// passing any error to the error handler as the consumer only receives a
+// generic error.
+func (g *Gateway) access(ctx context.Context, req bigiot.AccessRequest) (bigiot.AccessResponse, error) {
+	resp, err := g.proxy(ctx, req)
+	if err != nil && g.errFn != nil {
+		g.errFn(err)
+	}
+
+	return resp, err
+}
+
+// proxy makes a request to the upstream API and maps the records it returns to
+// the offering's outputs.
+func (g *Gateway) proxy(ctx context.Context, req bigiot.AccessRequest) (bigiot.AccessResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout())
+	defer cancel()
+
+	body, err := g.fetch(ctx, req.Inputs)
+	if err != nil {
+		return bigiot.AccessResponse{}, err
+	}
+
+	values, err := lookupRecords(body, g.config.Records)
+	if err != nil {
+		return bigiot.AccessResponse{}, badGateway(err)
+	}
+
+	records := make([]bigiot.Record, 0, len(values))
+
+	for i, value := range values {
+		record, err := g.mapRecord(value)
+		if err != nil {
+			return bigiot.AccessResponse{}, badGateway(errors.Wrapf(err, "error mapping upstream record %d", i))
+		}
+
+		records = append(records, record)
+	}
+
+	return bigiot.AccessResponse{Records: records}, nil
+}
+
+// fetch makes a request to the upstream API with the given inputs, and returns
+// the decoded JSON response.
+func (g *Gateway) fetch(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+	u := *g.upstream
+	query := u.Query()
+
+	for _, input := range g.config.Offering.Inputs {
+		value, ok := inputs[input.Name]
+		if !ok {
+			continue
+		}
+
+		param := input.Name
+		if mapped, ok := g.config.Inputs[input.Name]; ok {
+			param = mapped
+		}
+
+		query.Set(param, bigiot.FormatInput(value))
+	}
+
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating upstream request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	for name, value := range g.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := g.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, badGateway(errors.Wrap(err, "error requesting upstream api"))
+	}
+
+	limit := g.maxResponseSize()
+
+	defer func() {
+		// drain the body so the connection can be reused, unless it is too
+		// large to be worth reading
+		_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, limit))
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, badGateway(errors.Errorf("upstream api responded with status %d", resp.StatusCode))
+	}
+
+	// read one byte more than the limit so that we can tell it was exceeded
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, badGateway(errors.Wrap(err, "error reading upstream response"))
+	}
+
+	if int64(len(b)) > limit {
+		return nil, badGateway(errors.Errorf("upstream response exceeds the maximum size of %d bytes", limit))
+	}
+
+	var body interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	err = decoder.Decode(&body)
+	if err != nil {
+		return nil, badGateway(errors.Wrap(err, "error decoding upstream response"))
+	}
+
+	return body, nil
+}
+
+// badGateway returns a bigiot.BadGatewayError describing a failure of the
+// upstream API, so that the consumer receives a 502 Bad Gateway.
+func badGateway(err error) error {
+	return &bigiot.BadGatewayError{Reason: err.Error()}
+}
+
+// mapRecord converts a single upstream record into a Record containing the
+// offering's outputs. Outputs which are absent from the upstream record are
+// returned as null.
+func (g *Gateway) mapRecord(value interface{}) (bigiot.Record, error) {
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, errors.New("upstream record is not an object")
+	}
+
+	record := make(bigiot.Record, len(g.config.Offering.Outputs))
+
+	for _, output := range g.config.Offering.Outputs {
+		path := output.Name
+		if mapped, ok := g.config.Outputs[output.Name]; ok {
+			path = mapped
+		}
+
+		record[output.Name], _ = lookup(value, path)
+	}
+
+	return record, nil
+}
+
+// lookupRecords returns the records found at the given path within the
+// upstream response, which may be an array of records or a single record.
+func lookupRecords(body interface{}, path string) ([]interface{}, error) {
+	value, ok := lookup(body, path)
+	if !ok {
+		return nil, errors.Errorf("upstream response contains no records at %s", path)
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case map[string]interface{}:
+		return []interface{}{v}, nil
+	case nil:
+		return []interface{}{}, nil
+	default:
+		return nil, errors.New("upstream records are not an array or object")
+	}
+}
+
+// lookup returns the value at a path of dot separated keys within a decoded
+// JSON value. An empty path returns the value itself.
+func lookup(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
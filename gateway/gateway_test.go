@@ -0,0 +1,283 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway_test
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/gateway"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const testSecret = "CF72ABfRTqy1FQS1zBaevw=="
+
+// signToken creates a signed token in the form the marketplace issues to
+// consumers, valid for a minute either side of now.
+func signToken(t *testing.T, offeringID, subscriberID string) string {
+	t.Helper()
+
+	key, err := base64.StdEncoding.DecodeString(testSecret)
+	assert.Nil(t, err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: key}, nil)
+	assert.Nil(t, err)
+
+	now := time.Now()
+
+	cl := struct {
+		jwt.Claims
+		SubscribableID string `json:"subscribableId"`
+		SubscriberID   string `json:"subscriberId"`
+	}{
+		Claims: jwt.Claims{
+			Subject:   subscriberID + "==" + offeringID,
+			NotBefore: jwt.NewNumericDate(now.Add(-1 * time.Minute)),
+			Expiry:    jwt.NewNumericDate(now.Add(1 * time.Minute)),
+		},
+		SubscribableID: offeringID,
+		SubscriberID:   subscriberID,
+	}
+
+	tokenStr, err := jwt.Signed(signer).Claims(cl).CompactSerialize()
+	assert.Nil(t, err)
+
+	return tokenStr
+}
+
+// marketplace is a fake marketplace recording the operations called on it.
+type marketplace struct {
+	sync.Mutex
+	operations []string
+}
+
+// ServeHTTP is our implementation of http.Handler.
+func (m *marketplace) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/accessToken" {
+		w.Write([]byte("1234abcd"))
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+
+	for _, operation := range []string{"addOffering", "activateOffering", "trackAccesses"} {
+		if strings.Contains(string(body), "mutation "+operation) {
+			m.Lock()
+			m.operations = append(m.operations, operation)
+			m.Unlock()
+
+			w.Write([]byte(`{"data": {"` + operation + `": {"id": "Provider-ParkingSpaces"}}}`))
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+}
+
+// called returns true if the operation has been called.
+func (m *marketplace) called(operation string) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, op := range m.operations {
+		if op == operation {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eventually waits up to a second for condition to return true.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("condition not met")
+}
+
+func TestGateway(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key", r.Header.Get("X-Api-Key"))
+		assert.Equal(t, "json", r.URL.Query().Get("format"))
+
+		switch r.URL.Query().Get("town") {
+		case "Berlin":
+			w.Write([]byte(`{"data": {"spaces": [
+				{"id": "a", "availability": {"free": 3}},
+				{"id": "b", "availability": {"free": 12}},
+				{"id": "c"}
+			]}}`))
+		case "Hamburg":
+			w.Write([]byte(`{"data": {"spaces": {"id": "d", "availability": {"free": 1}}}}`))
+		case "Munich":
+			w.Write([]byte(`{"data": {"spaces": [`))
+			for i := 0; i < 1000; i++ {
+				w.Write([]byte(`{"id": "e", "availability": {"free": 1}},`))
+			}
+			w.Write([]byte(`{"id": "e", "availability": {"free": 1}}]}}`))
+		case "Cologne":
+			// drop the connection without responding
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if assert.Nil(t, err) {
+				conn.Close()
+			}
+		case "Bremen":
+			w.Write([]byte(`{"data": {"spaces": [`))
+		case "Dresden":
+			w.Write([]byte(`{"data": {}}`))
+		case "Leipzig":
+			w.Write([]byte(`{"data": {"spaces": ["f"]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	market := &marketplace{}
+	server := httptest.NewServer(market)
+	defer server.Close()
+
+	config, err := gateway.ReadConfig(strings.NewReader(testConfig))
+	assert.Nil(t, err)
+
+	config.Upstream = upstream.URL + "?format=json"
+	config.ActivationDuration = gateway.Duration(100 * time.Millisecond)
+	config.ReportInterval = gateway.Duration(50 * time.Millisecond)
+	config.MaxResponseSize = 4096
+
+	provider, err := bigiot.NewProvider("Provider", testSecret, bigiot.WithMarketplace(server.URL))
+	assert.Nil(t, err)
+
+	var (
+		mu     sync.Mutex
+		errors []error
+	)
+
+	gw, err := gateway.New(provider, *config, gateway.WithErrorHandler(func(err error) {
+		mu.Lock()
+		errors = append(errors, err)
+		mu.Unlock()
+	}))
+	assert.Nil(t, err)
+
+	token := signToken(t, "Provider-ParkingSpaces", "Consumer-Query")
+
+	get := func(city string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/parking?city="+city, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// requests are rejected until the offering is registered
+	assert.Equal(t, http.StatusServiceUnavailable, get("Berlin").Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- gw.Run(ctx)
+	}()
+
+	eventually(t, func() bool { return gw.Offering() != nil })
+	assert.Equal(t, "Provider-ParkingSpaces", gw.Offering().ID)
+
+	rec := get("Berlin")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"id": "a", "free": 3}, {"id": "b", "free": 12}, {"id": "c", "free": null}]`, rec.Body.String())
+
+	rec = get("Hamburg")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"id": "d", "free": 1}]`, rec.Body.String())
+
+	// failures of the upstream api are reported to the consumer as a bad gateway
+	failures := []struct {
+		city    string
+		message string
+	}{
+		{"Paris", "upstream api responded with status 404"},
+		{"Munich", "upstream response exceeds the maximum size of 4096 bytes"},
+		{"Cologne", "error requesting upstream api"},
+		{"Bremen", "error decoding upstream response"},
+		{"Dresden", "upstream response contains no records at data.spaces"},
+		{"Leipzig", "error mapping upstream record 0"},
+	}
+
+	for _, failure := range failures {
+		rec = get(failure.city)
+		assert.Equal(t, http.StatusBadGateway, rec.Code, failure.city)
+		assert.JSONEq(t, `{"errors": [{"message": "error requesting upstream service"}]}`, rec.Body.String())
+	}
+
+	eventually(t, func() bool { return market.called("activateOffering") && market.called("trackAccesses") })
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if assert.Len(t, errors, len(failures)) {
+		for i, failure := range failures {
+			assert.Contains(t, errors[i].Error(), failure.message)
+		}
+	}
+}
+
+func TestGatewayRegistrationFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/accessToken" {
+			w.Write([]byte("1234abcd"))
+			return
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"data": null, "errors": [{"message": "invalid offering"}]}`))
+	}))
+	defer server.Close()
+
+	config, err := gateway.ReadConfig(strings.NewReader(testConfig))
+	assert.Nil(t, err)
+
+	provider, err := bigiot.NewProvider("Provider", testSecret, bigiot.WithMarketplace(server.URL))
+	assert.Nil(t, err)
+
+	gw, err := gateway.New(provider, *config)
+	assert.Nil(t, err)
+
+	err = gw.Run(context.Background())
+	assert.NotNil(t, err)
+	assert.Nil(t, gw.Offering())
+}
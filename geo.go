@@ -0,0 +1,395 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Normalize returns the bounding box with Location1 set to its south-west
+// corner and Location2 set to its north-east corner. Longitudes are wrapped
+// into the range [-180, 180]. If the two corners are more than 180 degrees of
+// longitude apart, the box is assumed to be the smaller box crossing the
+// antimeridian, in which case the western longitude of the normalized box is
+// greater than the eastern one.
+func (b BoundingBox) Normalize() BoundingBox {
+	lng1 := wrapLng(b.Location1.Lng)
+	lng2 := wrapLng(b.Location2.Lng)
+
+	west, east := math.Min(lng1, lng2), math.Max(lng1, lng2)
+	if east-west > 180 {
+		west, east = east, west
+	}
+
+	return BoundingBox{
+		Location1: Location{Lng: west, Lat: math.Min(b.Location1.Lat, b.Location2.Lat)},
+		Location2: Location{Lng: east, Lat: math.Max(b.Location1.Lat, b.Location2.Lat)},
+	}
+}
+
+// CrossesAntimeridian returns true if the normalized bounding box crosses the
+// antimeridian, i.e. the 180th meridian.
+func (b BoundingBox) CrossesAntimeridian() bool {
+	n := b.Normalize()
+	return n.Location1.Lng > n.Location2.Lng
+}
+
+// Contains returns true if the location lies within or on the edge of the
+// bounding box. Locations with a non-finite coordinate or a latitude outside
+// [-90, 90] are never contained.
+func (b BoundingBox) Contains(l Location) bool {
+	if !l.valid() {
+		return false
+	}
+
+	n := b.Normalize()
+
+	if l.Lat < n.Location1.Lat || l.Lat > n.Location2.Lat {
+		return false
+	}
+
+	lng := wrapLng(l.Lng)
+
+	if n.Location1.Lng > n.Location2.Lng {
+		return lng >= n.Location1.Lng || lng <= n.Location2.Lng
+	}
+
+	return lng >= n.Location1.Lng && lng <= n.Location2.Lng
+}
+
+// Intersects returns true if the two bounding boxes overlap or touch.
+func (b BoundingBox) Intersects(other BoundingBox) bool {
+	for _, x := range b.split() {
+		for _, y := range other.split() {
+			if x.Location1.Lng <= y.Location2.Lng && y.Location1.Lng <= x.Location2.Lng &&
+				x.Location1.Lat <= y.Location2.Lat && y.Location1.Lat <= x.Location2.Lat {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// split returns the normalized bounding box, split into two boxes at the
+// antimeridian if it crosses it.
+func (b BoundingBox) split() []BoundingBox {
+	n := b.Normalize()
+
+	if n.Location1.Lng <= n.Location2.Lng {
+		return []BoundingBox{n}
+	}
+
+	return []BoundingBox{
+		{
+			Location1: n.Location1,
+			Location2: Location{Lng: 180, Lat: n.Location2.Lat},
+		},
+		{
+			Location1: Location{Lng: -180, Lat: n.Location1.Lat},
+			Location2: n.Location2,
+		},
+	}
+}
+
+// GeoJSONBBox returns the bounding box in GeoJSON form, i.e. as [west, south,
+// east, north]. As described in RFC 7946, west is greater than east for boxes
+// crossing the antimeridian.
+func (b BoundingBox) GeoJSONBBox() []float64 {
+	n := b.Normalize()
+	return []float64{n.Location1.Lng, n.Location1.Lat, n.Location2.Lng, n.Location2.Lat}
+}
+
+// BoundingBoxFromGeoJSON returns the BoundingBox for the given GeoJSON bbox
+// array, which must be of the form [west, south, east, north].
+func BoundingBoxFromGeoJSON(bbox []float64) (*BoundingBox, error) {
+	if len(bbox) != 4 {
+		return nil, errors.Errorf("invalid GeoJSON bbox, expected 4 values, got %d", len(bbox))
+	}
+
+	if bbox[1] > bbox[3] {
+		return nil, errors.New("invalid GeoJSON bbox, south is greater than north")
+	}
+
+	return &BoundingBox{
+		Location1: Location{Lng: bbox[0], Lat: bbox[1]},
+		Location2: Location{Lng: bbox[2], Lat: bbox[3]},
+	}, nil
+}
+
+// Bounds returns the bounding box of the extent. This is the BoundingBox if
+// set, otherwise the envelope of the Polygon. If the extent has neither, nil is
+// returned.
+func (a *SpatialExtent) Bounds() *BoundingBox {
+	if a.BoundingBox != nil {
+		return a.BoundingBox
+	}
+
+	if len(a.Polygon) == 0 {
+		return nil
+	}
+
+	ring := unwrapRing(a.Polygon)
+
+	west, east := ring[0].Lng, ring[0].Lng
+	south, north := ring[0].Lat, ring[0].Lat
+
+	for _, l := range ring[1:] {
+		west, east = math.Min(west, l.Lng), math.Max(east, l.Lng)
+		south, north = math.Min(south, l.Lat), math.Max(north, l.Lat)
+	}
+
+	if east-west >= 360 {
+		west, east = -180, 180
+	}
+
+	return &BoundingBox{
+		Location1: Location{Lng: wrapLng(west), Lat: south},
+		Location2: Location{Lng: wrapLng(east), Lat: north},
+	}
+}
+
+// Contains returns true if the location lies within the extent. If a Polygon is
+// set the location is tested against it, otherwise against the BoundingBox.
+// Polygons with fewer than three points enclose no area, so are ignored. An
+// extent without either (i.e. only a City) contains no locations, as we have
+// no geometry to test against.
+func (a *SpatialExtent) Contains(l Location) bool {
+	if len(a.Polygon) >= 3 {
+		return polygonContains(unwrapRing(a.Polygon), l)
+	}
+
+	if a.BoundingBox != nil {
+		return a.BoundingBox.Contains(l)
+	}
+
+	return false
+}
+
+// hasGeometry returns true if the extent has a geometry Contains can test
+// locations against.
+func (a *SpatialExtent) hasGeometry() bool {
+	return len(a.Polygon) >= 3 || a.BoundingBox != nil
+}
+
+// Intersects returns true if the bounds of the two extents overlap. Extents
+// without any geometry never intersect.
+func (a *SpatialExtent) Intersects(other *SpatialExtent) bool {
+	x, y := a.Bounds(), other.Bounds()
+	if x == nil || y == nil {
+		return false
+	}
+
+	return x.Intersects(*y)
+}
+
+// geoJSON is the unexported type we use to encode and decode the subset of
+// GeoJSON we support: Features, and Polygon geometries.
+type geoJSON struct {
+	Type        string            `json:"type"`
+	BBox        []float64         `json:"bbox,omitempty"`
+	Geometry    *geoJSON          `json:"geometry,omitempty"`
+	Coordinates [][][]float64     `json:"coordinates,omitempty"`
+	Properties  map[string]string `json:"properties,omitempty"`
+}
+
+// MarshalGeoJSON returns the extent as a GeoJSON Feature. The geometry of the
+// feature is the Polygon if set, otherwise the BoundingBox as a polygon, and
+// the city is included in the properties of the feature.
+func (a *SpatialExtent) MarshalGeoJSON() ([]byte, error) {
+	feature := geoJSON{
+		Type: "Feature",
+	}
+
+	if a.City != "" {
+		feature.Properties = map[string]string{"city": a.City}
+	}
+
+	ring := a.Polygon
+	if len(ring) == 0 && a.BoundingBox != nil {
+		n := a.BoundingBox.Normalize()
+		ring = []Location{
+			n.Location1,
+			{Lng: n.Location2.Lng, Lat: n.Location1.Lat},
+			n.Location2,
+			{Lng: n.Location1.Lng, Lat: n.Location2.Lat},
+		}
+	}
+
+	if bounds := a.Bounds(); bounds != nil {
+		feature.BBox = bounds.GeoJSONBBox()
+	}
+
+	if len(ring) > 0 {
+		coordinates := make([][]float64, 0, len(ring)+1)
+		for _, l := range ring {
+			coordinates = append(coordinates, []float64{l.Lng, l.Lat})
+		}
+
+		if ring[0] != ring[len(ring)-1] {
+			coordinates = append(coordinates, []float64{ring[0].Lng, ring[0].Lat})
+		}
+
+		feature.Geometry = &geoJSON{
+			Type:        "Polygon",
+			Coordinates: [][][]float64{coordinates},
+		}
+	}
+
+	b, err := json.Marshal(feature)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling GeoJSON")
+	}
+
+	return b, nil
+}
+
+// SpatialExtentFromGeoJSON returns a SpatialExtent for the given GeoJSON
+// document, which must be either a Feature or a Polygon geometry. A Polygon
+// geometry is returned as the Polygon of the extent, while a Feature without
+// a geometry but with a bbox is returned as a BoundingBox. Polygons with holes
+// are not supported. The city is read from the "city" property of a Feature.
+func SpatialExtentFromGeoJSON(b []byte) (*SpatialExtent, error) {
+	var doc geoJSON
+
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling GeoJSON")
+	}
+
+	extent := &SpatialExtent{}
+
+	geometry := &doc
+
+	switch doc.Type {
+	case "Feature":
+		extent.City = doc.Properties["city"]
+		geometry = doc.Geometry
+
+		if geometry == nil {
+			if doc.BBox == nil {
+				return nil, errors.New("GeoJSON feature has neither a geometry nor a bbox")
+			}
+
+			extent.BoundingBox, err = BoundingBoxFromGeoJSON(doc.BBox)
+			if err != nil {
+				return nil, err
+			}
+
+			return extent, nil
+		}
+	case "Polygon":
+	default:
+		return nil, errors.Errorf("unsupported GeoJSON type %q", doc.Type)
+	}
+
+	if geometry.Type != "Polygon" {
+		return nil, errors.Errorf("unsupported GeoJSON geometry type %q", geometry.Type)
+	}
+
+	if len(geometry.Coordinates) != 1 {
+		return nil, errors.New("GeoJSON polygon must have exactly one ring, holes are not supported")
+	}
+
+	for _, position := range geometry.Coordinates[0] {
+		if len(position) < 2 {
+			return nil, errors.New("invalid GeoJSON position")
+		}
+		extent.Polygon = append(extent.Polygon, Location{Lng: position[0], Lat: position[1]})
+	}
+
+	// GeoJSON rings are closed, we store the distinct vertices only
+	if n := len(extent.Polygon); n > 1 && extent.Polygon[0] == extent.Polygon[n-1] {
+		extent.Polygon = extent.Polygon[:n-1]
+	}
+
+	if len(extent.Polygon) < 3 {
+		return nil, errors.New("GeoJSON polygon must have at least 3 distinct positions")
+	}
+
+	return extent, nil
+}
+
+// valid returns true if the location has finite coordinates and a latitude
+// within [-90, 90]. Longitudes outside [-180, 180] are valid, and are wrapped
+// when tested.
+func (l Location) valid() bool {
+	return isFinite(l.Lat) && isFinite(l.Lng) && l.Lat >= -90 && l.Lat <= 90
+}
+
+// wrapLng wraps a longitude into the range [-180, 180].
+func wrapLng(lng float64) float64 {
+	if lng >= -180 && lng <= 180 {
+		return lng
+	}
+
+	lng = math.Mod(lng+180, 360)
+	if lng < 0 {
+		lng += 360
+	}
+
+	return lng - 180
+}
+
+// unwrapRing returns a copy of the ring with longitudes adjusted so that no
+// edge spans more than 180 degrees, allowing rings crossing the antimeridian to
+// be treated as if they were on a plane.
+func unwrapRing(ring []Location) []Location {
+	unwrapped := make([]Location, len(ring))
+	copy(unwrapped, ring)
+
+	for i := 1; i < len(unwrapped); i++ {
+		unwrapped[i].Lng = unwrapped[i-1].Lng + wrapLng(unwrapped[i].Lng-unwrapped[i-1].Lng)
+	}
+
+	return unwrapped
+}
+
+// polygonContains tests whether the location lies within the unwrapped ring
+// using ray casting. The location's longitude is shifted by a multiple of 360
+// degrees to fall within the span of the ring.
+func polygonContains(ring []Location, l Location) bool {
+	if !l.valid() {
+		return false
+	}
+
+	west := ring[0].Lng
+	for _, v := range ring[1:] {
+		west = math.Min(west, v.Lng)
+	}
+
+	if !isFinite(west) {
+		return false
+	}
+
+	lng := west + math.Mod(l.Lng-west, 360)
+	if lng < west {
+		lng += 360
+	}
+
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		vi, vj := ring[i], ring[j]
+		if (vi.Lat > l.Lat) != (vj.Lat > l.Lat) &&
+			lng < (vj.Lng-vi.Lng)*(l.Lat-vi.Lat)/(vj.Lat-vi.Lat)+vi.Lng {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
@@ -0,0 +1,318 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+func TestBoundingBoxNormalize(t *testing.T) {
+	testcases := []struct {
+		label    string
+		input    bigiot.BoundingBox
+		expected bigiot.BoundingBox
+	}{
+		{
+			label: "already normalized",
+			input: bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: 13.1, Lat: 52.3},
+				Location2: bigiot.Location{Lng: 13.7, Lat: 52.7},
+			},
+			expected: bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: 13.1, Lat: 52.3},
+				Location2: bigiot.Location{Lng: 13.7, Lat: 52.7},
+			},
+		},
+		{
+			label: "north-west and south-east corners",
+			input: bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: 13.7, Lat: 52.3},
+				Location2: bigiot.Location{Lng: 13.1, Lat: 52.7},
+			},
+			expected: bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: 13.1, Lat: 52.3},
+				Location2: bigiot.Location{Lng: 13.7, Lat: 52.7},
+			},
+		},
+		{
+			label: "crossing the antimeridian",
+			input: bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: -175, Lat: -15},
+				Location2: bigiot.Location{Lng: 175, Lat: -20},
+			},
+			expected: bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: 175, Lat: -20},
+				Location2: bigiot.Location{Lng: -175, Lat: -15},
+			},
+		},
+		{
+			label: "wrapped longitudes",
+			input: bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: 370, Lat: 0},
+				Location2: bigiot.Location{Lng: -350, Lat: 1},
+			},
+			expected: bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: 10, Lat: 0},
+				Location2: bigiot.Location{Lng: 10, Lat: 1},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			assert.Equal(t, testcase.expected, testcase.input.Normalize())
+		})
+	}
+}
+
+func TestBoundingBoxContains(t *testing.T) {
+	berlin := bigiot.BoundingBox{
+		Location1: bigiot.Location{Lng: 13.7, Lat: 52.7},
+		Location2: bigiot.Location{Lng: 13.1, Lat: 52.3},
+	}
+
+	assert.False(t, berlin.CrossesAntimeridian())
+	assert.True(t, berlin.Contains(bigiot.Location{Lng: 13.4, Lat: 52.5}))
+	assert.True(t, berlin.Contains(bigiot.Location{Lng: 13.1, Lat: 52.3}))
+	assert.False(t, berlin.Contains(bigiot.Location{Lng: 2.35, Lat: 48.85}))
+
+	fiji := bigiot.BoundingBox{
+		Location1: bigiot.Location{Lng: 177, Lat: -20},
+		Location2: bigiot.Location{Lng: -178, Lat: -15},
+	}
+
+	assert.True(t, fiji.CrossesAntimeridian())
+	assert.True(t, fiji.Contains(bigiot.Location{Lng: 179, Lat: -17}))
+	assert.True(t, fiji.Contains(bigiot.Location{Lng: -179, Lat: -17}))
+	assert.True(t, fiji.Contains(bigiot.Location{Lng: 181, Lat: -17}))
+	assert.False(t, fiji.Contains(bigiot.Location{Lng: 0, Lat: -17}))
+	assert.False(t, fiji.Contains(bigiot.Location{Lng: 179, Lat: -10}))
+}
+
+func TestBoundingBoxIntersects(t *testing.T) {
+	box := func(west, south, east, north float64) bigiot.BoundingBox {
+		return bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: west, Lat: south},
+			Location2: bigiot.Location{Lng: east, Lat: north},
+		}
+	}
+
+	assert.True(t, box(0, 0, 10, 10).Intersects(box(5, 5, 15, 15)))
+	assert.True(t, box(0, 0, 10, 10).Intersects(box(10, 10, 15, 15)))
+	assert.False(t, box(0, 0, 10, 10).Intersects(box(11, 0, 15, 10)))
+	assert.False(t, box(0, 0, 10, 10).Intersects(box(0, 11, 10, 15)))
+
+	// crossing the antimeridian
+	assert.True(t, box(170, 0, -170, 10).Intersects(box(-175, 5, -160, 15)))
+	assert.True(t, box(170, 0, -170, 10).Intersects(box(175, 5, 179, 15)))
+	assert.False(t, box(170, 0, -170, 10).Intersects(box(0, 0, 10, 10)))
+}
+
+func TestBoundingBoxGeoJSON(t *testing.T) {
+	bb := bigiot.BoundingBox{
+		Location1: bigiot.Location{Lng: -178, Lat: -15},
+		Location2: bigiot.Location{Lng: 177, Lat: -20},
+	}
+
+	assert.Equal(t, []float64{177, -20, -178, -15}, bb.GeoJSONBBox())
+
+	parsed, err := bigiot.BoundingBoxFromGeoJSON([]float64{177, -20, -178, -15})
+	assert.Nil(t, err)
+	assert.Equal(t, bb.Normalize(), *parsed)
+
+	_, err = bigiot.BoundingBoxFromGeoJSON([]float64{1, 2, 3})
+	assert.NotNil(t, err)
+
+	_, err = bigiot.BoundingBoxFromGeoJSON([]float64{0, 10, 1, 5})
+	assert.NotNil(t, err)
+}
+
+func TestSpatialExtentPolygon(t *testing.T) {
+	// a triangle
+	extent := bigiot.SpatialExtent{
+		City: "Somewhere",
+		Polygon: []bigiot.Location{
+			{Lng: 0, Lat: 0},
+			{Lng: 10, Lat: 0},
+			{Lng: 0, Lat: 10},
+		},
+	}
+
+	assert.Equal(t, &bigiot.BoundingBox{
+		Location1: bigiot.Location{Lng: 0, Lat: 0},
+		Location2: bigiot.Location{Lng: 10, Lat: 10},
+	}, extent.Bounds())
+
+	assert.True(t, extent.Contains(bigiot.Location{Lng: 2, Lat: 2}))
+	assert.False(t, extent.Contains(bigiot.Location{Lng: 8, Lat: 8}))
+
+	// a square crossing the antimeridian
+	crossing := bigiot.SpatialExtent{
+		Polygon: []bigiot.Location{
+			{Lng: 175, Lat: -20},
+			{Lng: -175, Lat: -20},
+			{Lng: -175, Lat: -10},
+			{Lng: 175, Lat: -10},
+		},
+	}
+
+	assert.Equal(t, &bigiot.BoundingBox{
+		Location1: bigiot.Location{Lng: 175, Lat: -20},
+		Location2: bigiot.Location{Lng: -175, Lat: -10},
+	}, crossing.Bounds())
+
+	assert.True(t, crossing.Contains(bigiot.Location{Lng: 179, Lat: -15}))
+	assert.True(t, crossing.Contains(bigiot.Location{Lng: -179, Lat: -15}))
+	assert.False(t, crossing.Contains(bigiot.Location{Lng: 0, Lat: -15}))
+
+	assert.True(t, crossing.Intersects(&bigiot.SpatialExtent{
+		BoundingBox: &bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: -178, Lat: -12},
+			Location2: bigiot.Location{Lng: -170, Lat: 0},
+		},
+	}))
+	assert.False(t, crossing.Intersects(&extent))
+
+	// no geometry
+	city := bigiot.SpatialExtent{City: "Berlin"}
+	assert.Nil(t, city.Bounds())
+	assert.False(t, city.Contains(bigiot.Location{Lng: 13.4, Lat: 52.5}))
+	assert.False(t, city.Intersects(&extent))
+}
+
+func TestSpatialExtentGeoJSON(t *testing.T) {
+	extent := &bigiot.SpatialExtent{
+		City: "Somewhere",
+		Polygon: []bigiot.Location{
+			{Lng: 0, Lat: 0},
+			{Lng: 10, Lat: 0},
+			{Lng: 0, Lat: 10},
+		},
+	}
+
+	b, err := extent.MarshalGeoJSON()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"type": "Feature",
+		"bbox": [0, 0, 10, 10],
+		"geometry": { "type": "Polygon", "coordinates": [[[0, 0], [10, 0], [0, 10], [0, 0]]] },
+		"properties": { "city": "Somewhere" }
+	}`, string(b))
+
+	parsed, err := bigiot.SpatialExtentFromGeoJSON(b)
+	assert.Nil(t, err)
+	assert.Equal(t, extent, parsed)
+
+	// bounding boxes are written as polygons
+	bb := &bigiot.SpatialExtent{
+		BoundingBox: &bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: 13.7, Lat: 52.7},
+			Location2: bigiot.Location{Lng: 13.1, Lat: 52.3},
+		},
+	}
+
+	b, err = bb.MarshalGeoJSON()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"type": "Feature",
+		"bbox": [13.1, 52.3, 13.7, 52.7],
+		"geometry": { "type": "Polygon", "coordinates": [[[13.1, 52.3], [13.7, 52.3], [13.7, 52.7], [13.1, 52.7], [13.1, 52.3]]] }
+	}`, string(b))
+
+	// a bare polygon geometry
+	parsed, err = bigiot.SpatialExtentFromGeoJSON([]byte(`{"type": "Polygon", "coordinates": [[[0, 0], [10, 0], [0, 10], [0, 0]]]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &bigiot.SpatialExtent{Polygon: extent.Polygon}, parsed)
+
+	// a feature with only a bbox
+	parsed, err = bigiot.SpatialExtentFromGeoJSON([]byte(`{"type": "Feature", "bbox": [13.1, 52.3, 13.7, 52.7], "geometry": null, "properties": { "city": "Berlin" }}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &bigiot.SpatialExtent{
+		City: "Berlin",
+		BoundingBox: &bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: 13.1, Lat: 52.3},
+			Location2: bigiot.Location{Lng: 13.7, Lat: 52.7},
+		},
+	}, parsed)
+
+	invalid := []string{
+		`not json`,
+		`{"type": "Point", "coordinates": [0, 0]}`,
+		`{"type": "Feature", "geometry": null}`,
+		`{"type": "Feature", "geometry": {"type": "LineString"}}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [10, 0], [0, 10], [0, 0]], [[1, 1], [2, 1], [1, 2], [1, 1]]]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [10, 0], [0, 0]]]}`,
+	}
+
+	for _, input := range invalid {
+		_, err = bigiot.SpatialExtentFromGeoJSON([]byte(input))
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestSpatialExtentContainsInvalidLocations(t *testing.T) {
+	polygon := bigiot.SpatialExtent{
+		Polygon: []bigiot.Location{
+			{Lng: 175, Lat: -20},
+			{Lng: -175, Lat: -20},
+			{Lng: -175, Lat: -10},
+			{Lng: 175, Lat: -10},
+		},
+	}
+
+	box := bigiot.SpatialExtent{
+		BoundingBox: &bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: -180, Lat: -90},
+			Location2: bigiot.Location{Lng: 180, Lat: 90},
+		},
+	}
+
+	invalid := []bigiot.Location{
+		{Lng: math.NaN(), Lat: -15},
+		{Lng: math.Inf(1), Lat: -15},
+		{Lng: math.Inf(-1), Lat: -15},
+		{Lng: 179, Lat: math.NaN()},
+		{Lng: 179, Lat: math.Inf(-1)},
+		{Lng: 179, Lat: -91},
+		{Lng: 179, Lat: 90.5},
+	}
+
+	for _, l := range invalid {
+		assert.False(t, polygon.Contains(l), "%v", l)
+		assert.False(t, box.Contains(l), "%v", l)
+	}
+
+	// very large longitudes are wrapped in a single step, so these return
+	// rather than looping for ever
+	assert.True(t, polygon.Contains(bigiot.Location{Lng: 179 + 360*1e6, Lat: -15}))
+	assert.False(t, polygon.Contains(bigiot.Location{Lng: 360 * 1e6, Lat: -15}))
+	polygon.Contains(bigiot.Location{Lng: 1e300, Lat: -15})
+	polygon.Contains(bigiot.Location{Lng: -1e300, Lat: -15})
+	assert.True(t, box.Contains(bigiot.Location{Lng: 1e300, Lat: -15}))
+
+	// polygons with non-finite vertices contain nothing, and don't hang
+	broken := bigiot.SpatialExtent{
+		Polygon: []bigiot.Location{
+			{Lng: 0, Lat: 0},
+			{Lng: math.Inf(1), Lat: 0},
+			{Lng: 0, Lat: 10},
+		},
+	}
+	assert.False(t, broken.Contains(bigiot.Location{Lng: 1, Lat: 1}))
+}
@@ -27,3 +27,20 @@ type query struct {
 type serializable interface {
 	serialize(clock Clock) string
 }
+
+// operation is implemented by the serializable types which are sent to the
+// marketplace as a complete query or mutation. It returns the name of the
+// operation, which we use when recording metrics.
+type operation interface {
+	operationName() string
+}
+
+// operationName returns the name of the operation for a serializable, or
+// "query" if it doesn't implement operation.
+func operationName(s serializable) string {
+	if op, ok := s.(operation); ok {
+		return op.operationName()
+	}
+
+	return "query"
+}
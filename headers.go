@@ -34,4 +34,8 @@ const (
 	// applicationJSON is a const value we use as a value for Accept or
 	// Content-Type headers
 	applicationJSON = "application/json"
+
+	// applicationJSONLD is a const value we use as a value for Accept or
+	// Content-Type headers when returning JSON-LD
+	applicationJSONLD = "application/ld+json"
 )
@@ -0,0 +1,532 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// XSDString is the RDF datatype for string values
+	XSDString = "xsd:string"
+
+	// XSDInteger is the RDF datatype for integer values. Values of this type are
+	// decoded as int64.
+	XSDInteger = "xsd:integer"
+
+	// XSDDecimal is the RDF datatype for decimal values. Values of this type are
+	// decoded as float64.
+	XSDDecimal = "xsd:decimal"
+
+	// XSDDouble is the RDF datatype for double precision floating point values.
+	// Values of this type are decoded as float64.
+	XSDDouble = "xsd:double"
+
+	// XSDBoolean is the RDF datatype for boolean values
+	XSDBoolean = "xsd:boolean"
+
+	// XSDDateTime is the RDF datatype for timestamps. Values of this type are
+	// decoded as time.Time, and may be sent either as RFC3339 strings or as
+	// epoch milliseconds.
+	XSDDateTime = "xsd:dateTime"
+)
+
+// knownDatatypes maps the RDF URIs of commonly used data fields to the
+// datatype of their values. This is used to decode inputs for which the
+// provider hasn't set an explicit Datatype.
+var knownDatatypes = map[string]string{
+	"schema:latitude":     XSDDouble,
+	"schema:longitude":    XSDDouble,
+	"schema:elevation":    XSDDouble,
+	"schema:geoRadius":    XSDDouble,
+	"schema:startDate":    XSDDateTime,
+	"schema:endDate":      XSDDateTime,
+	"schema:dateCreated":  XSDDateTime,
+	"schema:dateModified": XSDDateTime,
+	"schema:name":         XSDString,
+	"schema:description":  XSDString,
+	"schema:identifier":   XSDString,
+}
+
+// datatype returns the datatype of values for the DataField, either the
+// explicitly set Datatype or one inferred from the RdfURI. An empty string is
+// returned if the datatype isn't known.
+func (d *DataField) datatype() string {
+	if d.Datatype != "" {
+		return d.Datatype
+	}
+
+	return knownDatatypes[d.RdfURI]
+}
+
+// InputError describes a problem with a single input parameter sent by a
+// consumer.
+type InputError struct {
+	Name   string
+	Reason string
+}
+
+// Error is our implementation of the error interface.
+func (e InputError) Error() string {
+	return fmt.Sprintf("invalid input %s: %s", e.Name, e.Reason)
+}
+
+// InputErrors is a collection of InputError values returned when decoding the
+// inputs sent by a consumer fails. When returned from an AccessFunc, the
+// AccessHandler responds with a 400 Bad Request containing each error.
+type InputErrors []InputError
+
+// Error is our implementation of the error interface.
+func (e InputErrors) Error() string {
+	return strings.Join(e.messages(), "; ")
+}
+
+// messages returns the message for each of the contained errors.
+func (e InputErrors) messages() []string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return messages
+}
+
+// DecodeInputs extracts the values of the given inputs from the request. For
+// POST requests with a JSON body values are read from the top level JSON
+// object, otherwise they are read from the query string or form body. Values
+// are converted to the Go type matching the datatype of each input (see the
+// XSD constants), and any parameters not declared as inputs are ignored. If
+// any required inputs are missing, or any values can't be converted, an
+// InputErrors value is returned describing every problem found. The request
+// body is read without a size limit, so callers should wrap it with
+// http.MaxBytesReader as AccessHandler does.
+func DecodeInputs(r *http.Request, inputs []DataField) (map[string]interface{}, error) {
+	raw, err := rawInputs(r, inputs)
+	if err != nil {
+		return nil, InputErrors{{Name: "body", Reason: err.Error()}}
+	}
+
+	var inputErrs InputErrors
+	values := make(map[string]interface{}, len(raw))
+
+	for _, input := range inputs {
+		v, ok := raw[input.Name]
+		if !ok {
+			if input.Required {
+				inputErrs = append(inputErrs, InputError{Name: input.Name, Reason: "missing required input"})
+			}
+			continue
+		}
+
+		coerced, err := coerce(v, input.datatype())
+		if err != nil {
+			inputErrs = append(inputErrs, InputError{Name: input.Name, Reason: err.Error()})
+			continue
+		}
+
+		values[input.Name] = coerced
+	}
+
+	if len(inputErrs) > 0 {
+		return nil, inputErrs
+	}
+
+	return values, nil
+}
+
+// DecodeInputsInto extracts the values of the given inputs from the request as
+// DecodeInputs does, and then stores them in the struct pointed to by v. See
+// AccessRequest.Decode for how values are matched to struct fields.
+func DecodeInputsInto(r *http.Request, inputs []DataField, v interface{}) error {
+	values, err := DecodeInputs(r, inputs)
+	if err != nil {
+		return err
+	}
+
+	return assignInputs(values, v)
+}
+
+// Decode stores the decoded inputs of the request in the struct pointed to by
+// v. Inputs are matched to exported struct fields using the name given in a
+// "bigiot" struct tag, falling back to a case insensitive match on the field
+// name. Fields tagged with "-" are ignored. Supported field types are strings,
+// integers, floats, bools, time.Time, pointers to these and interface{}.
+//
+// Example:
+//		var params struct {
+//			Longitude float64 `bigiot:"longitude"`
+//			Latitude  float64 `bigiot:"latitude"`
+//		}
+//
+//		err := req.Decode(&params)
+func (a AccessRequest) Decode(v interface{}) error {
+	return assignInputs(a.Inputs, v)
+}
+
+// rawInputs extracts the undecoded values of the declared inputs from the
+// request.
+func rawInputs(r *http.Request, inputs []DataField) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(contentTypeHeader))
+	if r.Method == http.MethodPost && mediaType == applicationJSON {
+		body := make(map[string]interface{})
+
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			return nil, errors.New("malformed JSON body")
+		}
+
+		for _, input := range inputs {
+			if v, ok := body[input.Name]; ok && v != nil {
+				values[input.Name] = v
+			}
+		}
+
+		return values, nil
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return nil, errors.New("malformed request parameters")
+	}
+
+	for _, input := range inputs {
+		if _, ok := r.Form[input.Name]; ok {
+			values[input.Name] = r.Form.Get(input.Name)
+		}
+	}
+
+	return values, nil
+}
+
+// coerce converts the given raw value, which will either be a string read from
+// the query string or a value decoded from JSON, into the Go type for the
+// given datatype. Values that have already been coerced are also accepted, so
+// that decoded inputs can be assigned to struct fields. Values for unknown
+// datatypes are returned unchanged.
+func coerce(v interface{}, datatype string) (interface{}, error) {
+	switch datatype {
+	case XSDString:
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), nil
+		case int64:
+			return strconv.FormatInt(val, 10), nil
+		case bool:
+			return strconv.FormatBool(val), nil
+		case time.Time:
+			return val.Format(time.RFC3339), nil
+		}
+
+		return nil, errors.New("expected a string")
+
+	case XSDInteger:
+		switch val := v.(type) {
+		case string:
+			i, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return nil, errors.New("expected an integer")
+			}
+			return i, nil
+		case float64:
+			// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit in an
+			// int64
+			if val != math.Trunc(val) || math.Abs(val) >= math.MaxInt64 {
+				return nil, errors.New("expected an integer")
+			}
+			return int64(val), nil
+		case int64:
+			return val, nil
+		}
+
+		return nil, errors.New("expected an integer")
+
+	case XSDDecimal, XSDDouble:
+		switch val := v.(type) {
+		case string:
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil || !isFinite(f) {
+				return nil, errors.New("expected a number")
+			}
+			return f, nil
+		case float64:
+			if !isFinite(val) {
+				return nil, errors.New("expected a number")
+			}
+			return val, nil
+		case int64:
+			return float64(val), nil
+		}
+
+		return nil, errors.New("expected a number")
+
+	case XSDBoolean:
+		switch val := v.(type) {
+		case string:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, errors.New("expected a boolean")
+			}
+			return b, nil
+		case bool:
+			return val, nil
+		}
+
+		return nil, errors.New("expected a boolean")
+
+	case XSDDateTime:
+		switch val := v.(type) {
+		case string:
+			if t, err := time.Parse(time.RFC3339, val); err == nil {
+				return t.UTC(), nil
+			}
+
+			ms, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || ms < -maxEpochMs || ms > maxEpochMs {
+				return nil, errors.New("expected an RFC3339 timestamp or epoch milliseconds")
+			}
+			return FromEpochMs(ms), nil
+		case float64:
+			// checking the range first also rejects NaN, and ensures the
+			// conversion to int64 is well defined
+			if !(val >= -float64(maxEpochMs) && val <= float64(maxEpochMs)) {
+				return nil, errors.New("expected an RFC3339 timestamp or epoch milliseconds")
+			}
+			return FromEpochMs(int64(val)), nil
+		case time.Time:
+			return val, nil
+		}
+
+		return nil, errors.New("expected an RFC3339 timestamp or epoch milliseconds")
+	}
+
+	return v, nil
+}
+
+// FormatInput converts an input value into the string sent as a query
+// parameter, in a form which DecodeInputs converts back to the same value.
+func FormatInput(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		return val.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// maxEpochMs is the largest number of epoch milliseconds, positive or
+// negative, that FromEpochMs can convert without overflowing.
+const maxEpochMs = math.MaxInt64 / int64(time.Millisecond)
+
+// isFinite returns false for NaN and infinite values, which strconv.ParseFloat
+// accepts but which are never valid inputs.
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// timeType is the reflect.Type of time.Time, used when assigning values to
+// struct fields.
+var timeType = reflect.TypeOf(time.Time{})
+
+// assignInputs stores the given decoded values in the struct pointed to by v.
+func assignInputs(values map[string]interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("inputs can only be decoded into a pointer to a struct")
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	var inputErrs InputErrors
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			// unexported field
+			continue
+		}
+
+		name := fieldName(field)
+		if name == "" {
+			continue
+		}
+
+		value, ok := lookupInput(values, name)
+		if !ok {
+			continue
+		}
+
+		err := setField(rv.Field(i), value)
+		if err != nil {
+			inputErrs = append(inputErrs, InputError{Name: name, Reason: err.Error()})
+		}
+	}
+
+	if len(inputErrs) > 0 {
+		return inputErrs
+	}
+
+	return nil
+}
+
+// fieldName returns the input or output name for a struct field, taken from
+// the bigiot struct tag if present, otherwise the field name. An empty string
+// is returned for fields that should be skipped.
+func fieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("bigiot")
+	if tag == "-" {
+		return ""
+	}
+
+	if idx := strings.Index(tag, ","); idx != -1 {
+		tag = tag[:idx]
+	}
+
+	if tag != "" {
+		return tag
+	}
+
+	return field.Name
+}
+
+// lookupInput finds the named value, falling back to a case insensitive match.
+// If several names match case insensitively, the value of the first in sorted
+// order is used, so that the result doesn't depend on map iteration order.
+func lookupInput(values map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := values[name]; ok {
+		return v, true
+	}
+
+	match := ""
+	found := false
+
+	for k := range values {
+		if strings.EqualFold(k, name) && (!found || k < match) {
+			match = k
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, false
+	}
+
+	return values[match], true
+}
+
+// setField sets the value of the given struct field, converting the value to
+// the type of the field.
+func setField(f reflect.Value, v interface{}) error {
+	if f.Kind() == reflect.Ptr {
+		ptr := reflect.New(f.Type().Elem())
+		err := setField(ptr.Elem(), v)
+		if err != nil {
+			return err
+		}
+
+		f.Set(ptr)
+		return nil
+	}
+
+	if f.Type() == timeType {
+		t, err := coerce(v, XSDDateTime)
+		if err != nil {
+			return err
+		}
+
+		f.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		s, err := coerce(v, XSDString)
+		if err != nil {
+			return err
+		}
+		f.SetString(s.(string))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := coerce(v, XSDInteger)
+		if err != nil {
+			return err
+		}
+		if f.OverflowInt(i.(int64)) {
+			return errors.New("integer out of range")
+		}
+		f.SetInt(i.(int64))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := coerce(v, XSDInteger)
+		if err != nil {
+			return err
+		}
+		if i.(int64) < 0 || f.OverflowUint(uint64(i.(int64))) {
+			return errors.New("integer out of range")
+		}
+		f.SetUint(uint64(i.(int64)))
+
+	case reflect.Float32, reflect.Float64:
+		n, err := coerce(v, XSDDouble)
+		if err != nil {
+			return err
+		}
+		if f.OverflowFloat(n.(float64)) {
+			return errors.New("number out of range")
+		}
+		f.SetFloat(n.(float64))
+
+	case reflect.Bool:
+		b, err := coerce(v, XSDBoolean)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b.(bool))
+
+	case reflect.Interface:
+		if v == nil {
+			f.Set(reflect.Zero(f.Type()))
+			break
+		}
+
+		if !reflect.TypeOf(v).AssignableTo(f.Type()) {
+			return errors.Errorf("cannot be stored in a field of type %s", f.Type())
+		}
+		f.Set(reflect.ValueOf(v))
+
+	default:
+		return errors.Errorf("unsupported field type %s", f.Type())
+	}
+
+	return nil
+}
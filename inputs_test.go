@@ -0,0 +1,317 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+var testInputs = []bigiot.DataField{
+	{Name: "longitude", RdfURI: "schema:longitude", Required: true},
+	{Name: "latitude", RdfURI: "schema:latitude", Required: true},
+	{Name: "radius", Datatype: bigiot.XSDInteger},
+	{Name: "live", Datatype: bigiot.XSDBoolean},
+	{Name: "since", RdfURI: "schema:startDate"},
+	{Name: "label", RdfURI: "schema:random"},
+}
+
+func TestDecodeInputs(t *testing.T) {
+	testcases := []struct {
+		label       string
+		method      string
+		target      string
+		contentType string
+		body        string
+		expected    map[string]interface{}
+		expectedErr string
+	}{
+		{
+			label:  "query string",
+			method: http.MethodGet,
+			target: "/?longitude=2.33&latitude=54.5&radius=100&live=true&since=2018-01-01T09:04:00Z&label=abc",
+			expected: map[string]interface{}{
+				"longitude": 2.33,
+				"latitude":  54.5,
+				"radius":    int64(100),
+				"live":      true,
+				"since":     time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC),
+				"label":     "abc",
+			},
+		},
+		{
+			label:       "form body",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "longitude=2.33&latitude=54.5&since=1514797440000",
+			expected: map[string]interface{}{
+				"longitude": 2.33,
+				"latitude":  54.5,
+				"since":     time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC),
+			},
+		},
+		{
+			label:       "json body",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `{"longitude": 2.33, "latitude": "54.5", "radius": 100, "live": false, "label": 12}`,
+			expected: map[string]interface{}{
+				"longitude": 2.33,
+				"latitude":  54.5,
+				"radius":    int64(100),
+				"live":      false,
+				"label":     float64(12),
+			},
+		},
+		{
+			label:       "missing required",
+			method:      http.MethodGet,
+			target:      "/?longitude=2.33",
+			expectedErr: "invalid input latitude: missing required input",
+		},
+		{
+			label:       "malformed values",
+			method:      http.MethodGet,
+			target:      "/?longitude=2.33&latitude=54.5&radius=1.5&live=maybe&since=yesterday",
+			expectedErr: "invalid input radius: expected an integer; invalid input live: expected a boolean; invalid input since: expected an RFC3339 timestamp or epoch milliseconds",
+		},
+		{
+			label:       "non-finite numbers",
+			method:      http.MethodGet,
+			target:      "/?longitude=-Inf&latitude=NaN&since=Inf",
+			expectedErr: "invalid input longitude: expected a number; invalid input latitude: expected a number; invalid input since: expected an RFC3339 timestamp or epoch milliseconds",
+		},
+		{
+			label:       "json integer out of range",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `{"longitude": 2.33, "latitude": 54.5, "radius": 9223372036854775808}`,
+			expectedErr: "invalid input radius: expected an integer",
+		},
+		{
+			label:       "json timestamp out of range",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `{"longitude": 2.33, "latitude": 54.5, "since": 1e300}`,
+			expectedErr: "invalid input since: expected an RFC3339 timestamp or epoch milliseconds",
+		},
+		{
+			label:       "query timestamp out of range",
+			method:      http.MethodGet,
+			target:      "/?longitude=2.33&latitude=54.5&since=9223372036854775807",
+			expectedErr: "invalid input since: expected an RFC3339 timestamp or epoch milliseconds",
+		},
+		{
+			label:       "fractional json integer",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `{"longitude": 2.33, "latitude": 54.5, "radius": 1.5}`,
+			expectedErr: "invalid input radius: expected an integer",
+		},
+		{
+			label:       "malformed json",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `{"longitude"`,
+			expectedErr: "invalid input body: malformed JSON body",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			req := httptest.NewRequest(testcase.method, testcase.target, strings.NewReader(testcase.body))
+			if testcase.contentType != "" {
+				req.Header.Set("Content-Type", testcase.contentType)
+			}
+
+			got, err := bigiot.DecodeInputs(req, testInputs)
+			if testcase.expectedErr != "" {
+				assert.NotNil(t, err)
+				assert.IsType(t, bigiot.InputErrors{}, err)
+				assert.Equal(t, testcase.expectedErr, err.Error())
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, testcase.expected, got)
+			}
+		})
+	}
+}
+
+func TestDecodeInputsInto(t *testing.T) {
+	type params struct {
+		Longitude float64   `bigiot:"longitude"`
+		Lat       float32   `bigiot:"latitude"`
+		Radius    *uint16   `bigiot:"radius"`
+		Live      bool      `bigiot:"live"`
+		Since     time.Time `bigiot:"since"`
+		Label     string
+		Ignored   string `bigiot:"-"`
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?longitude=2.33&latitude=54.5&radius=100&live=1&since=1514797440000&label=abc", nil)
+
+		var got params
+		err := bigiot.DecodeInputsInto(req, testInputs, &got)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 2.33, got.Longitude)
+		assert.Equal(t, float32(54.5), got.Lat)
+		assert.Equal(t, uint16(100), *got.Radius)
+		assert.True(t, got.Live)
+		assert.Equal(t, time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC), got.Since)
+		assert.Equal(t, "abc", got.Label)
+		assert.Equal(t, "", got.Ignored)
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?longitude=2.33&latitude=54.5&radius=-1", nil)
+
+		var got params
+		err := bigiot.DecodeInputsInto(req, testInputs, &got)
+		assert.NotNil(t, err)
+		assert.Equal(t, "invalid input radius: integer out of range", err.Error())
+	})
+
+	t.Run("float out of range", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?longitude=2.33&latitude=1e300", nil)
+
+		var got params
+		err := bigiot.DecodeInputsInto(req, testInputs, &got)
+		assert.NotNil(t, err)
+		assert.Equal(t, "invalid input latitude: number out of range", err.Error())
+	})
+
+	t.Run("not a struct pointer", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?longitude=2.33&latitude=54.5", nil)
+
+		var got params
+		err := bigiot.DecodeInputsInto(req, testInputs, got)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestAccessRequestDecode(t *testing.T) {
+	req := bigiot.AccessRequest{
+		Inputs: map[string]interface{}{
+			"longitude": 2.33,
+			"latitude":  54.5,
+			"radius":    int64(100),
+		},
+	}
+
+	var params struct {
+		Longitude float64 `bigiot:"longitude"`
+		Latitude  float64 `bigiot:"latitude"`
+		Radius    int     `bigiot:"radius"`
+	}
+
+	err := req.Decode(&params)
+	assert.Nil(t, err)
+	assert.Equal(t, 2.33, params.Longitude)
+	assert.Equal(t, 54.5, params.Latitude)
+	assert.Equal(t, 100, params.Radius)
+}
+
+func TestAccessRequestDecodeInterface(t *testing.T) {
+	var params struct {
+		Value  interface{}
+		Label  fmt.Stringer
+		Radius *interface{}
+	}
+
+	req := bigiot.AccessRequest{
+		Inputs: map[string]interface{}{
+			"value":  nil,
+			"label":  nil,
+			"radius": int64(100),
+		},
+	}
+
+	err := req.Decode(&params)
+	assert.Nil(t, err)
+	assert.Nil(t, params.Value)
+	assert.Nil(t, params.Label)
+	assert.Equal(t, int64(100), *params.Radius)
+
+	req.Inputs["label"] = "abc"
+
+	err = req.Decode(&params)
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid input Label: cannot be stored in a field of type fmt.Stringer", err.Error())
+}
+
+func TestAccessRequestDecodeCaseInsensitive(t *testing.T) {
+	req := bigiot.AccessRequest{
+		Inputs: map[string]interface{}{
+			"label": "lower",
+			"LABEL": "upper",
+			"Label": "exact",
+		},
+	}
+
+	var params struct {
+		Label string
+		LABEl string
+	}
+
+	// an exact match is preferred, otherwise the first match in sorted order is
+	// used every time
+	for i := 0; i < 20; i++ {
+		err := req.Decode(&params)
+		assert.Nil(t, err)
+		assert.Equal(t, "exact", params.Label)
+		assert.Equal(t, "upper", params.LABEl)
+	}
+}
+
+func TestFormatInput(t *testing.T) {
+	inputs := map[string]interface{}{
+		"longitude": 2.33,
+		"latitude":  54.5,
+		"radius":    int64(100),
+		"live":      true,
+		"since":     time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC),
+		"label":     "abc",
+	}
+
+	query := url.Values{}
+	for name, value := range inputs {
+		query.Set(name, bigiot.FormatInput(value))
+	}
+
+	assert.Equal(t, "label=abc&latitude=54.5&live=true&longitude=2.33&radius=100&since=2018-01-01T09%3A04%3A00Z", query.Encode())
+
+	// formatted inputs are decoded to the same values
+	req := httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil)
+
+	got, err := bigiot.DecodeInputs(req, testInputs)
+	assert.Nil(t, err)
+	assert.Equal(t, inputs, got)
+}
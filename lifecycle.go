@@ -0,0 +1,255 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultShutdownTimeout is the default time allowed for all offerings to be
+// deactivated or deleted when a Lifecycle is shut down.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ShutdownPolicy controls what a Lifecycle does with its offerings on shutdown.
+type ShutdownPolicy int
+
+const (
+	// DeactivateOnShutdown deactivates offerings on shutdown by means of
+	// DeactivateOffering, so they keep their IDs and can be activated again
+	// when the service restarts. This is the default.
+	DeactivateOnShutdown ShutdownPolicy = iota
+
+	// DeleteOnShutdown deletes offerings from the marketplace on shutdown.
+	DeleteOnShutdown
+)
+
+// String returns the name of the policy.
+func (p ShutdownPolicy) String() string {
+	if p == DeleteOnShutdown {
+		return "delete"
+	}
+
+	return "deactivate"
+}
+
+// Lifecycle tracks the offerings registered through a Provider, so that they
+// can be deactivated or deleted when the service providing them shuts down,
+// rather than remaining active on the marketplace until they expire.
+type Lifecycle struct {
+	mu        sync.Mutex
+	provider  *Provider
+	policy    ShutdownPolicy
+	timeout   time.Duration
+	offerings []string
+}
+
+// LifecycleOption is a functional configuration type used to configure
+// optional behaviour of a Lifecycle.
+type LifecycleOption func(*Lifecycle)
+
+// WithShutdownPolicy is a LifecycleOption setting whether offerings are
+// deactivated or deleted on shutdown. The default is DeactivateOnShutdown.
+func WithShutdownPolicy(policy ShutdownPolicy) LifecycleOption {
+	return func(l *Lifecycle) {
+		l.policy = policy
+	}
+}
+
+// WithShutdownTimeout is a LifecycleOption setting the time allowed for all
+// offerings to be deactivated or deleted on shutdown. The default is
+// DefaultShutdownTimeout.
+func WithShutdownTimeout(timeout time.Duration) LifecycleOption {
+	return func(l *Lifecycle) {
+		l.timeout = timeout
+	}
+}
+
+// NewLifecycle returns a Lifecycle which tracks every offering registered by
+// the given provider from now on, and stops tracking offerings deleted by the
+// provider. It should be created before any offerings are registered. A
+// provider has at most one Lifecycle, so calling NewLifecycle again replaces
+// the previous one.
+//
+// Example:
+//		lifecycle := bigiot.NewLifecycle(provider)
+//		lifecycle.ShutdownOnSignal(func(results []bigiot.ShutdownResult, err error) {
+//			os.Exit(0)
+//		})
+//
+//		offering, _ := provider.RegisterOffering(ctx, description)
+func NewLifecycle(provider *Provider, options ...LifecycleOption) *Lifecycle {
+	l := &Lifecycle{
+		provider: provider,
+		policy:   DeactivateOnShutdown,
+		timeout:  DefaultShutdownTimeout,
+	}
+
+	for _, opt := range options {
+		opt(l)
+	}
+
+	provider.lifecycle = l
+
+	return l
+}
+
+// Track adds an offering to the set deactivated or deleted on shutdown. It is
+// only required for offerings registered before the Lifecycle was created.
+func (l *Lifecycle) Track(offeringID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range l.offerings {
+		if id == offeringID {
+			return
+		}
+	}
+
+	l.offerings = append(l.offerings, offeringID)
+}
+
+// Untrack removes an offering from the set deactivated or deleted on
+// shutdown.
+func (l *Lifecycle) Untrack(offeringID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, id := range l.offerings {
+		if id == offeringID {
+			l.offerings = append(l.offerings[:i], l.offerings[i+1:]...)
+			return
+		}
+	}
+}
+
+// Offerings returns the IDs of the tracked offerings in the order they were
+// registered.
+func (l *Lifecycle) Offerings() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offerings := make([]string, len(l.offerings))
+	copy(offerings, l.offerings)
+
+	return offerings
+}
+
+// ShutdownResult is the outcome of deactivating or deleting a single offering
+// on shutdown. Err is nil if the offering was deactivated or deleted.
+type ShutdownResult struct {
+	OfferingID string
+	Err        error
+}
+
+// Shutdown deactivates or deletes all tracked offerings according to the
+// policy, making the requests concurrently and waiting at most the shutdown
+// timeout for them to complete. It returns a result for every offering in the
+// order they were registered, and an error if any of them failed. Offerings
+// which were deactivated or deleted are no longer tracked, so Shutdown may be
+// called again to retry those which failed.
+func (l *Lifecycle) Shutdown(ctx context.Context) ([]ShutdownResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	offerings := l.Offerings()
+	results := make([]ShutdownResult, len(offerings))
+
+	var wg sync.WaitGroup
+
+	for i, offeringID := range offerings {
+		wg.Add(1)
+
+		go func(i int, offeringID string) {
+			defer wg.Done()
+
+			results[i] = ShutdownResult{
+				OfferingID: offeringID,
+				Err:        l.shutdownOffering(ctx, offeringID),
+			}
+		}(i, offeringID)
+	}
+
+	wg.Wait()
+
+	failed := 0
+
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			l.provider.logger.Error("offering shutdown failed", "offering", result.OfferingID, "policy", l.policy.String(), "error", result.Err)
+			continue
+		}
+
+		l.Untrack(result.OfferingID)
+		l.provider.logger.Info("offering shutdown", "offering", result.OfferingID, "policy", l.policy.String())
+	}
+
+	if failed > 0 {
+		return results, errors.Errorf("failed to %s %d of %d offerings", l.policy, failed, len(results))
+	}
+
+	return results, nil
+}
+
+// ShutdownOnSignal calls Shutdown when the process receives one of the given
+// signals, or SIGTERM or an interrupt if none are given, and then calls fn with
+// the results. The caller is responsible for exiting the process from fn if
+// required. The returned function stops listening for signals.
+func (l *Lifecycle) ShutdownOnSignal(fn func([]ShutdownResult, error), signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	signal.Notify(ch, signals...)
+
+	go func() {
+		select {
+		case <-ch:
+			signal.Stop(ch)
+			fn(l.Shutdown(context.Background()))
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// shutdownOffering deactivates or deletes a single offering.
+func (l *Lifecycle) shutdownOffering(ctx context.Context, offeringID string) error {
+	if l.policy == DeleteOnShutdown {
+		return l.provider.DeleteOffering(ctx, &DeleteOffering{ID: offeringID})
+	}
+
+	_, err := l.provider.DeactivateOffering(ctx, offeringID)
+
+	return err
+}
@@ -0,0 +1,188 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+// lifecycleServer returns a fake marketplace which registers offerings with
+// the ID Provider-<LocalID>, and records the deactivation and deletion
+// requests it receives, returned in sorted order. Requests for the offering
+// Provider-Broken fail, and requests for Provider-Slow never complete.
+func lifecycleServer() (*httptest.Server, func() []string) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+
+	localID := regexp.MustCompile(`localId: "(\w+)"`)
+	mutation := regexp.MustCompile(`mutation (\w+) .*id: "([\w-]+)"`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := struct {
+			Query string `json:"query"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&q)
+
+		if m := localID.FindStringSubmatch(q.Query); m != nil {
+			w.Write([]byte(`{"data": {"addOffering": {"id": "Provider-` + m[1] + `"}}}`))
+			return
+		}
+
+		m := mutation.FindStringSubmatch(q.Query)
+		if m == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		operation, id := m[1], m[2]
+
+		switch id {
+		case "Provider-Broken":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"data": null, "errors": [{"message": "offering broken"}]}`))
+			return
+		case "Provider-Slow":
+			<-r.Context().Done()
+			return
+		}
+
+		mu.Lock()
+		requests = append(requests, operation+" "+id)
+		mu.Unlock()
+
+		w.Write([]byte(`{"data": {"` + operation + `": {"id": "` + id + `"}}}`))
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sort.Strings(requests)
+		return requests
+	}
+}
+
+func TestLifecycleShutdown(t *testing.T) {
+	ctx := context.Background()
+
+	testcases := []struct {
+		name     string
+		policy   bigiot.ShutdownPolicy
+		expected []string
+	}{
+		{
+			name:   "deactivate",
+			policy: bigiot.DeactivateOnShutdown,
+			expected: []string{
+				"deactivateOffering Provider-Parking",
+				"deactivateOffering Provider-Weather",
+				"deleteOffering Provider-Removed",
+			},
+		},
+		{
+			name:   "delete",
+			policy: bigiot.DeleteOnShutdown,
+			expected: []string{
+				"deleteOffering Provider-Parking",
+				"deleteOffering Provider-Removed",
+				"deleteOffering Provider-Weather",
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			server, requests := lifecycleServer()
+			defer server.Close()
+
+			provider, err := bigiot.NewProvider("Provider", testSecret, bigiot.WithMarketplace(server.URL))
+			assert.Nil(t, err)
+
+			lifecycle := bigiot.NewLifecycle(provider, bigiot.WithShutdownPolicy(testcase.policy))
+
+			for _, localID := range []string{"Parking", "Weather", "Removed"} {
+				_, err = provider.RegisterOffering(ctx, &bigiot.OfferingDescription{LocalID: localID})
+				assert.Nil(t, err)
+			}
+
+			// deleted offerings are no longer tracked
+			err = provider.DeleteOffering(ctx, &bigiot.DeleteOffering{ID: "Provider-Removed"})
+			assert.Nil(t, err)
+
+			assert.Equal(t, []string{"Provider-Parking", "Provider-Weather"}, lifecycle.Offerings())
+
+			results, err := lifecycle.Shutdown(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, []bigiot.ShutdownResult{
+				{OfferingID: "Provider-Parking"},
+				{OfferingID: "Provider-Weather"},
+			}, results)
+
+			assert.Equal(t, testcase.expected, requests())
+			assert.Len(t, lifecycle.Offerings(), 0)
+		})
+	}
+}
+
+func TestLifecycleShutdownFailures(t *testing.T) {
+	server, requests := lifecycleServer()
+	defer server.Close()
+
+	provider, err := bigiot.NewProvider("Provider", testSecret, bigiot.WithMarketplace(server.URL))
+	assert.Nil(t, err)
+
+	lifecycle := bigiot.NewLifecycle(
+		provider,
+		bigiot.WithShutdownPolicy(bigiot.DeleteOnShutdown),
+		bigiot.WithShutdownTimeout(50*time.Millisecond),
+	)
+
+	lifecycle.Track("Provider-Slow")
+	lifecycle.Track("Provider-Parking")
+	lifecycle.Track("Provider-Broken")
+	lifecycle.Track("Provider-Parking")
+
+	start := time.Now()
+
+	results, err := lifecycle.Shutdown(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to delete 2 of 3 offerings", err.Error())
+	assert.True(t, time.Since(start) < time.Second)
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, "Provider-Slow", results[0].OfferingID)
+	assert.NotNil(t, results[0].Err)
+	assert.Equal(t, bigiot.ShutdownResult{OfferingID: "Provider-Parking"}, results[1])
+	assert.Equal(t, "Provider-Broken", results[2].OfferingID)
+	assert.Equal(t, "Error deleting offering: offering broken", results[2].Err.Error())
+
+	assert.Equal(t, []string{"deleteOffering Provider-Parking"}, requests())
+
+	// failed offerings remain tracked so shutdown can be retried
+	assert.Equal(t, []string{"Provider-Slow", "Provider-Broken"}, lifecycle.Offerings())
+}
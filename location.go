@@ -0,0 +1,155 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// latitudeURI is the RdfURI identifying latitude inputs
+	latitudeURI = "schema:latitude"
+
+	// longitudeURI is the RdfURI identifying longitude inputs
+	longitudeURI = "schema:longitude"
+)
+
+// WithLocationValidation is an AccessOption that rejects requests whose
+// location falls outside the SpatialExtent of the offering's description. The
+// location is read from the inputs with an RdfURI of schema:latitude and
+// schema:longitude, and is tested against the extent's Polygon or BoundingBox
+// (see SpatialExtent.Contains).
+//
+// Requests outside the coverage area receive a 422 Unprocessable Entity
+// response, which as well as the usual list of errors contains a "coverage"
+// field holding the offering's coverage area as a GeoJSON Feature, so that
+// consumers can learn where the offering has data. Requests which don't
+// include both a latitude and a longitude, or offerings without a bounding
+// box or polygon, are not validated. A latitude or longitude which is not a
+// finite number, or is outside the range [-90, 90] or [-180, 180]
+// respectively, is rejected with a 400 Bad Request.
+//
+// Example:
+//		handler := provider.AccessHandler(offering.ID, description, fn, bigiot.WithLocationValidation())
+func WithLocationValidation() AccessOption {
+	return func(h *accessHandler) {
+		h.validateLocation = true
+	}
+}
+
+// coverageErrorResponse is the error response we return when a request is
+// outside an offering's coverage area.
+type coverageErrorResponse struct {
+	ErrorResponse
+	Coverage json.RawMessage `json:"coverage"`
+}
+
+// checkLocation tests the location given in the inputs against the spatial
+// extent of the offering, writing an error response and returning false if it
+// is outside. Extents without any geometry Contains can test against, such as
+// a City alone, accept every location.
+func (h *accessHandler) checkLocation(w http.ResponseWriter, inputs map[string]interface{}) bool {
+	extent := h.description.SpatialExtent
+	if extent == nil || !extent.hasGeometry() {
+		return true
+	}
+
+	location, ok, err := inputLocation(h.description.Inputs, inputs)
+	if err != nil {
+		writeInputErrors(w, err)
+		return false
+	}
+
+	if !ok || extent.Contains(location) {
+		return true
+	}
+
+	coverage, err := extent.MarshalGeoJSON()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error encoding coverage area")
+		return false
+	}
+
+	writeJSON(w, http.StatusUnprocessableEntity, coverageErrorResponse{
+		ErrorResponse: ErrorResponse{
+			Errors: []Error{
+				{
+					Message: fmt.Sprintf(
+						"location (latitude %s, longitude %s) is outside the coverage area of this offering",
+						strconv.FormatFloat(location.Lat, 'f', -1, 64),
+						strconv.FormatFloat(location.Lng, 'f', -1, 64),
+					),
+				},
+			},
+		},
+		Coverage: coverage,
+	})
+
+	return false
+}
+
+// inputLocation returns the location given by the latitude and longitude
+// inputs, if present. An InputErrors error is returned if either is not a
+// finite number, or is outside the valid range for a latitude or longitude.
+func inputLocation(fields []DataField, inputs map[string]interface{}) (Location, bool, error) {
+	var (
+		location       Location
+		hasLat, hasLng bool
+		inputErrs      InputErrors
+	)
+
+	for _, field := range fields {
+		if field.RdfURI != latitudeURI && field.RdfURI != longitudeURI {
+			continue
+		}
+
+		v, ok := inputs[field.Name]
+		if !ok {
+			continue
+		}
+
+		coerced, err := coerce(v, XSDDouble)
+		if err != nil {
+			inputErrs = append(inputErrs, InputError{Name: field.Name, Reason: err.Error()})
+			continue
+		}
+
+		f := coerced.(float64)
+
+		switch field.RdfURI {
+		case latitudeURI:
+			if f < -90 || f > 90 {
+				inputErrs = append(inputErrs, InputError{Name: field.Name, Reason: "latitude must be between -90 and 90"})
+				continue
+			}
+			location.Lat, hasLat = f, true
+		case longitudeURI:
+			if f < -180 || f > 180 {
+				inputErrs = append(inputErrs, InputError{Name: field.Name, Reason: "longitude must be between -180 and 180"})
+				continue
+			}
+			location.Lng, hasLng = f, true
+		}
+	}
+
+	if len(inputErrs) > 0 {
+		return location, false, inputErrs
+	}
+
+	return location, hasLat && hasLng, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/mocks"
+)
+
+func TestAccessHandlerWithLocationValidation(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+
+	p, err := bigiot.NewProvider("id", testSecret, bigiot.WithClock(mocks.Clock{T: now}))
+	assert.Nil(t, err)
+
+	inputs := []bigiot.DataField{
+		{Name: "lng", RdfURI: "schema:longitude"},
+		{Name: "lat", RdfURI: "schema:latitude"},
+	}
+
+	outputs := []bigiot.DataField{
+		{Name: "value", RdfURI: "schema:random"},
+	}
+
+	fn := func(ctx context.Context, req bigiot.AccessRequest) (bigiot.AccessResponse, error) {
+		return bigiot.AccessResponse{
+			Records: []bigiot.Record{{"value": 1}},
+		}, nil
+	}
+
+	berlin := &bigiot.SpatialExtent{
+		City: "Berlin",
+		BoundingBox: &bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: 13.7, Lat: 52.7},
+			Location2: bigiot.Location{Lng: 13.1, Lat: 52.3},
+		},
+	}
+
+	testcases := []struct {
+		label          string
+		extent         *bigiot.SpatialExtent
+		target         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			label:          "inside bounding box",
+			extent:         berlin,
+			target:         "/?lat=52.5&lng=13.4",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":1}]`,
+		},
+		{
+			label:          "outside bounding box",
+			extent:         berlin,
+			target:         "/?lat=48.85&lng=2.35",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"errors":[{"message":"location (latitude 48.85, longitude 2.35) is outside the coverage area of this offering"}],"coverage":{"type":"Feature","bbox":[13.1,52.3,13.7,52.7],"geometry":{"type":"Polygon","coordinates":[[[13.1,52.3],[13.7,52.3],[13.7,52.7],[13.1,52.7],[13.1,52.3]]]},"properties":{"city":"Berlin"}}}`,
+		},
+		{
+			label:          "location not given",
+			extent:         berlin,
+			target:         "/?lat=48.85",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":1}]`,
+		},
+		{
+			label: "outside polygon",
+			extent: &bigiot.SpatialExtent{
+				Polygon: []bigiot.Location{
+					{Lng: 0, Lat: 0},
+					{Lng: 10, Lat: 0},
+					{Lng: 0, Lat: 10},
+				},
+			},
+			target:         "/?lat=8&lng=8",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"errors":[{"message":"location (latitude 8, longitude 8) is outside the coverage area of this offering"}],"coverage":{"type":"Feature","bbox":[0,0,10,10],"geometry":{"type":"Polygon","coordinates":[[[0,0],[10,0],[0,10],[0,0]]]}}}`,
+		},
+		{
+			label:          "non-finite longitude",
+			extent:         berlin,
+			target:         "/?lat=52.5&lng=NaN",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input lng: expected a number"}]}`,
+		},
+		{
+			label:          "infinite latitude",
+			extent:         berlin,
+			target:         "/?lat=-Inf&lng=13.4",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input lat: expected a number"}]}`,
+		},
+		{
+			label:          "latitude out of range",
+			extent:         berlin,
+			target:         "/?lat=91&lng=13.4",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input lat: latitude must be between -90 and 90"}]}`,
+		},
+		{
+			label:          "longitude out of range",
+			extent:         berlin,
+			target:         "/?lat=52.5&lng=373.4",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input lng: longitude must be between -180 and 180"}]}`,
+		},
+		{
+			label:          "huge longitude",
+			extent:         berlin,
+			target:         "/?lat=52.5&lng=1e300",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"errors":[{"message":"invalid input lng: longitude must be between -180 and 180"}]}`,
+		},
+		{
+			label:          "no geometry",
+			extent:         &bigiot.SpatialExtent{City: "Berlin"},
+			target:         "/?lat=48.85&lng=2.35",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":1}]`,
+		},
+		{
+			label: "degenerate polygon",
+			extent: &bigiot.SpatialExtent{
+				Polygon: []bigiot.Location{
+					{Lng: 0, Lat: 0},
+					{Lng: 10, Lat: 0},
+				},
+			},
+			target:         "/?lat=48.85&lng=2.35",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":1}]`,
+		},
+		{
+			label:          "no extent",
+			target:         "/?lat=48.85&lng=2.35",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":1}]`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			description := &bigiot.OfferingDescription{
+				Inputs:        inputs,
+				Outputs:       outputs,
+				SpatialExtent: testcase.extent,
+			}
+
+			handler := p.AccessHandler("Provider-Offering", description, fn, bigiot.WithLocationValidation())
+
+			req := httptest.NewRequest(http.MethodGet, testcase.target, nil)
+			req.Header.Set("Authorization", "Bearer "+signToken(t, testSecret, "Provider-Offering", "Consumer-Query", now))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, testcase.expectedStatus, rec.Code)
+			assert.JSONEq(t, testcase.expectedBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// redacted is the value logged in place of secrets.
+const redacted = "REDACTED"
+
+var (
+	// secretPattern matches the client secret sent in the query string of
+	// requests to the /accessToken endpoint, or in a form encoded body.
+	secretPattern = regexp.MustCompile(`(?i)(client_?secret=)[^&\s"']+`)
+
+	// jsonSecretPattern matches the client secret in JSON documents, e.g.
+	// {"clientSecret":"..."} or {"client_secret": "..."}, including JSON
+	// which has itself been quoted within a JSON string.
+	jsonSecretPattern = regexp.MustCompile(`(?i)(\\?"client_?secret\\?"\s*:\s*\\?")[^"\\]*`)
+
+	// bearerPattern matches bearer tokens in Authorization headers.
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[^\s"']+`)
+
+	// basicPattern matches the credentials in Basic Authorization headers,
+	// including headers formatted as a Go http.Header or as JSON.
+	basicPattern = regexp.MustCompile(`(?i)(authorization["']?[\s:=\["']*basic\s+)[^\s"'\]]+`)
+)
+
+// Logger is the interface used to log the requests made to the marketplace.
+// Messages are passed with alternating keys and values, in the style of
+// log/slog, so a *slog.Logger can be used as a Logger directly. The interface
+// is deliberately minimal so that it can be adapted to any other logging
+// library without this package depending on it.
+//
+// Operation names, outcomes and durations are logged at info level, or at error
+// level for requests which fail. GraphQL documents sent to the marketplace and
+// the responses received are logged at debug level. Client secrets, bearer
+// tokens and basic auth credentials are always redacted before being passed to
+// the Logger.
+//
+// Example:
+//		type zapLogger struct {
+//			logger *zap.SugaredLogger
+//		}
+//
+//		func (l *zapLogger) Debug(msg string, keyvals ...interface{}) {
+//			l.logger.Debugw(msg, keyvals...)
+//		}
+//
+//		func (l *zapLogger) Info(msg string, keyvals ...interface{}) {
+//			l.logger.Infow(msg, keyvals...)
+//		}
+//
+//		func (l *zapLogger) Error(msg string, keyvals ...interface{}) {
+//			l.logger.Errorw(msg, keyvals...)
+//		}
+type Logger interface {
+	// Debug logs verbose messages useful when debugging, such as the bodies of
+	// requests and responses.
+	Debug(msg string, keyvals ...interface{})
+
+	// Info logs a message about normal operation.
+	Info(msg string, keyvals ...interface{})
+
+	// Error logs a message about a failed operation.
+	Error(msg string, keyvals ...interface{})
+}
+
+// WithLogger allows a caller to log the requests made to the marketplace.
+//
+// Example:
+// 		provider, _ := bigiot.NewProvider(
+//			providerID,
+//			providerSecret,
+//			bigiot.WithLogger(slog.Default()),
+// 		)
+func WithLogger(logger Logger) Option {
+	return func(b *base) error {
+		b.logger = &redactingLogger{logger: logger}
+
+		return nil
+	}
+}
+
+// NewStdLogger returns a Logger which writes messages to a standard library
+// *log.Logger in logfmt style, e.g. level=info msg="request completed"
+// operation=addOffering. Debug messages are discarded unless debug is true.
+func NewStdLogger(logger *log.Logger, debug bool) Logger {
+	return &stdLogger{
+		logger: logger,
+		debug:  debug,
+	}
+}
+
+// stdLogger is our Logger adapter for the standard library logger.
+type stdLogger struct {
+	logger *log.Logger
+	debug  bool
+}
+
+// Debug is our implementation of Logger.
+func (l *stdLogger) Debug(msg string, keyvals ...interface{}) {
+	if l.debug {
+		l.output("debug", msg, keyvals)
+	}
+}
+
+// Info is our implementation of Logger.
+func (l *stdLogger) Info(msg string, keyvals ...interface{}) {
+	l.output("info", msg, keyvals)
+}
+
+// Error is our implementation of Logger.
+func (l *stdLogger) Error(msg string, keyvals ...interface{}) {
+	l.output("error", msg, keyvals)
+}
+
+// output formats a single message as logfmt and writes it to the wrapped
+// logger.
+func (l *stdLogger) output(level, msg string, keyvals []interface{}) {
+	var buf bytes.Buffer
+
+	buf.WriteString("level=")
+	buf.WriteString(level)
+	buf.WriteString(" msg=")
+	buf.WriteString(logfmtValue(msg))
+
+	for i := 0; i < len(keyvals); i += 2 {
+		buf.WriteByte(' ')
+		buf.WriteString(fmt.Sprint(keyvals[i]))
+		buf.WriteByte('=')
+
+		if i+1 < len(keyvals) {
+			buf.WriteString(logfmtValue(fmt.Sprint(keyvals[i+1])))
+		} else {
+			buf.WriteString(`""`)
+		}
+	}
+
+	l.logger.Print(buf.String())
+}
+
+// logfmtValue quotes a value if it is empty or contains characters which would
+// make the logfmt output ambiguous.
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// redactingLogger wraps the Logger supplied by the caller, redacting secrets
+// from every message before it is logged.
+type redactingLogger struct {
+	logger Logger
+}
+
+// Debug is our implementation of Logger.
+func (l *redactingLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Debug(redact(msg), redactKeyvals(keyvals)...)
+}
+
+// Info is our implementation of Logger.
+func (l *redactingLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Info(redact(msg), redactKeyvals(keyvals)...)
+}
+
+// Error is our implementation of Logger.
+func (l *redactingLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger.Error(redact(msg), redactKeyvals(keyvals)...)
+}
+
+// redactKeyvals returns a copy of keyvals with secrets redacted from any
+// string, byte slice or error values. Other values are passed through
+// unchanged, so loggers can format them as they see fit.
+func redactKeyvals(keyvals []interface{}) []interface{} {
+	redactedKeyvals := make([]interface{}, len(keyvals))
+
+	for i, v := range keyvals {
+		switch value := v.(type) {
+		case string:
+			redactedKeyvals[i] = redact(value)
+		case []byte:
+			redactedKeyvals[i] = redact(string(value))
+		case error:
+			redactedKeyvals[i] = redact(value.Error())
+		default:
+			redactedKeyvals[i] = v
+		}
+	}
+
+	return redactedKeyvals
+}
+
+// redact replaces any client secrets, bearer tokens or basic auth credentials
+// contained in s.
+func redact(s string) string {
+	s = secretPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = jsonSecretPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+	return basicPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// noopLogger is the Logger implementation used when the caller doesn't supply
+// one.
+type noopLogger struct{}
+
+// Debug is our implementation of Logger, and does nothing.
+func (noopLogger) Debug(msg string, keyvals ...interface{}) {}
+
+// Info is our implementation of Logger, and does nothing.
+func (noopLogger) Info(msg string, keyvals ...interface{}) {}
+
+// Error is our implementation of Logger, and does nothing.
+func (noopLogger) Error(msg string, keyvals ...interface{}) {}
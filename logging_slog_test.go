@@ -0,0 +1,66 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package bigiot_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/simular"
+)
+
+func TestSlogLogger(t *testing.T) {
+	simular.Activate()
+	defer simular.DeactivateAndReset()
+
+	simular.RegisterStubRequests(
+		simular.NewStubRequest(
+			http.MethodGet,
+			"https://market.big-iot.org/accessToken?clientId=Provider&clientSecret=secret",
+			simular.NewStringResponder(403, "ClientDoesNotExist: Provider"),
+		),
+	)
+
+	var buf bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	// a *slog.Logger satisfies Logger without an adapter
+	var _ bigiot.Logger = logger
+
+	provider, err := bigiot.NewProvider(
+		"Provider",
+		"secret",
+		bigiot.WithLogger(logger),
+	)
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.NotNil(t, err)
+
+	output := buf.String()
+
+	assert.Contains(t, output, `level=DEBUG msg="sending request" operation=accessToken url="https://market.big-iot.org/accessToken?clientId=Provider&clientSecret=REDACTED"`)
+	assert.Contains(t, output, `level=DEBUG msg="received response" operation=accessToken status=403 body="ClientDoesNotExist: Provider"`)
+	assert.Contains(t, output, `level=ERROR msg="request failed" operation=accessToken outcome=rejected`)
+	assert.NotContains(t, output, "clientSecret=secret")
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"time"
+)
+
+// The names of the operations passed to Metrics implementations. Other than
+// OperationAccessToken these match the names of the GraphQL operations sent to
+// the marketplace.
+const (
+	// OperationAccessToken is the name of requests made by Authenticate.
+	OperationAccessToken = "accessToken"
+
+	// OperationAddOffering is the name of requests made by RegisterOffering.
+	OperationAddOffering = "addOffering"
+
+	// OperationActivateOffering is the name of requests made by
+	// ActivateOffering.
+	OperationActivateOffering = "activateOffering"
+
+	// OperationDeactivateOffering is the name of requests made by
+	// DeactivateOffering.
+	OperationDeactivateOffering = "deactivateOffering"
+
+	// OperationDeleteOffering is the name of requests made by DeleteOffering.
+	OperationDeleteOffering = "deleteOffering"
+
+	// OperationTrackAccesses is the name of requests made by ReportUsage.
+	OperationTrackAccesses = "trackAccesses"
+)
+
+// Outcome describes the result of a request made to the marketplace.
+type Outcome string
+
+const (
+	// OutcomeSuccess is the outcome of requests which completed successfully.
+	OutcomeSuccess Outcome = "success"
+
+	// OutcomeRejected is the outcome of requests to which the marketplace
+	// responded with an error, for example because of invalid credentials or an
+	// invalid offering.
+	OutcomeRejected Outcome = "rejected"
+
+	// OutcomeFailed is the outcome of requests which did not receive a response
+	// from the marketplace, for example because it could not be reached or the
+	// request timed out.
+	OutcomeFailed Outcome = "failed"
+)
+
+// Metrics is the interface used to record metrics about the requests made to
+// the marketplace. It is deliberately minimal so that it can be adapted to any
+// metrics library without this package depending on it.
+//
+// Example:
+//		type prometheusMetrics struct {
+//			requests *prometheus.HistogramVec
+//		}
+//
+//		func (m *prometheusMetrics) ObserveRequest(operation string, outcome bigiot.Outcome, duration time.Duration) {
+//			m.requests.WithLabelValues(operation, string(outcome)).Observe(duration.Seconds())
+//		}
+type Metrics interface {
+	// ObserveRequest is called once for every request made to the marketplace,
+	// with the name of the operation, its outcome and how long it took.
+	// Implementations must be safe for concurrent use.
+	ObserveRequest(operation string, outcome Outcome, duration time.Duration)
+}
+
+// WithMetrics allows a caller to record metrics about every request made to
+// the marketplace, labelled by operation name and outcome.
+//
+// Example:
+// 		provider, _ := bigiot.NewProvider(
+//			providerID,
+//			providerSecret,
+//			bigiot.WithMetrics(metrics),
+// 		)
+func WithMetrics(metrics Metrics) Option {
+	return func(b *base) error {
+		b.metrics = metrics
+
+		return nil
+	}
+}
+
+// noopMetrics is the Metrics implementation used when the caller doesn't
+// supply one.
+type noopMetrics struct{}
+
+// ObserveRequest is our implementation of Metrics, and does nothing.
+func (noopMetrics) ObserveRequest(operation string, outcome Outcome, duration time.Duration) {}
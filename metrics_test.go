@@ -0,0 +1,170 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/simular"
+)
+
+type observation struct {
+	operation string
+	outcome   bigiot.Outcome
+	duration  time.Duration
+}
+
+type recordingMetrics struct {
+	sync.Mutex
+	observations []observation
+}
+
+func (m *recordingMetrics) ObserveRequest(operation string, outcome bigiot.Outcome, duration time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.observations = append(m.observations, observation{operation, outcome, duration})
+}
+
+// tickingClock is a Clock which advances by a second every time it is read.
+type tickingClock struct {
+	sync.Mutex
+	t time.Time
+}
+
+func (c *tickingClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	c.t = c.t.Add(time.Second)
+	return c.t
+}
+
+type failingTripper struct{}
+
+func (failingTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestMetrics(t *testing.T) {
+	simular.Activate()
+	defer simular.DeactivateAndReset()
+
+	simular.RegisterStubRequests(
+		simular.NewStubRequest(
+			http.MethodGet,
+			"https://market.big-iot.org/accessToken?clientId=Provider&clientSecret=wrong",
+			simular.NewStringResponder(403, "ClientDoesNotExist: Provider"),
+		),
+		simular.NewStubRequest(
+			http.MethodGet,
+			"https://market.big-iot.org/accessToken?clientId=Provider&clientSecret=secret",
+			simular.NewStringResponder(200, "1234abcd"),
+		),
+		simular.NewStubRequest(
+			http.MethodPost,
+			"https://market.big-iot.org/graphql",
+			simular.NewStringResponder(200, `{"data": {"deleteOffering": {"id": "Provider-Offering"}}}`),
+		),
+		simular.NewStubRequest(
+			http.MethodPost,
+			"https://market.big-iot.org/graphql",
+			simular.NewStringResponder(400, `{"data":null,"errors":[{"message":"bad request"}]}`),
+		),
+	)
+
+	metrics := &recordingMetrics{}
+	clock := &tickingClock{t: time.Unix(0, 0)}
+
+	provider, err := bigiot.NewProvider(
+		"Provider",
+		"wrong",
+		bigiot.WithMetrics(metrics),
+		bigiot.WithClock(clock),
+	)
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.NotNil(t, err)
+
+	provider, err = bigiot.NewProvider(
+		"Provider",
+		"secret",
+		bigiot.WithMetrics(metrics),
+		bigiot.WithClock(clock),
+	)
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.Nil(t, err)
+
+	err = provider.DeleteOffering(context.Background(), &bigiot.DeleteOffering{ID: "Provider-Offering"})
+	assert.Nil(t, err)
+
+	err = provider.DeleteOffering(context.Background(), &bigiot.DeleteOffering{ID: "Provider-Offering"})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []observation{
+		{bigiot.OperationAccessToken, bigiot.OutcomeRejected, time.Second},
+		{bigiot.OperationAccessToken, bigiot.OutcomeSuccess, time.Second},
+		{bigiot.OperationDeleteOffering, bigiot.OutcomeSuccess, time.Second},
+		{bigiot.OperationDeleteOffering, bigiot.OutcomeRejected, time.Second},
+	}, metrics.observations)
+}
+
+func TestMetricsFailedRequests(t *testing.T) {
+	metrics := &recordingMetrics{}
+	clock := &tickingClock{t: time.Unix(0, 0)}
+
+	provider, err := bigiot.NewProvider(
+		"Provider",
+		"secret",
+		bigiot.WithMetrics(metrics),
+		bigiot.WithClock(clock),
+		bigiot.WithHTTPClient(&http.Client{Transport: failingTripper{}}),
+	)
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.NotNil(t, err)
+
+	_, err = provider.ActivateOffering(context.Background(), &bigiot.ActivateOffering{ID: "Provider-Offering"})
+	assert.NotNil(t, err)
+
+	err = provider.ReportUsage(context.Background(), bigiot.NewMemoryAccountingStore())
+	assert.Nil(t, err)
+
+	store := bigiot.NewMemoryAccountingStore()
+	assert.Nil(t, store.Record(context.Background(), "Consumer", "Provider-Offering", 10))
+
+	err = provider.ReportUsage(context.Background(), store)
+	assert.NotNil(t, err)
+
+	assert.Len(t, metrics.observations, 3)
+	assert.Equal(t, bigiot.OperationAccessToken, metrics.observations[0].operation)
+	assert.Equal(t, bigiot.OperationActivateOffering, metrics.observations[1].operation)
+	assert.Equal(t, bigiot.OperationTrackAccesses, metrics.observations[2].operation)
+
+	for _, o := range metrics.observations {
+		assert.Equal(t, bigiot.OutcomeFailed, o.outcome)
+	}
+}
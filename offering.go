@@ -28,17 +28,23 @@ import (
 // its endpoints, license and price. In addition this is how offerings specify
 // that they are active.
 type OfferingDescription struct {
-	providerID    string
-	LocalID       string
-	Name          string
-	Category      string
-	Inputs        []DataField
-	Outputs       []DataField
-	Endpoints     []Endpoint
-	SpatialExtent *SpatialExtent
-	License       License
-	Price         Price
-	Activation    *Activation
+	providerID     string
+	LocalID        string
+	Name           string
+	Category       string
+	Inputs         []DataField
+	Outputs        []DataField
+	Endpoints      []Endpoint
+	SpatialExtent  *SpatialExtent
+	TemporalExtent *TemporalExtent
+	License        License
+	Price          Price
+	Activation     *Activation
+}
+
+// operationName is our implementation of operation for OfferingDescription.
+func (o *OfferingDescription) operationName() string {
+	return OperationAddOffering
 }
 
 // serialize attempts to serialize it into the string form that the marketplace
@@ -55,9 +61,10 @@ func (o *OfferingDescription) serialize(clock Clock) string {
 	buf.WriteString(o.LocalID)
 	buf.WriteString(`", name: "`)
 	buf.WriteString(o.Name)
+	buf.WriteString(`"`)
 
 	if o.Activation != nil {
-		buf.WriteString(`", activation: `)
+		buf.WriteString(`, activation: `)
 		buf.WriteString(o.Activation.serialize(clock))
 	}
 
@@ -112,19 +119,30 @@ func (o *OfferingDescription) serialize(clock Clock) string {
 		buf.WriteString(o.SpatialExtent.serialize(clock))
 	}
 
+	if o.TemporalExtent != nil {
+		buf.WriteString(`, temporalExtent: `)
+		buf.WriteString(o.TemporalExtent.serialize(clock))
+	}
+
 	buf.WriteString(` } )`)
 
 	// desired returned output
-	buf.WriteString(` { id name activation { status expirationTime } } }`)
+	buf.WriteString(` `)
+	buf.WriteString(offeringSelection)
+	buf.WriteString(` }`)
 
 	return buf.String()
 }
 
 // DataField captures information about an offering's inputs or outputs. Used
-// when creating an offering.
+// when creating an offering. The Datatype and Required fields are not sent to
+// the marketplace, but are used when decoding the inputs sent by consumers. If
+// Datatype is empty we attempt to infer it from the RdfURI.
 type DataField struct {
-	Name   string
-	RdfURI string
+	Name     string
+	RdfURI   string
+	Datatype string
+	Required bool
 }
 
 // serialize is our implementation of Serializable for DataField. Serializes
@@ -164,10 +182,15 @@ func (e *Endpoint) serialize(clock Clock) string {
 }
 
 // SpatialExtent is how the BIG IoT marketplace defines geographical constraints when
-// registering an offering.
+// registering an offering. The area may be given either as a BoundingBox or as
+// a Polygon (the outer ring of a polygon as a list of vertices). The
+// marketplace only accepts bounding boxes, so if only a Polygon is given its
+// envelope is sent as the boundary, while the Polygon itself is used locally
+// for containment tests.
 type SpatialExtent struct {
 	City        string
 	BoundingBox *BoundingBox
+	Polygon     []Location
 }
 
 // serialize is our implementation of serializable - to convert into BIG IoT
@@ -179,9 +202,43 @@ func (a *SpatialExtent) serialize(clock Clock) string {
 	buf.WriteString(a.City)
 	buf.WriteString(`"`)
 
-	if a.BoundingBox != nil {
+	if boundary := a.Bounds(); boundary != nil {
 		buf.WriteString(`, boundary: `)
-		buf.WriteString(a.BoundingBox.serialize(clock))
+		buf.WriteString(boundary.serialize(clock))
+	}
+
+	buf.WriteString(` }`)
+
+	return buf.String()
+}
+
+// TemporalExtent is used to represent the period of time for which an offering
+// provides data, for example an offering of historical data. Either end may be
+// left as the zero time to indicate an open ended period.
+type TemporalExtent struct {
+	From time.Time
+	To   time.Time
+}
+
+// serialize is our implementation of serializable - to convert into BIG IoT
+// graphql form. Times are sent as epoch milliseconds, and zero times are
+// omitted.
+func (t *TemporalExtent) serialize(clock Clock) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(`{`)
+
+	if !t.From.IsZero() {
+		buf.WriteString(` from: `)
+		buf.WriteString(ToEpochMs(t.From))
+	}
+
+	if !t.To.IsZero() {
+		if !t.From.IsZero() {
+			buf.WriteString(`,`)
+		}
+		buf.WriteString(` to: `)
+		buf.WriteString(ToEpochMs(t.To))
 	}
 
 	buf.WriteString(` }`)
@@ -189,6 +246,34 @@ func (a *SpatialExtent) serialize(clock Clock) string {
 	return buf.String()
 }
 
+// Contains returns true if the given time falls within the temporal extent.
+// Open ends of the extent are treated as unbounded.
+func (t *TemporalExtent) Contains(tm time.Time) bool {
+	if !t.From.IsZero() && tm.Before(t.From) {
+		return false
+	}
+
+	if !t.To.IsZero() && tm.After(t.To) {
+		return false
+	}
+
+	return true
+}
+
+// Overlaps returns true if the two temporal extents share any period of time.
+// Open ends of either extent are treated as unbounded.
+func (t *TemporalExtent) Overlaps(other *TemporalExtent) bool {
+	if !t.From.IsZero() && !other.To.IsZero() && other.To.Before(t.From) {
+		return false
+	}
+
+	if !t.To.IsZero() && !other.From.IsZero() && other.From.After(t.To) {
+		return false
+	}
+
+	return true
+}
+
 // BoundingBox is used to represent a geographical bounding box within which an
 // offering provides data. It contains two locations representing opposite
 // corners of a geospatial box.
@@ -249,20 +334,27 @@ func (p *Price) serialize(clock Clock) string {
 	return buf.String()
 }
 
-// Money is used to capture price information for the offering. Note we aren't
-// using precise numeric types here so this is not suitable for precision
-// calculations.
+// Money is used to capture price information for the offering. The amount is
+// an exact decimal, so very small prices (for example per byte prices) are
+// represented and serialized without rounding.
 type Money struct {
-	Amount   float64 // TODO: look at more precise numeric type here
+	Amount   Amount
 	Currency Currency
 }
 
+// String returns the money formatted for display, e.g. "1.50 CHF". The amount
+// is written with at least the number of decimal places given by the
+// currency's exponent, but is never rounded.
+func (m Money) String() string {
+	return m.Amount.StringFixed(m.Currency.Exponent()) + " " + m.Currency.String()
+}
+
 // serialize is our implementation of Serializable for Money objects.
 func (m *Money) serialize(clock Clock) string {
 	var buf bytes.Buffer
 
 	buf.WriteString(`{ amount: `)
-	buf.WriteString(strconv.FormatFloat(m.Amount, 'g', -1, 64))
+	buf.WriteString(m.Amount.StringFixed(m.Currency.Exponent()))
 	buf.WriteString(`, currency: `)
 	buf.WriteString(m.Currency.String())
 	buf.WriteString(` }`)
@@ -305,6 +397,18 @@ func (a *Activation) serialize(clock Clock) string {
 	return buf.String()
 }
 
+// MarshalJSON is an implementation of the json Marshaler interface, encoding
+// the expiration time as epoch milliseconds to match UnmarshalJSON.
+func (a Activation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Status         bool  `json:"status"`
+		ExpirationTime int64 `json:"expirationTime"`
+	}{
+		Status:         a.Status,
+		ExpirationTime: epochMs(a.ExpirationTime),
+	})
+}
+
 // UnmarshalJSON is an implementation of the json Unmarshaler interface. We add
 // a custom implementation to handle converting timestamps from epoch
 // milliseconds into golang time.Time objects.
@@ -321,18 +425,286 @@ func (a *Activation) UnmarshalJSON(b []byte) error {
 	}
 
 	a.Status = d.Status
-	a.ExpirationTime = FromEpochMs(d.ExpirationTime)
+
+	if d.ExpirationTime != 0 {
+		a.ExpirationTime = FromEpochMs(d.ExpirationTime)
+	}
 
 	return nil
 }
 
+// offeringSelection is the selection set we request whenever the marketplace
+// returns an offering, so that callers get back everything the marketplace
+// actually stored.
+const offeringSelection = `{ id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated }`
+
 // Offering is an output type used when returning information about an offering.
 // This can happen either after creating an offering or if we get information on
 // an offering from the marketplace.
 type Offering struct {
-	ID         string     `json:"id"`
-	Name       string     `json:"name"`
-	Activation Activation `json:"activation"`
+	ID             string           `json:"id"`
+	Name           string           `json:"name"`
+	Category       string           `json:"rdfUri"`
+	Inputs         []DataField      `json:"inputs"`
+	Outputs        []DataField      `json:"outputs"`
+	Endpoints      []Endpoint       `json:"endpoints"`
+	License        License          `json:"license"`
+	Price          Price            `json:"price"`
+	SpatialExtent  *SpatialExtent   `json:"spatialExtent"`
+	TemporalExtent *TemporalExtent  `json:"temporalExtent"`
+	Activation     Activation       `json:"activation"`
+	Provider       OfferingProvider `json:"provider"`
+	Created        time.Time        `json:"created"`
+	LastUpdated    time.Time        `json:"lastUpdated"`
+}
+
+// IsActive returns true if the offering's activation status is set and its
+// expiration time is after the current time of the given clock. If clock is
+// nil the current time is used.
+func (o *Offering) IsActive(clock Clock) bool {
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	return o.Activation.Status && o.Activation.ExpirationTime.After(clock.Now())
+}
+
+// OfferingProvider is an output type containing information about the provider
+// that registered an offering.
+type OfferingProvider struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Organization Organization `json:"organization"`
+}
+
+// Organization is an output type containing information about the
+// organization a provider belongs to.
+type Organization struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// offeringJSON is the structure of an offering as returned by the marketplace,
+// which is used both for decoding marketplace responses and for encoding
+// offerings in the same form. Enumerated values are kept as strings so that
+// values we don't recognise can be mapped to their Unknown value, and times
+// are epoch milliseconds.
+type offeringJSON struct {
+	ID             string              `json:"id"`
+	Name           string              `json:"name"`
+	RdfURI         string              `json:"rdfUri"`
+	Inputs         []dataFieldJSON     `json:"inputs"`
+	Outputs        []dataFieldJSON     `json:"outputs"`
+	Endpoints      []endpointJSON      `json:"endpoints"`
+	License        string              `json:"license"`
+	Price          *priceJSON          `json:"price,omitempty"`
+	SpatialExtent  *spatialExtentJSON  `json:"spatialExtent,omitempty"`
+	TemporalExtent *temporalExtentJSON `json:"temporalExtent,omitempty"`
+	Activation     Activation          `json:"activation"`
+	Provider       OfferingProvider    `json:"provider"`
+	Created        int64               `json:"created,omitempty"`
+	LastUpdated    int64               `json:"lastUpdated,omitempty"`
+}
+
+// dataFieldJSON is the structure of a DataField within an offeringJSON.
+type dataFieldJSON struct {
+	Name   string `json:"name"`
+	RdfURI string `json:"rdfUri"`
+}
+
+// endpointJSON is the structure of an Endpoint within an offeringJSON.
+type endpointJSON struct {
+	URI                 string `json:"uri"`
+	EndpointType        string `json:"endpointType"`
+	AccessInterfaceType string `json:"accessInterfaceType"`
+}
+
+// priceJSON is the structure of a Price within an offeringJSON.
+type priceJSON struct {
+	Money struct {
+		Amount   Amount `json:"amount"`
+		Currency string `json:"currency"`
+	} `json:"money"`
+	PricingModel string `json:"pricingModel"`
+}
+
+// locationJSON is the structure of a Location within an offeringJSON.
+type locationJSON struct {
+	Lng float64 `json:"lng"`
+	Lat float64 `json:"lat"`
+}
+
+// spatialExtentJSON is the structure of a SpatialExtent within an
+// offeringJSON. Only the bounding box is supported by the marketplace, so the
+// polygon is an addition of ours which allows it to survive a round trip.
+type spatialExtentJSON struct {
+	City     string `json:"city"`
+	Boundary *struct {
+		L1 locationJSON `json:"l1"`
+		L2 locationJSON `json:"l2"`
+	} `json:"boundary,omitempty"`
+	Polygon []locationJSON `json:"polygon,omitempty"`
+}
+
+// temporalExtentJSON is the structure of a TemporalExtent within an
+// offeringJSON.
+type temporalExtentJSON struct {
+	From int64 `json:"from,omitempty"`
+	To   int64 `json:"to,omitempty"`
+}
+
+// MarshalJSON is an implementation of the json Marshaler interface. Offerings
+// are encoded in the same form the marketplace returns them, so that an
+// encoded offering can be decoded again by UnmarshalJSON.
+func (o Offering) MarshalJSON() ([]byte, error) {
+	d := offeringJSON{
+		ID:          o.ID,
+		Name:        o.Name,
+		RdfURI:      o.Category,
+		License:     string(o.License),
+		Activation:  o.Activation,
+		Provider:    o.Provider,
+		Created:     epochMs(o.Created),
+		LastUpdated: epochMs(o.LastUpdated),
+	}
+
+	for _, input := range o.Inputs {
+		d.Inputs = append(d.Inputs, dataFieldJSON{Name: input.Name, RdfURI: input.RdfURI})
+	}
+
+	for _, output := range o.Outputs {
+		d.Outputs = append(d.Outputs, dataFieldJSON{Name: output.Name, RdfURI: output.RdfURI})
+	}
+
+	for _, endpoint := range o.Endpoints {
+		d.Endpoints = append(d.Endpoints, endpointJSON{
+			URI:                 endpoint.URI,
+			EndpointType:        string(endpoint.EndpointType),
+			AccessInterfaceType: string(endpoint.AccessInterfaceType),
+		})
+	}
+
+	if o.Price != (Price{}) {
+		d.Price = &priceJSON{PricingModel: string(o.Price.PricingModel)}
+		d.Price.Money.Amount = o.Price.Money.Amount
+		d.Price.Money.Currency = string(o.Price.Money.Currency)
+	}
+
+	if o.SpatialExtent != nil {
+		d.SpatialExtent = &spatialExtentJSON{City: o.SpatialExtent.City}
+
+		if box := o.SpatialExtent.BoundingBox; box != nil {
+			d.SpatialExtent.Boundary = &struct {
+				L1 locationJSON `json:"l1"`
+				L2 locationJSON `json:"l2"`
+			}{
+				L1: locationJSON{Lng: box.Location1.Lng, Lat: box.Location1.Lat},
+				L2: locationJSON{Lng: box.Location2.Lng, Lat: box.Location2.Lat},
+			}
+		}
+
+		for _, l := range o.SpatialExtent.Polygon {
+			d.SpatialExtent.Polygon = append(d.SpatialExtent.Polygon, locationJSON{Lng: l.Lng, Lat: l.Lat})
+		}
+	}
+
+	if o.TemporalExtent != nil {
+		d.TemporalExtent = &temporalExtentJSON{
+			From: epochMs(o.TemporalExtent.From),
+			To:   epochMs(o.TemporalExtent.To),
+		}
+	}
+
+	return json.Marshal(d)
+}
+
+// UnmarshalJSON is an implementation of the json Unmarshaler interface. We add
+// a custom implementation to convert timestamps from epoch milliseconds, and
+// to map any enumerated values we don't recognise to their Unknown value
+// rather than failing, so that a marketplace adding new values doesn't break
+// existing clients.
+func (o *Offering) UnmarshalJSON(b []byte) error {
+	var d offeringJSON
+
+	err := json.Unmarshal(b, &d)
+	if err != nil {
+		return errors.Wrap(err, "error unmarshalling offering type")
+	}
+
+	*o = Offering{
+		ID:         d.ID,
+		Name:       d.Name,
+		Category:   d.RdfURI,
+		Activation: d.Activation,
+		Provider:   d.Provider,
+	}
+
+	for _, input := range d.Inputs {
+		o.Inputs = append(o.Inputs, DataField{Name: input.Name, RdfURI: input.RdfURI})
+	}
+
+	for _, output := range d.Outputs {
+		o.Outputs = append(o.Outputs, DataField{Name: output.Name, RdfURI: output.RdfURI})
+	}
+
+	for _, endpoint := range d.Endpoints {
+		o.Endpoints = append(o.Endpoints, Endpoint{
+			URI:                 endpoint.URI,
+			EndpointType:        EndpointType(endpointTypeEnum.orUnknown(endpoint.EndpointType)),
+			AccessInterfaceType: AccessInterfaceType(accessInterfaceTypeEnum.orUnknown(endpoint.AccessInterfaceType)),
+		})
+	}
+
+	if d.License != "" {
+		o.License = License(licenseEnum.orUnknown(d.License))
+	}
+
+	if d.Price != nil {
+		o.Price = Price{
+			PricingModel: PricingModel(pricingModelEnum.orUnknown(d.Price.PricingModel)),
+			Money: Money{
+				Amount:   d.Price.Money.Amount,
+				Currency: Currency(currencyEnum.orUnknown(d.Price.Money.Currency)),
+			},
+		}
+	}
+
+	if d.SpatialExtent != nil {
+		o.SpatialExtent = &SpatialExtent{City: d.SpatialExtent.City}
+
+		if d.SpatialExtent.Boundary != nil {
+			o.SpatialExtent.BoundingBox = &BoundingBox{
+				Location1: Location{Lng: d.SpatialExtent.Boundary.L1.Lng, Lat: d.SpatialExtent.Boundary.L1.Lat},
+				Location2: Location{Lng: d.SpatialExtent.Boundary.L2.Lng, Lat: d.SpatialExtent.Boundary.L2.Lat},
+			}
+		}
+
+		for _, l := range d.SpatialExtent.Polygon {
+			o.SpatialExtent.Polygon = append(o.SpatialExtent.Polygon, Location{Lng: l.Lng, Lat: l.Lat})
+		}
+	}
+
+	if d.TemporalExtent != nil {
+		o.TemporalExtent = &TemporalExtent{}
+
+		if d.TemporalExtent.From != 0 {
+			o.TemporalExtent.From = FromEpochMs(d.TemporalExtent.From)
+		}
+
+		if d.TemporalExtent.To != 0 {
+			o.TemporalExtent.To = FromEpochMs(d.TemporalExtent.To)
+		}
+	}
+
+	if d.Created != 0 {
+		o.Created = FromEpochMs(d.Created)
+	}
+
+	if d.LastUpdated != 0 {
+		o.LastUpdated = FromEpochMs(d.LastUpdated)
+	}
+
+	return nil
 }
 
 // DeleteOffering is an input type used to delete or unregister an offering.
@@ -340,6 +712,11 @@ type DeleteOffering struct {
 	ID string
 }
 
+// operationName is our implementation of operation for DeleteOffering.
+func (d *DeleteOffering) operationName() string {
+	return OperationDeleteOffering
+}
+
 // serialize is our implementation of Serializable for DeleteOffering objects.
 func (d *DeleteOffering) serialize(clock Clock) string {
 	var buf bytes.Buffer
@@ -358,6 +735,11 @@ type ActivateOffering struct {
 	Duration       time.Duration
 }
 
+// operationName is our implementation of operation for ActivateOffering.
+func (a *ActivateOffering) operationName() string {
+	return OperationActivateOffering
+}
+
 // serialize is our implementation of the serializable interface
 func (a *ActivateOffering) serialize(clock Clock) string {
 	var (
@@ -378,7 +760,33 @@ func (a *ActivateOffering) serialize(clock Clock) string {
 		expirationTime = a.ExpirationTime
 	}
 	buf.WriteString(ToEpochMs(expirationTime))
-	buf.WriteString(` } ) { id activation { status expirationTime } } }`)
+	buf.WriteString(` } ) `)
+	buf.WriteString(offeringSelection)
+	buf.WriteString(` }`)
+
+	return buf.String()
+}
+
+// deactivateOffering is the unexported input type used to deactivate an
+// offering by means of DeactivateOffering.
+type deactivateOffering struct {
+	id string
+}
+
+// operationName is our implementation of operation for deactivateOffering.
+func (d *deactivateOffering) operationName() string {
+	return OperationDeactivateOffering
+}
+
+// serialize is our implementation of the serializable interface
+func (d *deactivateOffering) serialize(clock Clock) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(`mutation deactivateOffering { deactivateOffering ( input: { id: "`)
+	buf.WriteString(d.id)
+	buf.WriteString(`" } ) `)
+	buf.WriteString(offeringSelection)
+	buf.WriteString(` }`)
 
 	return buf.String()
 }
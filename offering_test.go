@@ -1,6 +1,7 @@
 package bigiot
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -69,7 +70,7 @@ func TestActivateOffering(t *testing.T) {
 				ID:             "Organisation-Provider-Offering",
 				ExpirationTime: clock.Now().Add(10 * time.Minute),
 			},
-			expected: fmt.Sprintf(`mutation activateOffering { activateOffering ( input: { id: "Organisation-Provider-Offering", expirationTime: %v } ) { id activation { status expirationTime } } }`, ToEpochMs(clock.Now().Add(10*time.Minute))),
+			expected: fmt.Sprintf(`mutation activateOffering { activateOffering ( input: { id: "Organisation-Provider-Offering", expirationTime: %v } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }`, ToEpochMs(clock.Now().Add(10*time.Minute))),
 		},
 		{
 			label: "with duration",
@@ -77,14 +78,14 @@ func TestActivateOffering(t *testing.T) {
 				ID:       "Organisation-Provider-Offering",
 				Duration: 15 * time.Minute,
 			},
-			expected: fmt.Sprintf(`mutation activateOffering { activateOffering ( input: { id: "Organisation-Provider-Offering", expirationTime: %v } ) { id activation { status expirationTime } } }`, ToEpochMs(clock.Now().Add(15*time.Minute))),
+			expected: fmt.Sprintf(`mutation activateOffering { activateOffering ( input: { id: "Organisation-Provider-Offering", expirationTime: %v } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }`, ToEpochMs(clock.Now().Add(15*time.Minute))),
 		},
 		{
 			label: "with neither",
 			input: ActivateOffering{
 				ID: "Organisation-Provider-Offering",
 			},
-			expected: fmt.Sprintf(`mutation activateOffering { activateOffering ( input: { id: "Organisation-Provider-Offering", expirationTime: %v } ) { id activation { status expirationTime } } }`, ToEpochMs(clock.Now().Add(10*time.Minute))),
+			expected: fmt.Sprintf(`mutation activateOffering { activateOffering ( input: { id: "Organisation-Provider-Offering", expirationTime: %v } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }`, ToEpochMs(clock.Now().Add(10*time.Minute))),
 		},
 	}
 
@@ -96,6 +97,42 @@ func TestActivateOffering(t *testing.T) {
 	}
 }
 
+func TestDeactivateOffering(t *testing.T) {
+	input := deactivateOffering{id: "Organisation-Provider-Offering"}
+
+	expected := `mutation deactivateOffering { deactivateOffering ( input: { id: "Organisation-Provider-Offering" } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }`
+
+	assert.Equal(t, expected, input.serialize(mocks.Clock{T: time.Now()}))
+}
+
+func TestOfferingIsActive(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	clock := mocks.Clock{T: now}
+
+	testcases := []struct {
+		label      string
+		activation Activation
+		expected   bool
+	}{
+		{"active", Activation{Status: true, ExpirationTime: now.Add(time.Minute)}, true},
+		{"expired", Activation{Status: true, ExpirationTime: now.Add(-time.Minute)}, false},
+		{"expiring now", Activation{Status: true, ExpirationTime: now}, false},
+		{"deactivated", Activation{Status: false, ExpirationTime: now.Add(time.Minute)}, false},
+		{"no expiration time", Activation{Status: true}, false},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			offering := Offering{Activation: testcase.activation}
+			assert.Equal(t, testcase.expected, offering.IsActive(clock))
+		})
+	}
+
+	// a nil clock uses the current time
+	offering := Offering{Activation: Activation{Status: true, ExpirationTime: time.Now().Add(time.Minute)}}
+	assert.True(t, offering.IsActive(nil))
+}
+
 func TestSerializeLocation(t *testing.T) {
 	clock := mocks.Clock{
 		T: time.Now(),
@@ -128,6 +165,90 @@ func TestSerializeBoundingBox(t *testing.T) {
 	assert.Equal(t, `{ l1: { lng: 23.2, lat: 45.2 }, l2: { lng: 24.2, lat: 46.2 } }`, bb.serialize(clock))
 }
 
+func TestSerializeMoney(t *testing.T) {
+	clock := mocks.Clock{
+		T: time.Now(),
+	}
+
+	testcases := []struct {
+		money    Money
+		expected string
+	}{
+		{
+			money:    Money{Amount: NewAmount(1, 0), Currency: CHF},
+			expected: `{ amount: 1.00, currency: CHF }`,
+		},
+		{
+			money:    Money{Amount: MustParseAmount("0.001"), Currency: GBP},
+			expected: `{ amount: 0.001, currency: GBP }`,
+		},
+		{
+			money:    Money{Amount: NewAmount(150, 0), Currency: "JPY"},
+			expected: `{ amount: 150, currency: JPY }`,
+		},
+		{
+			money:    Money{Amount: MustParseAmount("1.5"), Currency: "BHD"},
+			expected: `{ amount: 1.500, currency: BHD }`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		assert.Equal(t, testcase.expected, testcase.money.serialize(clock))
+	}
+}
+
+func TestSerializeTemporalExtent(t *testing.T) {
+	clock := mocks.Clock{
+		T: time.Now(),
+	}
+
+	from := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		label    string
+		extent   TemporalExtent
+		expected string
+	}{
+		{
+			label:    "closed",
+			extent:   TemporalExtent{From: from, To: to},
+			expected: `{ from: 1483228800000, to: 1514764800000 }`,
+		},
+		{
+			label:    "open ended",
+			extent:   TemporalExtent{From: from},
+			expected: `{ from: 1483228800000 }`,
+		},
+		{
+			label:    "open start",
+			extent:   TemporalExtent{To: to},
+			expected: `{ to: 1514764800000 }`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			assert.Equal(t, testcase.expected, testcase.extent.serialize(clock))
+		})
+	}
+}
+
+func TestTemporalExtentContains(t *testing.T) {
+	from := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	closed := TemporalExtent{From: from, To: to}
+	assert.True(t, closed.Contains(from))
+	assert.True(t, closed.Contains(from.Add(24*time.Hour)))
+	assert.False(t, closed.Contains(from.Add(-time.Second)))
+	assert.False(t, closed.Contains(to.Add(time.Second)))
+
+	open := TemporalExtent{From: from}
+	assert.True(t, open.Contains(to.Add(24*time.Hour)))
+	assert.False(t, open.Contains(from.Add(-time.Second)))
+}
+
 func TestSerializeSpatialExtent(t *testing.T) {
 	clock := mocks.Clock{
 		T: time.Now(),
@@ -162,6 +283,18 @@ func TestSerializeSpatialExtent(t *testing.T) {
 			},
 			expected: `{ city: "Edinburgh", boundary: { l1: { lng: 23.2, lat: 45.2 }, l2: { lng: 24.2, lat: 46.2 } } }`,
 		},
+		{
+			label: "with polygon",
+			input: SpatialExtent{
+				City: "Edinburgh",
+				Polygon: []Location{
+					{Lng: 23.2, Lat: 45.2},
+					{Lng: 24.2, Lat: 45.7},
+					{Lng: 23.7, Lat: 46.2},
+				},
+			},
+			expected: `{ city: "Edinburgh", boundary: { l1: { lng: 23.2, lat: 45.2 }, l2: { lng: 24.2, lat: 46.2 } } }`,
+		},
 	}
 
 	for _, testcase := range testcases {
@@ -203,7 +336,7 @@ func TestSerializeOfferingDescription(t *testing.T) {
 				License: OpenDataLicense,
 				Price: Price{
 					Money: Money{
-						Amount:   0.001,
+						Amount:   MustParseAmount("0.001"),
 						Currency: EUR,
 					},
 					PricingModel: PerAccess,
@@ -216,7 +349,7 @@ func TestSerializeOfferingDescription(t *testing.T) {
 					Duration: duration,
 				},
 			},
-			expected: `mutation addOffering { addOffering ( input: { id: "", localId: "TestOffering", name: "Test Offering", activation: { status: true, expirationTime: 600000 }, rdfUri: "urn:proposed:RandomValues", outputs: [{ name: "value", rdfUri: "schema:random" }], endpoints: [{ uri: "https://example.com/random", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB }], license: OPEN_DATA_LICENSE, price: { money: { amount: 0.001, currency: EUR }, pricingModel: PER_ACCESS }, spatialExtent: { city: "Berlin" } } ) { id name activation { status expirationTime } } }`,
+			expected: `mutation addOffering { addOffering ( input: { id: "", localId: "TestOffering", name: "Test Offering", activation: { status: true, expirationTime: 600000 }, rdfUri: "urn:proposed:RandomValues", outputs: [{ name: "value", rdfUri: "schema:random" }], endpoints: [{ uri: "https://example.com/random", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB }], license: OPEN_DATA_LICENSE, price: { money: { amount: 0.001, currency: EUR }, pricingModel: PER_ACCESS }, spatialExtent: { city: "Berlin" } } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }`,
 		},
 		{
 			label: "duration no bounding",
@@ -246,7 +379,7 @@ func TestSerializeOfferingDescription(t *testing.T) {
 				License: OpenDataLicense,
 				Price: Price{
 					Money: Money{
-						Amount:   0.001,
+						Amount:   MustParseAmount("0.001"),
 						Currency: EUR,
 					},
 					PricingModel: PerAccess,
@@ -269,9 +402,27 @@ func TestSerializeOfferingDescription(t *testing.T) {
 					Duration: duration,
 				},
 			},
-			expected: `mutation addOffering { addOffering ( input: { id: "", localId: "TestOffering", name: "Test Offering", activation: { status: true, expirationTime: 600000 }, rdfUri: "urn:proposed:RandomValues", inputs: [{ name: "value", rdfUri: "schema:random" }], outputs: [{ name: "value", rdfUri: "schema:random" }], endpoints: [{ uri: "https://example.com/random", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB }], license: OPEN_DATA_LICENSE, price: { money: { amount: 0.001, currency: EUR }, pricingModel: PER_ACCESS }, spatialExtent: { city: "Berlin", boundary: { l1: { lng: 0, lat: 0 }, l2: { lng: 1, lat: 1 } } } } ) { id name activation { status expirationTime } } }`,
+			expected: `mutation addOffering { addOffering ( input: { id: "", localId: "TestOffering", name: "Test Offering", activation: { status: true, expirationTime: 600000 }, rdfUri: "urn:proposed:RandomValues", inputs: [{ name: "value", rdfUri: "schema:random" }], outputs: [{ name: "value", rdfUri: "schema:random" }], endpoints: [{ uri: "https://example.com/random", endpointType: HTTP_GET, accessInterfaceType: BIGIOT_LIB }], license: OPEN_DATA_LICENSE, price: { money: { amount: 0.001, currency: EUR }, pricingModel: PER_ACCESS }, spatialExtent: { city: "Berlin", boundary: { l1: { lng: 0, lat: 0 }, l2: { lng: 1, lat: 1 } } } } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }`,
 		},
-	}
+		{
+			label: "temporal extent",
+			input: &OfferingDescription{
+				LocalID:  "HistoricalOffering",
+				Name:     "Historical Offering",
+				Category: "urn:proposed:RandomValues",
+				License:  OpenDataLicense,
+				Price: Price{
+					Money: Money{
+						Currency: EUR,
+					},
+					PricingModel: Free,
+				},
+				TemporalExtent: &TemporalExtent{
+					From: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			expected: `mutation addOffering { addOffering ( input: { id: "", localId: "HistoricalOffering", name: "Historical Offering", rdfUri: "urn:proposed:RandomValues", license: OPEN_DATA_LICENSE, price: { money: { amount: 0.00, currency: EUR }, pricingModel: FREE }, temporalExtent: { from: 1483228800000 } } ) { id name rdfUri inputs { name rdfUri } outputs { name rdfUri } endpoints { uri endpointType accessInterfaceType } license price { money { amount currency } pricingModel } spatialExtent { city boundary { l1 { lng lat } l2 { lng lat } } } temporalExtent { from to } activation { status expirationTime } provider { id name organization { id name } } created lastUpdated } }`,
+		}}
 
 	for _, testcase := range testcases {
 		t.Run(testcase.label, func(t *testing.T) {
@@ -279,3 +430,167 @@ func TestSerializeOfferingDescription(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalOffering(t *testing.T) {
+	input := `{
+		"id": "Organization-Provider-Offering",
+		"name": "Test Offering",
+		"rdfUri": "urn:proposed:RandomValues",
+		"inputs": [{ "name": "latitude", "rdfUri": "schema:latitude" }],
+		"outputs": [{ "name": "value", "rdfUri": "schema:random" }],
+		"endpoints": [
+			{ "uri": "https://example.com/random", "endpointType": "HTTP_GET", "accessInterfaceType": "BIGIOT_LIB" },
+			{ "uri": "coap://example.com/random", "endpointType": "COAP", "accessInterfaceType": "EXTERNAL" }
+		],
+		"license": "OPEN_DATA_LICENSE",
+		"price": { "money": { "amount": 0.001, "currency": "EUR" }, "pricingModel": "PER_ACCESS" },
+		"spatialExtent": { "city": "Berlin", "boundary": { "l1": { "lng": 13.1, "lat": 52.3 }, "l2": { "lng": 13.7, "lat": 52.7 } } },
+		"temporalExtent": { "from": 1483228800000, "to": null },
+		"activation": { "status": true, "expirationTime": 1509983101577 },
+		"provider": { "id": "Organization-Provider", "name": "Provider", "organization": { "id": "Organization", "name": "Organization" } },
+		"created": 1509982501577,
+		"lastUpdated": 1509982801577
+	}`
+
+	var offering Offering
+	err := json.Unmarshal([]byte(input), &offering)
+	assert.Nil(t, err)
+
+	assert.Equal(t, Offering{
+		ID:       "Organization-Provider-Offering",
+		Name:     "Test Offering",
+		Category: "urn:proposed:RandomValues",
+		Inputs: []DataField{
+			{Name: "latitude", RdfURI: "schema:latitude"},
+		},
+		Outputs: []DataField{
+			{Name: "value", RdfURI: "schema:random"},
+		},
+		Endpoints: []Endpoint{
+			{URI: "https://example.com/random", EndpointType: HTTPGet, AccessInterfaceType: BIGIoTLib},
+			{URI: "coap://example.com/random", EndpointType: UnknownEndpointType, AccessInterfaceType: External},
+		},
+		License: OpenDataLicense,
+		Price: Price{
+			PricingModel: PerAccess,
+			Money:        Money{Amount: MustParseAmount("0.001"), Currency: EUR},
+		},
+		SpatialExtent: &SpatialExtent{
+			City: "Berlin",
+			BoundingBox: &BoundingBox{
+				Location1: Location{Lng: 13.1, Lat: 52.3},
+				Location2: Location{Lng: 13.7, Lat: 52.7},
+			},
+		},
+		TemporalExtent: &TemporalExtent{
+			From: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Activation: Activation{
+			Status:         true,
+			ExpirationTime: FromEpochMs(1509983101577),
+		},
+		Provider: OfferingProvider{
+			ID:   "Organization-Provider",
+			Name: "Provider",
+			Organization: Organization{
+				ID:   "Organization",
+				Name: "Organization",
+			},
+		},
+		Created:     FromEpochMs(1509982501577),
+		LastUpdated: FromEpochMs(1509982801577),
+	}, offering)
+
+	// unknown enum values are tolerated, and missing fields are left empty
+	err = json.Unmarshal([]byte(`{"id": "Offering", "license": "GPL", "price": { "money": { "amount": 1, "currency": "BTC" }, "pricingModel": "PER_YEAR" }}`), &offering)
+	assert.Nil(t, err)
+	assert.Equal(t, Offering{
+		ID:      "Offering",
+		License: UnknownLicense,
+		Price: Price{
+			PricingModel: UnknownPricingModel,
+			Money:        Money{Amount: NewAmount(1, 0), Currency: UnknownCurrency},
+		},
+	}, offering)
+
+	err = json.Unmarshal([]byte(`{"id": 12}`), &offering)
+	assert.NotNil(t, err)
+}
+
+func TestMarshalOfferingRoundTrip(t *testing.T) {
+	offerings := []Offering{
+		{},
+		{
+			ID:       "Organization-Provider-Offering",
+			Name:     "Test Offering",
+			Category: "urn:proposed:RandomValues",
+			Inputs: []DataField{
+				{Name: "latitude", RdfURI: "schema:latitude"},
+			},
+			Outputs: []DataField{
+				{Name: "value", RdfURI: "schema:random"},
+			},
+			Endpoints: []Endpoint{
+				{URI: "https://example.com/random", EndpointType: HTTPGet, AccessInterfaceType: BIGIoTLib},
+				{URI: "coap://example.com/random", EndpointType: UnknownEndpointType, AccessInterfaceType: External},
+			},
+			License: OpenDataLicense,
+			Price: Price{
+				PricingModel: PerByte,
+				Money:        Money{Amount: MustParseAmount("0.000001"), Currency: EUR},
+			},
+			SpatialExtent: &SpatialExtent{
+				City: "Berlin",
+				BoundingBox: &BoundingBox{
+					Location1: Location{Lng: 13.1, Lat: 52.3},
+					Location2: Location{Lng: 13.7, Lat: 52.7},
+				},
+			},
+			TemporalExtent: &TemporalExtent{
+				To: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Activation: Activation{
+				Status:         true,
+				ExpirationTime: FromEpochMs(1509983101577),
+			},
+			Provider: OfferingProvider{
+				ID:           "Organization-Provider",
+				Name:         "Provider",
+				Organization: Organization{ID: "Organization", Name: "Organization"},
+			},
+			Created:     FromEpochMs(1509982501577),
+			LastUpdated: FromEpochMs(1509982801577),
+		},
+		{
+			ID: "Organization-Provider-Polygon",
+			SpatialExtent: &SpatialExtent{
+				City: "Berlin",
+				Polygon: []Location{
+					{Lng: 13.1, Lat: 52.3},
+					{Lng: 13.7, Lat: 52.3},
+					{Lng: 13.4, Lat: 52.7},
+				},
+			},
+		},
+	}
+
+	for _, offering := range offerings {
+		b, err := json.Marshal(offering)
+		assert.Nil(t, err)
+
+		var decoded Offering
+		err = json.Unmarshal(b, &decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, offering, decoded)
+	}
+
+	b, err := json.Marshal(offerings[1])
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"rdfUri":"urn:proposed:RandomValues"`)
+	assert.Contains(t, string(b), `"price":{"money":{"amount":0.000001,"currency":"EUR"},"pricingModel":"PER_BYTE"}`)
+	assert.Contains(t, string(b), `"created":1509982501577`)
+
+	b, err = json.Marshal(offerings[2])
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"polygon":[{"lng":13.1,"lat":52.3},{"lng":13.7,"lat":52.3},{"lng":13.4,"lat":52.7}]`)
+}
@@ -0,0 +1,190 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Namespaces of the vocabularies commonly used in RdfURI values.
+const (
+	RDFNamespace    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	RDFSNamespace   = "http://www.w3.org/2000/01/rdf-schema#"
+	XSDNamespace    = "http://www.w3.org/2001/XMLSchema#"
+	SchemaNamespace = "http://schema.org/"
+	BigIoTNamespace = "http://schema.big-iot.org/core/"
+)
+
+// DefaultPrefixes returns the prefixes commonly used in RdfURI values, mapped
+// to the namespaces they abbreviate: rdf, rdfs, xsd, schema and bigiot. These
+// are defined within the JSON-LD context of records returned by JSONLD, and
+// are used by the rdf package.
+func DefaultPrefixes() map[string]string {
+	return map[string]string{
+		"rdf":    RDFNamespace,
+		"rdfs":   RDFSNamespace,
+		"xsd":    XSDNamespace,
+		"schema": SchemaNamespace,
+		"bigiot": BigIoTNamespace,
+	}
+}
+
+// EncodeRecords converts the given value into a slice of output records keyed
+// by the names of the declared outputs. The value may be a Record, a map with
+// string keys, a struct or a pointer to a struct, or a slice or array of any
+// of these. Struct fields are matched to outputs using the name given in a
+// "bigiot" struct tag, falling back to the field name, and fields tagged with
+// "-" are ignored. Any fields not declared as outputs are dropped, and an
+// error is returned if any record is missing a declared output. If no outputs
+// are declared every field is included.
+//
+// Example:
+//		type parking struct {
+//			Coordinates string `bigiot:"geoCoordinates"`
+//		}
+//
+//		records, err := bigiot.EncodeRecords(description.Outputs, []parking{...})
+func EncodeRecords(outputs []DataField, v interface{}) ([]Record, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return []Record{}, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if !rv.IsValid() {
+		return []Record{}, nil
+	}
+
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		record, err := encodeRecord(outputs, rv)
+		if err != nil {
+			return nil, err
+		}
+
+		return []Record{record}, nil
+	}
+
+	records := make([]Record, 0, rv.Len())
+
+	for i := 0; i < rv.Len(); i++ {
+		record, err := encodeRecord(outputs, rv.Index(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error encoding record %d", i)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// JSONLD returns the given records as a JSON-LD document, with an @context
+// mapping each of the declared outputs to its RdfURI, and the records
+// contained in the @graph. The returned value can be passed directly to
+// json.Marshal.
+func JSONLD(outputs []DataField, records []Record) map[string]interface{} {
+	jsonContext := make(map[string]interface{}, len(outputs))
+	prefixes := DefaultPrefixes()
+
+	for _, output := range outputs {
+		if output.RdfURI == "" {
+			continue
+		}
+
+		jsonContext[output.Name] = output.RdfURI
+
+		if idx := strings.Index(output.RdfURI, ":"); idx != -1 {
+			prefix := output.RdfURI[:idx]
+			if iri, ok := prefixes[prefix]; ok {
+				jsonContext[prefix] = iri
+			}
+		}
+	}
+
+	graph := make([]interface{}, len(records))
+	for i, record := range records {
+		graph[i] = record
+	}
+
+	return map[string]interface{}{
+		"@context": jsonContext,
+		"@graph":   graph,
+	}
+}
+
+// encodeRecord converts a single map or struct into a Record.
+func encodeRecord(outputs []DataField, rv reflect.Value) (Record, error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, errors.New("record is nil")
+		}
+		rv = rv.Elem()
+	}
+
+	fields := make(Record)
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, errors.Errorf("unsupported record type %s", rv.Type())
+		}
+
+		for _, key := range rv.MapKeys() {
+			fields[key.String()] = rv.MapIndex(key).Interface()
+		}
+
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			if field.PkgPath != "" {
+				// unexported field
+				continue
+			}
+
+			name := fieldName(field)
+			if name == "" {
+				continue
+			}
+
+			fields[name] = rv.Field(i).Interface()
+		}
+
+	default:
+		return nil, errors.Errorf("unsupported record type %s", rv.Type())
+	}
+
+	if len(outputs) == 0 {
+		return fields, nil
+	}
+
+	record := make(Record, len(outputs))
+
+	for _, output := range outputs {
+		v, ok := fields[output.Name]
+		if !ok {
+			return nil, errors.Errorf("missing declared output %s", output.Name)
+		}
+
+		record[output.Name] = v
+	}
+
+	return record, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+func TestEncodeRecords(t *testing.T) {
+	outputs := []bigiot.DataField{
+		{Name: "longitude", RdfURI: "schema:longitude"},
+		{Name: "latitude", RdfURI: "schema:latitude"},
+	}
+
+	type location struct {
+		Lng      float64 `bigiot:"longitude"`
+		Lat      float64 `bigiot:"latitude"`
+		Internal string  `bigiot:"-"`
+		Label    string
+		private  string
+	}
+
+	testcases := []struct {
+		label       string
+		outputs     []bigiot.DataField
+		input       interface{}
+		expected    []bigiot.Record
+		expectedErr string
+	}{
+		{
+			label:    "records",
+			outputs:  outputs,
+			input:    []bigiot.Record{{"longitude": 2.33, "latitude": 54.5, "extra": true}},
+			expected: []bigiot.Record{{"longitude": 2.33, "latitude": 54.5}},
+		},
+		{
+			label:    "single struct",
+			outputs:  outputs,
+			input:    location{Lng: 2.33, Lat: 54.5, Internal: "x"},
+			expected: []bigiot.Record{{"longitude": 2.33, "latitude": 54.5}},
+		},
+		{
+			label:   "slice of struct pointers",
+			outputs: outputs,
+			input:   []*location{{Lng: 2.33, Lat: 54.5}, {Lng: 2.38, Lat: 54.53}},
+			expected: []bigiot.Record{
+				{"longitude": 2.33, "latitude": 54.5},
+				{"longitude": 2.38, "latitude": 54.53},
+			},
+		},
+		{
+			label:    "no declared outputs",
+			input:    location{Lng: 2.33, Lat: 54.5, Internal: "x", Label: "a", private: "b"},
+			expected: []bigiot.Record{{"longitude": 2.33, "latitude": 54.5, "Label": "a"}},
+		},
+		{
+			label:    "nil",
+			outputs:  outputs,
+			input:    nil,
+			expected: []bigiot.Record{},
+		},
+		{
+			label:       "missing output",
+			outputs:     outputs,
+			input:       []map[string]interface{}{{"longitude": 2.33, "latitude": 54.5}, {"longitude": 2.33}},
+			expectedErr: "error encoding record 1: missing declared output latitude",
+		},
+		{
+			label:       "unsupported type",
+			outputs:     outputs,
+			input:       []int{1, 2},
+			expectedErr: "error encoding record 0: unsupported record type int",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			got, err := bigiot.EncodeRecords(testcase.outputs, testcase.input)
+			if testcase.expectedErr != "" {
+				assert.NotNil(t, err)
+				assert.Equal(t, testcase.expectedErr, err.Error())
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, testcase.expected, got)
+			}
+		})
+	}
+}
+
+func TestJSONLD(t *testing.T) {
+	outputs := []bigiot.DataField{
+		{Name: "longitude", RdfURI: "schema:longitude"},
+		{Name: "parking", RdfURI: "urn:big-iot:ParkingSpace"},
+		{Name: "unannotated"},
+	}
+
+	records := []bigiot.Record{
+		{"longitude": 2.33, "parking": 4, "unannotated": "x"},
+	}
+
+	b, err := json.Marshal(bigiot.JSONLD(outputs, records))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"@context":{"longitude":"schema:longitude","parking":"urn:big-iot:ParkingSpace","schema":"http://schema.org/"},"@graph":[{"longitude":2.33,"parking":4,"unannotated":"x"}]}`, string(b))
+}
@@ -16,8 +16,8 @@ package bigiot
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"time"
 
 	"github.com/pkg/errors"
 	"gopkg.in/square/go-jose.v2/jwt"
@@ -28,6 +28,7 @@ import (
 // our runtime configuration (auth credentials, base url etc.).
 type Provider struct {
 	*base
+	lifecycle *Lifecycle
 }
 
 // NewProvider instantiates and returns a configured Provider instance. The
@@ -48,7 +49,8 @@ func NewProvider(id, secret string, options ...Option) (*Provider, error) {
 // RegisterOffering allows calles to register an offering on the marketplace.
 // When registering the caller will supply an activation lifetime for the
 // Offering as part of the input AddOffering instance. The function returns a
-// populated Offering instance or nil and an error.
+// populated Offering instance or nil and an error. If the provider has a
+// Lifecycle, the offering is tracked so it can be deactivated on shutdown.
 func (p *Provider) RegisterOffering(ctx context.Context, offering *OfferingDescription) (*Offering, error) {
 	offering.providerID = p.id
 
@@ -64,6 +66,10 @@ func (p *Provider) RegisterOffering(ctx context.Context, offering *OfferingDescr
 		return nil, errors.Wrap(err, "Error unmarshalling register offering json")
 	}
 
+	if p.lifecycle != nil {
+		p.lifecycle.Track(response.Data.Offering.ID)
+	}
+
 	return &response.Data.Offering, nil
 }
 
@@ -77,6 +83,10 @@ func (p *Provider) DeleteOffering(ctx context.Context, offering *DeleteOffering)
 		return errors.Wrap(err, "Error deleting offering")
 	}
 
+	if p.lifecycle != nil {
+		p.lifecycle.Untrack(offering.ID)
+	}
+
 	return nil
 }
 
@@ -95,43 +105,105 @@ func (p *Provider) ActivateOffering(ctx context.Context, activation *ActivateOff
 	return &response.Data.Offering, nil
 }
 
+// DeactivateOffering marks the offering with the given ID as inactive on the
+// marketplace without deleting it, so that it keeps its ID and subscriptions
+// and can be activated again by means of ActivateOffering, for example after a
+// maintenance window. It returns the updated Offering, or nil and an error.
+func (p *Provider) DeactivateOffering(ctx context.Context, offeringID string) (*Offering, error) {
+	body, err := p.query(ctx, &deactivateOffering{id: offeringID})
+	if err != nil {
+		return nil, errors.Wrap(err, "error deactivating offering")
+	}
+
+	response := deactivateOfferingResponse{}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling deactivation response")
+	}
+
+	return &response.Data.Offering, nil
+}
+
 // ValidateToken takes as input a string which should be JWT token generated by
 // the marketplace and given to the client before it is allowed to access data
 // from an offering. It takes as input the encoded token string, extracts its
 // component parts and verifies the signature using the secret of the provider.
 // It returns the ID of the offering the token is for, or an empty string and an
-// error if unable to validate the token.
+// error if unable to validate the token or if the token has been revoked.
 func (p *Provider) ValidateToken(tokenStr string) (string, error) {
-	key, err := base64.StdEncoding.DecodeString(p.secret)
+	subscriber, err := p.validateSubscriber(context.Background(), tokenStr)
+	if err != nil {
+		return "", err
+	}
+
+	return subscriber.OfferingID, nil
+}
+
+// parseToken does the work of validating an incoming token string, returning
+// the full set of claims contained within the token so that callers needing
+// more than just the offering ID (i.e. the subscriber ID) can access them.
+func (p *Provider) parseToken(tokenStr string) (*claims, error) {
+	keys, err := p.keys.validationKeys(p.clock.Now())
 	if err != nil {
-		return "", errors.Wrap(err, "decoding secret failed")
+		return nil, err
 	}
 
 	token, err := jwt.ParseSigned(tokenStr)
 	if err != nil {
-		return "", errors.Wrap(err, "error parsing token string")
+		return nil, errors.Wrap(err, "error parsing token string")
+	}
+
+	cl, err := verifySignature(token, keys)
+	if err != nil {
+		return nil, err
 	}
 
-	cl := claims{}
-	err = token.Claims(key, &cl)
+	err = validateClaims(cl, p.clock.Now())
 	if err != nil {
-		return "", errors.Wrap(err, "error extracting claims from token")
+		return nil, err
+	}
+
+	// all good
+	return cl, nil
+}
+
+// verifySignature extracts the claims from a token after verifying it was
+// signed with one of the given keys. Each key is tried in turn, so that tokens
+// signed with the previous secret are accepted while it is being rotated.
+func verifySignature(token *jwt.JSONWebToken, keys [][]byte) (*claims, error) {
+	var err error
+
+	for _, key := range keys {
+		cl := &claims{}
+
+		err = token.Claims(key, cl)
+		if err == nil {
+			return cl, nil
+		}
 	}
 
+	if err == nil {
+		err = errors.New("no keys available")
+	}
+
+	return nil, errors.Wrap(err, "error extracting claims from token")
+}
+
+// validateClaims checks that the claims of a token are valid at the given time.
+func validateClaims(cl *claims, now time.Time) error {
 	// the only claim we validate for now is that the token has neither expired nor
 	// is not valid yet. Note the jwt library allows leeway of one minute before
 	// marking a token as invalid, I presume to allow for clock inconsistencies,
 	// i.e. if the token expires 17:05, then Validate will still allow it up to
 	// 17:06. The same applies before the token is technically valid.
-	err = cl.Validate(jwt.Expected{
-		Time: p.clock.Now(),
+	err := cl.Validate(jwt.Expected{
+		Time: now,
 	})
 	if err != nil {
-		return "", errors.Wrap(err, "error validating claims")
+		return errors.Wrap(err, "error validating claims")
 	}
 
-	// all good
-	return cl.SubscribableID, nil
+	return nil
 }
 
 // addOfferingResponse is a unexported type used when parsing the response from
@@ -150,6 +222,14 @@ type activateOfferingResponse struct {
 	} `json:"data"`
 }
 
+// deactivateOfferingResponse is an unexported type used when parsing the
+// response calling DeactivateOffering
+type deactivateOfferingResponse struct {
+	Data struct {
+		Offering Offering `json:"deactivateOffering"`
+	} `json:"data"`
+}
+
 // claims embeds the Claims object provided by the jwt library, but adds some
 // extra fields used by BIG IoT to identify the specific offering being
 // requested.
@@ -0,0 +1,452 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdf
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// writeJSONLD returns the graph as a JSON-LD document with an @context of the
+// graph's prefixes and an @graph of node objects. As with Turtle, blank nodes
+// referred to by a single triple are embedded within the node referring to
+// them.
+func writeJSONLD(g *Graph) ([]byte, error) {
+	w := &jsonldWriter{
+		graph:   g,
+		nested:  g.nested(),
+		written: make(map[Term]bool),
+	}
+
+	nodes := []interface{}{}
+	subjects := g.subjects()
+
+	for _, subject := range subjects {
+		if !w.nested[subject] {
+			nodes = append(nodes, w.node(subject, true))
+		}
+	}
+
+	for _, subject := range subjects {
+		if !w.written[subject] {
+			nodes = append(nodes, w.node(subject, true))
+		}
+	}
+
+	doc := map[string]interface{}{
+		"@graph": nodes,
+	}
+
+	if len(g.Prefixes) > 0 {
+		doc["@context"] = g.Prefixes
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// jsonldWriter holds the state used while writing a graph as JSON-LD.
+type jsonldWriter struct {
+	graph   *Graph
+	nested  map[Term]bool
+	written map[Term]bool
+}
+
+// node returns the node object for a subject. Embedded blank nodes are written
+// without an @id.
+func (w *jsonldWriter) node(subject Term, topLevel bool) map[string]interface{} {
+	w.written[subject] = true
+
+	node := make(map[string]interface{})
+	if topLevel || subject.Kind != BlankNode {
+		node["@id"] = w.id(subject)
+	}
+
+	var types []interface{}
+	var predicates []string
+	values := make(map[string][]interface{})
+
+	for _, t := range w.graph.Triples {
+		if t.Subject != subject {
+			continue
+		}
+
+		if t.Predicate.Value == rdfType && t.Object.Kind == IRI {
+			types = append(types, w.iri(t.Object.Value))
+			continue
+		}
+
+		key := w.iri(t.Predicate.Value)
+		if _, ok := values[key]; !ok {
+			predicates = append(predicates, key)
+		}
+		values[key] = append(values[key], w.value(t.Object))
+	}
+
+	if len(types) > 0 {
+		node["@type"] = single(types)
+	}
+
+	for _, key := range predicates {
+		node[key] = single(values[key])
+	}
+
+	return node
+}
+
+// value returns the JSON-LD form of an object.
+func (w *jsonldWriter) value(object Term) interface{} {
+	switch object.Kind {
+	case IRI:
+		return map[string]interface{}{"@id": w.iri(object.Value)}
+	case BlankNode:
+		if w.nested[object] && !w.written[object] {
+			return w.node(object, false)
+		}
+		return map[string]interface{}{"@id": w.id(object)}
+	}
+
+	if object.Language != "" {
+		return map[string]interface{}{"@value": object.Value, "@language": object.Language}
+	}
+
+	if object.Datatype != "" && object.Datatype != xsdString {
+		return map[string]interface{}{"@value": object.Value, "@type": w.iri(object.Datatype)}
+	}
+
+	return object.Value
+}
+
+// id returns the @id of an IRI or blank node.
+func (w *jsonldWriter) id(t Term) string {
+	if t.Kind == BlankNode {
+		return "_:" + t.Value
+	}
+
+	return w.iri(t.Value)
+}
+
+// iri returns the compact form of an IRI if there is one.
+func (w *jsonldWriter) iri(iri string) string {
+	if name, ok := w.graph.Compact(iri); ok {
+		return name
+	}
+
+	return iri
+}
+
+// single returns the only element of a list, or the list itself if it has
+// more than one element.
+func single(values []interface{}) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	return values
+}
+
+// parseJSONLD parses a JSON-LD document, adding the prefixes defined in its
+// @context and the triples of its nodes to the graph. Only inline contexts
+// whose entries map terms or prefixes directly to IRIs are supported.
+func parseJSONLD(b []byte, g *Graph) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	var doc interface{}
+
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return err
+	}
+
+	p := &jsonldParser{
+		graph:      g,
+		blankNodes: make(map[string]Term),
+	}
+
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		return p.document(d)
+	case []interface{}:
+		for _, item := range d {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return errors.New("expected a node object")
+			}
+
+			err = p.document(obj)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return errors.New("expected a JSON object or array")
+}
+
+// jsonldParser holds the state used while parsing a JSON-LD document.
+type jsonldParser struct {
+	graph      *Graph
+	blankNodes map[string]Term
+}
+
+// document parses a top level object, which is either a single node object or
+// an object with an @graph of node objects.
+func (p *jsonldParser) document(obj map[string]interface{}) error {
+	if ctx, ok := obj["@context"]; ok {
+		err := p.context(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	graph, ok := obj["@graph"]
+	if !ok {
+		_, err := p.node(obj)
+		return err
+	}
+
+	items, ok := graph.([]interface{})
+	if !ok {
+		items = []interface{}{graph}
+	}
+
+	for _, item := range items {
+		node, ok := item.(map[string]interface{})
+		if !ok {
+			return errors.New("expected a node object in @graph")
+		}
+
+		_, err := p.node(node)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// context adds the entries of an @context to the graph's prefixes.
+func (p *jsonldParser) context(ctx interface{}) error {
+	switch c := ctx.(type) {
+	case nil:
+		return nil
+	case string:
+		return errors.New("remote contexts are not supported")
+	case []interface{}:
+		for _, item := range c {
+			err := p.context(item)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for key, value := range c {
+			if strings.HasPrefix(key, "@") {
+				continue
+			}
+
+			switch v := value.(type) {
+			case string:
+				p.graph.Prefixes[key] = v
+			case map[string]interface{}:
+				if id, ok := v["@id"].(string); ok {
+					p.graph.Prefixes[key] = p.graph.Expand(id)
+				}
+			}
+		}
+		return nil
+	}
+
+	return errors.New("invalid @context")
+}
+
+// node parses a node object, adding its triples to the graph and returning its
+// subject. Nodes without an @id are blank nodes.
+func (p *jsonldParser) node(obj map[string]interface{}) (Term, error) {
+	var subject Term
+
+	switch id := obj["@id"].(type) {
+	case nil:
+		subject = p.graph.NewBlankNode()
+	case string:
+		subject = p.resource(id)
+	default:
+		return Term{}, errors.New("invalid @id, expected a string")
+	}
+
+	// map keys are unordered, so sort them to keep the order of triples stable
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := obj[key]
+
+		if key == "@type" {
+			types, ok := value.([]interface{})
+			if !ok {
+				types = []interface{}{value}
+			}
+
+			for _, t := range types {
+				s, ok := t.(string)
+				if !ok {
+					return Term{}, errors.New("invalid @type, expected a string")
+				}
+				p.graph.Add(subject, NewIRI(rdfType), p.resource(s))
+			}
+			continue
+		}
+
+		// other keywords such as @id and @context have already been handled or
+		// are not supported
+		if strings.HasPrefix(key, "@") {
+			continue
+		}
+
+		predicate := p.expand(key)
+		if !strings.Contains(predicate, ":") {
+			// terms which do not expand to an IRI are ignored by JSON-LD
+			continue
+		}
+
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+
+		for _, val := range values {
+			object, ok, err := p.value(val)
+			if err != nil {
+				return Term{}, errors.Wrapf(err, "invalid value for %s", key)
+			}
+
+			if ok {
+				p.graph.Add(subject, NewIRI(predicate), object)
+			}
+		}
+	}
+
+	return subject, nil
+}
+
+// value parses the value of a property. The second return value is false for
+// null values, which JSON-LD ignores.
+func (p *jsonldParser) value(val interface{}) (Term, bool, error) {
+	switch v := val.(type) {
+	case nil:
+		return Term{}, false, nil
+	case string:
+		return NewLiteral(v), true, nil
+	case bool:
+		if v {
+			return NewTypedLiteral("true", xsdBoolean), true, nil
+		}
+		return NewTypedLiteral("false", xsdBoolean), true, nil
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return NewTypedLiteral(v.String(), xsdDouble), true, nil
+		}
+		return NewTypedLiteral(v.String(), xsdInteger), true, nil
+	case map[string]interface{}:
+		if _, ok := v["@list"]; ok {
+			return Term{}, false, errors.New("@list values are not supported")
+		}
+
+		if _, ok := v["@set"]; ok {
+			return Term{}, false, errors.New("@set values are not supported")
+		}
+
+		if value, ok := v["@value"]; ok {
+			return p.literal(value, v)
+		}
+
+		node, err := p.node(v)
+		if err != nil {
+			return Term{}, false, err
+		}
+
+		return node, true, nil
+	}
+
+	return Term{}, false, errors.New("nested arrays are not supported")
+}
+
+// literal parses a value object.
+func (p *jsonldParser) literal(value interface{}, obj map[string]interface{}) (Term, bool, error) {
+	var term Term
+
+	switch v := value.(type) {
+	case nil:
+		return Term{}, false, nil
+	case string:
+		term = NewLiteral(v)
+	case bool, json.Number:
+		t, _, err := p.value(v)
+		if err != nil {
+			return Term{}, false, err
+		}
+		term = t
+	default:
+		return Term{}, false, errors.New("invalid @value")
+	}
+
+	if language, ok := obj["@language"].(string); ok {
+		term.Language = language
+		term.Datatype = ""
+	}
+
+	if datatype, ok := obj["@type"].(string); ok {
+		term = NewTypedLiteral(term.Value, p.expand(datatype))
+	}
+
+	return term, true, nil
+}
+
+// resource returns the term for an @id or @type value.
+func (p *jsonldParser) resource(id string) Term {
+	if strings.HasPrefix(id, "_:") {
+		node, ok := p.blankNodes[id]
+		if !ok {
+			node = p.graph.NewBlankNode()
+			p.blankNodes[id] = node
+		}
+		return node
+	}
+
+	return NewIRI(p.expand(id))
+}
+
+// expand returns the IRI for a term, prefixed name or IRI.
+func (p *jsonldParser) expand(name string) string {
+	if iri, ok := p.graph.Prefixes[name]; ok {
+		return iri
+	}
+
+	return p.graph.Expand(name)
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdf_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot/rdf"
+)
+
+func TestParseJSONLD(t *testing.T) {
+	input := `{
+  "@context": [
+    { "ex": "http://example.com/", "@vocab": "http://example.com/" },
+    { "name": { "@id": "ex:name" } }
+  ],
+  "@id": "ex:a",
+  "@type": ["ex:Thing"],
+  "name": ["A", { "@value": "a", "@language": "en" }],
+  "ex:count": 3,
+  "ex:ratio": 0.5,
+  "ex:active": false,
+  "ex:created": { "@value": "2017-06-01T00:00:00Z", "@type": "http://www.w3.org/2001/XMLSchema#dateTime" },
+  "ex:link": { "@id": "http://example.com/b" },
+  "ex:child": [{ "name": "child" }, { "@id": "_:shared" }],
+  "ex:missing": null,
+  "undefined": "ignored"
+}`
+
+	g, err := rdf.Parse(strings.NewReader(input), rdf.JSONLD)
+	assert.Nil(t, err)
+
+	ex := func(name string) rdf.Term {
+		return rdf.NewIRI("http://example.com/" + name)
+	}
+	blank := func(label string) rdf.Term {
+		return rdf.Term{Kind: rdf.BlankNode, Value: label}
+	}
+
+	a := ex("a")
+
+	assert.Equal(t, []rdf.Triple{
+		{a, rdf.NewIRI(rdf.RDFNamespace + "type"), ex("Thing")},
+		{a, ex("active"), rdf.NewTypedLiteral("false", rdf.XSDNamespace+"boolean")},
+		{blank("b1"), ex("name"), rdf.NewLiteral("child")},
+		{a, ex("child"), blank("b1")},
+		{a, ex("child"), blank("b2")},
+		{a, ex("count"), rdf.NewTypedLiteral("3", rdf.XSDNamespace+"integer")},
+		{a, ex("created"), rdf.NewTypedLiteral("2017-06-01T00:00:00Z", rdf.XSDNamespace+"dateTime")},
+		{a, ex("link"), ex("b")},
+		{a, ex("ratio"), rdf.NewTypedLiteral("0.5", rdf.XSDNamespace+"double")},
+		{a, ex("name"), rdf.NewLiteral("A")},
+		{a, ex("name"), rdf.NewLangLiteral("a", "en")},
+	}, g.Triples)
+}
+
+func TestParseJSONLDInvalid(t *testing.T) {
+	testcases := []struct {
+		label string
+		input string
+	}{
+		{"invalid json", `{"@graph": [`},
+		{"not an object", `"a"`},
+		{"remote context", `{"@context": "http://schema.org/", "@id": "urn:a"}`},
+		{"invalid id", `{"@id": 5}`},
+		{"invalid type", `{"@id": "urn:a", "@type": 5}`},
+		{"invalid graph", `{"@graph": ["urn:a"]}`},
+		{"list", `{"@id": "urn:a", "urn:p": {"@list": ["a"]}}`},
+		{"nested array", `{"@id": "urn:a", "urn:p": [["a"]]}`},
+		{"invalid value", `{"@id": "urn:a", "urn:p": {"@value": ["a"]}}`},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			_, err := rdf.Parse(strings.NewReader(testcase.input), rdf.JSONLD)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestWriteJSONLD(t *testing.T) {
+	g := rdf.NewGraph(map[string]string{"ex": "http://example.com/"})
+
+	a := rdf.NewIRI("http://example.com/a")
+	p := rdf.NewIRI("http://example.com/p")
+	nested := g.NewBlankNode()
+	shared := g.NewBlankNode()
+
+	g.Add(a, rdf.NewIRI(rdf.RDFNamespace+"type"), rdf.NewIRI("http://example.com/Thing"))
+	g.Add(a, p, nested)
+	g.Add(a, p, shared)
+	g.Add(a, rdf.NewIRI("http://example.com/q"), rdf.NewLangLiteral("hola", "es"))
+	g.Add(nested, p, rdf.NewTypedLiteral("1", rdf.XSDNamespace+"integer"))
+	g.Add(shared, p, rdf.NewLiteral("shared"))
+	g.Add(rdf.NewIRI("http://example.org/b"), p, shared)
+
+	var buf bytes.Buffer
+
+	err := rdf.Write(&buf, g, rdf.JSONLD)
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+  "@context": {
+    "ex": "http://example.com/"
+  },
+  "@graph": [
+    {
+      "@id": "ex:a",
+      "@type": "ex:Thing",
+      "ex:p": [
+        {
+          "ex:p": {
+            "@type": "http://www.w3.org/2001/XMLSchema#integer",
+            "@value": "1"
+          }
+        },
+        {
+          "@id": "_:b2"
+        }
+      ],
+      "ex:q": {
+        "@language": "es",
+        "@value": "hola"
+      }
+    },
+    {
+      "@id": "_:b2",
+      "ex:p": "shared"
+    },
+    {
+      "@id": "http://example.org/b",
+      "ex:p": {
+        "@id": "_:b2"
+      }
+    }
+  ]
+}
+`, buf.String())
+
+	parsed, err := rdf.Parse(&buf, rdf.JSONLD)
+	assert.Nil(t, err)
+	assert.Len(t, parsed.Triples, len(g.Triples))
+	assert.Equal(t, g.Prefixes, parsed.Prefixes)
+}
@@ -0,0 +1,562 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdf
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thingful/bigiot"
+)
+
+// The classes and properties used to describe offerings.
+var (
+	offeringClass = NewIRI(BigIoTNamespace + "Offering")
+	geoShapeClass = NewIRI(SchemaNamespace + "GeoShape")
+
+	typeProperty = NewIRI(rdfType)
+	nameProperty = NewIRI(SchemaNamespace + "name")
+
+	offeringIDProperty = NewIRI(BigIoTNamespace + "offeringId")
+	localIDProperty    = NewIRI(BigIoTNamespace + "localId")
+	categoryProperty   = NewIRI(BigIoTNamespace + "category")
+	licenseProperty    = NewIRI(BigIoTNamespace + "license")
+
+	inputProperty         = NewIRI(BigIoTNamespace + "hasInput")
+	outputProperty        = NewIRI(BigIoTNamespace + "hasOutput")
+	rdfAnnotationProperty = NewIRI(BigIoTNamespace + "rdfAnnotation")
+	datatypeProperty      = NewIRI(BigIoTNamespace + "datatype")
+	requiredProperty      = NewIRI(BigIoTNamespace + "required")
+
+	endpointProperty            = NewIRI(BigIoTNamespace + "hasEndpoint")
+	endpointTypeProperty        = NewIRI(BigIoTNamespace + "endpointType")
+	accessInterfaceTypeProperty = NewIRI(BigIoTNamespace + "accessInterfaceType")
+	urlProperty                 = NewIRI(SchemaNamespace + "url")
+
+	priceProperty         = NewIRI(BigIoTNamespace + "hasPrice")
+	pricingModelProperty  = NewIRI(BigIoTNamespace + "pricingModel")
+	amountProperty        = NewIRI(SchemaNamespace + "price")
+	priceCurrencyProperty = NewIRI(SchemaNamespace + "priceCurrency")
+
+	spatialCoverageProperty = NewIRI(SchemaNamespace + "spatialCoverage")
+	cityProperty            = NewIRI(BigIoTNamespace + "city")
+	boxProperty             = NewIRI(SchemaNamespace + "box")
+	polygonProperty         = NewIRI(SchemaNamespace + "polygon")
+
+	temporalExtentProperty = NewIRI(BigIoTNamespace + "hasTemporalExtent")
+	startDateProperty      = NewIRI(SchemaNamespace + "startDate")
+	endDateProperty        = NewIRI(SchemaNamespace + "endDate")
+
+	activationProperty     = NewIRI(BigIoTNamespace + "hasActivation")
+	activatedProperty      = NewIRI(BigIoTNamespace + "activated")
+	expirationTimeProperty = NewIRI(BigIoTNamespace + "expirationTime")
+	durationProperty       = NewIRI(BigIoTNamespace + "duration")
+
+	providerProperty       = NewIRI(BigIoTNamespace + "hasProvider")
+	providerIDProperty     = NewIRI(BigIoTNamespace + "providerId")
+	organizationProperty   = NewIRI(BigIoTNamespace + "hasOrganization")
+	organizationIDProperty = NewIRI(BigIoTNamespace + "organizationId")
+	createdProperty        = NewIRI(SchemaNamespace + "dateCreated")
+	modifiedProperty       = NewIRI(SchemaNamespace + "dateModified")
+)
+
+// FromOfferingDescription returns the RDF graph describing an offering
+// description. The offering is a blank node typed as bigiot:Offering, with its
+// inputs, outputs, endpoints, price, spatial and temporal extents and
+// activation as nested blank nodes. Areas are written as a schema:GeoShape
+// using the schema.org box and polygon formats.
+func FromOfferingDescription(description *bigiot.OfferingDescription) *Graph {
+	g := NewGraph(DefaultPrefixes())
+	subject := g.NewBlankNode()
+
+	g.Add(subject, typeProperty, offeringClass)
+	addLiteral(g, subject, localIDProperty, description.LocalID)
+	addLiteral(g, subject, nameProperty, description.Name)
+
+	addOffering(g, subject, description.Category, description.Inputs, description.Outputs, description.Endpoints, description.License, description.Price, description.SpatialExtent, description.TemporalExtent)
+
+	if description.Activation != nil {
+		addActivation(g, subject, description.Activation)
+	}
+
+	return g
+}
+
+// FromOffering returns the RDF graph describing an offering returned by the
+// marketplace. This contains the same terms as FromOfferingDescription, along
+// with the offering's ID, provider and timestamps.
+func FromOffering(offering *bigiot.Offering) *Graph {
+	g := NewGraph(DefaultPrefixes())
+	subject := g.NewBlankNode()
+
+	g.Add(subject, typeProperty, offeringClass)
+	addLiteral(g, subject, offeringIDProperty, offering.ID)
+	addLiteral(g, subject, nameProperty, offering.Name)
+
+	addOffering(g, subject, offering.Category, offering.Inputs, offering.Outputs, offering.Endpoints, offering.License, offering.Price, offering.SpatialExtent, offering.TemporalExtent)
+	addActivation(g, subject, &offering.Activation)
+
+	if offering.Provider.ID != "" || offering.Provider.Name != "" {
+		provider := g.NewBlankNode()
+		g.Add(subject, providerProperty, provider)
+		addLiteral(g, provider, providerIDProperty, offering.Provider.ID)
+		addLiteral(g, provider, nameProperty, offering.Provider.Name)
+
+		if offering.Provider.Organization.ID != "" || offering.Provider.Organization.Name != "" {
+			organization := g.NewBlankNode()
+			g.Add(provider, organizationProperty, organization)
+			addLiteral(g, organization, organizationIDProperty, offering.Provider.Organization.ID)
+			addLiteral(g, organization, nameProperty, offering.Provider.Organization.Name)
+		}
+	}
+
+	addTime(g, subject, createdProperty, offering.Created)
+	addTime(g, subject, modifiedProperty, offering.LastUpdated)
+
+	return g
+}
+
+// ToOfferingDescription reads an offering description from a graph, which must
+// contain exactly one subject typed as bigiot:Offering. This is the reverse of
+// FromOfferingDescription, so a description written by this package is read
+// back unchanged. Enumerated values, amounts and currencies must be valid.
+func ToOfferingDescription(g *Graph) (*bigiot.OfferingDescription, error) {
+	subjects := g.Subjects(typeProperty, offeringClass)
+	switch len(subjects) {
+	case 0:
+		return nil, errors.New("graph does not contain an offering")
+	case 1:
+	default:
+		return nil, errors.Errorf("graph contains %d offerings, expected 1", len(subjects))
+	}
+
+	subject := subjects[0]
+	r := &reader{graph: g}
+
+	description := &bigiot.OfferingDescription{
+		LocalID:  r.value(subject, localIDProperty),
+		Name:     r.value(subject, nameProperty),
+		Category: r.value(subject, categoryProperty),
+	}
+
+	for _, node := range g.Objects(subject, inputProperty) {
+		description.Inputs = append(description.Inputs, r.dataField(node))
+	}
+
+	for _, node := range g.Objects(subject, outputProperty) {
+		description.Outputs = append(description.Outputs, r.dataField(node))
+	}
+
+	for _, node := range g.Objects(subject, endpointProperty) {
+		description.Endpoints = append(description.Endpoints, r.endpoint(node))
+	}
+
+	if license := r.value(subject, licenseProperty); license != "" {
+		var err error
+		description.License, err = bigiot.ParseLicense(license)
+		r.fail(err)
+	}
+
+	if node, ok := g.Object(subject, priceProperty); ok {
+		description.Price = r.price(node)
+	}
+
+	if node, ok := g.Object(subject, spatialCoverageProperty); ok {
+		description.SpatialExtent = r.spatialExtent(node)
+	}
+
+	if node, ok := g.Object(subject, temporalExtentProperty); ok {
+		description.TemporalExtent = &bigiot.TemporalExtent{
+			From: r.time(node, startDateProperty),
+			To:   r.time(node, endDateProperty),
+		}
+	}
+
+	if node, ok := g.Object(subject, activationProperty); ok {
+		description.Activation = &bigiot.Activation{
+			Status:         r.bool(node, activatedProperty),
+			ExpirationTime: r.time(node, expirationTimeProperty),
+			Duration:       r.duration(node, durationProperty),
+		}
+	}
+
+	if r.err != nil {
+		return nil, errors.Wrap(r.err, "error reading offering description")
+	}
+
+	return description, nil
+}
+
+// WriteOfferingDescription writes an offering description to w as RDF in the
+// given format.
+func WriteOfferingDescription(w io.Writer, description *bigiot.OfferingDescription, format Format) error {
+	return Write(w, FromOfferingDescription(description), format)
+}
+
+// WriteOffering writes an offering to w as RDF in the given format.
+func WriteOffering(w io.Writer, offering *bigiot.Offering, format Format) error {
+	return Write(w, FromOffering(offering), format)
+}
+
+// ReadOfferingDescription reads an offering description from RDF in the given
+// format.
+func ReadOfferingDescription(r io.Reader, format Format) (*bigiot.OfferingDescription, error) {
+	g, err := Parse(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return ToOfferingDescription(g)
+}
+
+// addOffering adds the triples shared by offering descriptions and offerings.
+func addOffering(g *Graph, subject Term, category string, inputs, outputs []bigiot.DataField, endpoints []bigiot.Endpoint, license bigiot.License, price bigiot.Price, spatialExtent *bigiot.SpatialExtent, temporalExtent *bigiot.TemporalExtent) {
+	if category != "" {
+		g.Add(subject, categoryProperty, resource(category))
+	}
+
+	for _, input := range inputs {
+		addDataField(g, subject, inputProperty, input)
+	}
+
+	for _, output := range outputs {
+		addDataField(g, subject, outputProperty, output)
+	}
+
+	for _, endpoint := range endpoints {
+		node := g.NewBlankNode()
+		g.Add(subject, endpointProperty, node)
+		addLiteral(g, node, endpointTypeProperty, endpoint.EndpointType.String())
+		addLiteral(g, node, accessInterfaceTypeProperty, endpoint.AccessInterfaceType.String())
+		if endpoint.URI != "" {
+			g.Add(node, urlProperty, resource(endpoint.URI))
+		}
+	}
+
+	addLiteral(g, subject, licenseProperty, license.String())
+
+	if price.PricingModel != "" || !price.Money.Amount.IsZero() || price.Money.Currency != "" {
+		node := g.NewBlankNode()
+		g.Add(subject, priceProperty, node)
+		addLiteral(g, node, pricingModelProperty, price.PricingModel.String())
+		g.Add(node, amountProperty, NewTypedLiteral(price.Money.Amount.String(), xsdDecimal))
+		addLiteral(g, node, priceCurrencyProperty, price.Money.Currency.String())
+	}
+
+	if spatialExtent != nil {
+		node := g.NewBlankNode()
+		g.Add(subject, spatialCoverageProperty, node)
+		g.Add(node, typeProperty, geoShapeClass)
+		addLiteral(g, node, cityProperty, spatialExtent.City)
+
+		if box := spatialExtent.BoundingBox; box != nil {
+			addLiteral(g, node, boxProperty, formatPoints([]bigiot.Location{box.Location1, box.Location2}))
+		}
+
+		if len(spatialExtent.Polygon) > 0 {
+			// schema.org polygons repeat the first point to close the ring
+			ring := spatialExtent.Polygon
+			if ring[0] != ring[len(ring)-1] {
+				ring = append(append([]bigiot.Location(nil), ring...), ring[0])
+			}
+			addLiteral(g, node, polygonProperty, formatPoints(ring))
+		}
+	}
+
+	if temporalExtent != nil {
+		node := g.NewBlankNode()
+		g.Add(subject, temporalExtentProperty, node)
+		addTime(g, node, startDateProperty, temporalExtent.From)
+		addTime(g, node, endDateProperty, temporalExtent.To)
+	}
+}
+
+// addDataField adds an input or output to the graph.
+func addDataField(g *Graph, subject, predicate Term, field bigiot.DataField) {
+	node := g.NewBlankNode()
+	g.Add(subject, predicate, node)
+	addLiteral(g, node, nameProperty, field.Name)
+
+	if field.RdfURI != "" {
+		g.Add(node, rdfAnnotationProperty, resource(field.RdfURI))
+	}
+
+	addLiteral(g, node, datatypeProperty, field.Datatype)
+	g.Add(node, requiredProperty, NewTypedLiteral(strconv.FormatBool(field.Required), xsdBoolean))
+}
+
+// addActivation adds an activation to the graph.
+func addActivation(g *Graph, subject Term, activation *bigiot.Activation) {
+	node := g.NewBlankNode()
+	g.Add(subject, activationProperty, node)
+	g.Add(node, activatedProperty, NewTypedLiteral(strconv.FormatBool(activation.Status), xsdBoolean))
+	addTime(g, node, expirationTimeProperty, activation.ExpirationTime)
+
+	if activation.Duration != 0 {
+		g.Add(node, durationProperty, NewTypedLiteral(formatDuration(activation.Duration), xsdDuration))
+	}
+}
+
+// addLiteral adds a string literal to the graph, unless it is empty.
+func addLiteral(g *Graph, subject, predicate Term, value string) {
+	if value != "" {
+		g.Add(subject, predicate, NewLiteral(value))
+	}
+}
+
+// addTime adds an xsd:dateTime literal to the graph, unless it is the zero
+// time.
+func addTime(g *Graph, subject, predicate Term, t time.Time) {
+	if !t.IsZero() {
+		g.Add(subject, predicate, NewTypedLiteral(t.UTC().Format(time.RFC3339Nano), xsdDateTime))
+	}
+}
+
+// resource returns an IRI term for values which look like IRIs, such as
+// categories and RDF URIs, or a literal otherwise.
+func resource(value string) Term {
+	if strings.Contains(value, ":") {
+		return NewIRI(value)
+	}
+
+	return NewLiteral(value)
+}
+
+// formatPoints formats a list of locations as used by schema.org GeoShapes:
+// space separated latitude longitude pairs.
+func formatPoints(points []bigiot.Location) string {
+	values := make([]string, 0, len(points)*2)
+	for _, point := range points {
+		values = append(values, strconv.FormatFloat(point.Lat, 'f', -1, 64), strconv.FormatFloat(point.Lng, 'f', -1, 64))
+	}
+
+	return strings.Join(values, " ")
+}
+
+// parsePoints parses a list of locations in schema.org GeoShape form. Commas
+// are accepted as well as spaces between values.
+func parsePoints(s string) ([]bigiot.Location, error) {
+	values := strings.Fields(strings.Replace(s, ",", " ", -1))
+	if len(values)%2 != 0 {
+		return nil, errors.Errorf("invalid points %q, expected latitude longitude pairs", s)
+	}
+
+	points := make([]bigiot.Location, len(values)/2)
+
+	for i := range points {
+		lat, err := strconv.ParseFloat(values[i*2], 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid latitude %q", values[i*2])
+		}
+
+		lng, err := strconv.ParseFloat(values[i*2+1], 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid longitude %q", values[i*2+1])
+		}
+
+		points[i] = bigiot.Location{Lat: lat, Lng: lng}
+	}
+
+	return points, nil
+}
+
+// formatDuration formats a duration as an xsd:duration in seconds, e.g.
+// PT90S.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	return sign + "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
+
+// parseDuration parses an xsd:duration containing only hours, minutes and
+// seconds, e.g. PT1H30M.
+func parseDuration(s string) (time.Duration, error) {
+	value := s
+
+	sign := ""
+	if strings.HasPrefix(value, "-") {
+		sign = "-"
+		value = value[1:]
+	}
+
+	if !strings.HasPrefix(value, "PT") || len(value) == 2 {
+		return 0, errors.Errorf("unsupported duration %q", s)
+	}
+
+	d, err := time.ParseDuration(sign + strings.ToLower(value[2:]))
+	if err != nil {
+		return 0, errors.Errorf("unsupported duration %q", s)
+	}
+
+	return d, nil
+}
+
+// reader reads values from a graph, recording the first error encountered so
+// that callers can check for errors once at the end.
+type reader struct {
+	graph *Graph
+	err   error
+}
+
+// value returns the value of the first object of the subject and predicate, or
+// an empty string.
+func (r *reader) value(subject, predicate Term) string {
+	object, _ := r.graph.Object(subject, predicate)
+	return object.Value
+}
+
+// bool returns the value of a boolean literal.
+func (r *reader) bool(subject, predicate Term) bool {
+	switch value := r.value(subject, predicate); value {
+	case "", "false", "0":
+		return false
+	case "true", "1":
+		return true
+	default:
+		r.fail(errors.Errorf("invalid boolean %q", value))
+		return false
+	}
+}
+
+// time returns the value of an xsd:dateTime literal, or the zero time.
+func (r *reader) time(subject, predicate Term) time.Time {
+	value := r.value(subject, predicate)
+	if value == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		r.fail(errors.Errorf("invalid date time %q", value))
+	}
+
+	return t
+}
+
+// duration returns the value of an xsd:duration literal.
+func (r *reader) duration(subject, predicate Term) time.Duration {
+	value := r.value(subject, predicate)
+	if value == "" {
+		return 0
+	}
+
+	d, err := parseDuration(value)
+	r.fail(err)
+
+	return d
+}
+
+// dataField reads an input or output.
+func (r *reader) dataField(node Term) bigiot.DataField {
+	return bigiot.DataField{
+		Name:     r.value(node, nameProperty),
+		RdfURI:   r.value(node, rdfAnnotationProperty),
+		Datatype: r.value(node, datatypeProperty),
+		Required: r.bool(node, requiredProperty),
+	}
+}
+
+// endpoint reads an endpoint.
+func (r *reader) endpoint(node Term) bigiot.Endpoint {
+	endpoint := bigiot.Endpoint{
+		URI: r.value(node, urlProperty),
+	}
+
+	var err error
+
+	if value := r.value(node, endpointTypeProperty); value != "" {
+		endpoint.EndpointType, err = bigiot.ParseEndpointType(value)
+		r.fail(err)
+	}
+
+	if value := r.value(node, accessInterfaceTypeProperty); value != "" {
+		endpoint.AccessInterfaceType, err = bigiot.ParseAccessInterfaceType(value)
+		r.fail(err)
+	}
+
+	return endpoint
+}
+
+// price reads a price.
+func (r *reader) price(node Term) bigiot.Price {
+	var (
+		price bigiot.Price
+		err   error
+	)
+
+	if value := r.value(node, pricingModelProperty); value != "" {
+		price.PricingModel, err = bigiot.ParsePricingModel(value)
+		r.fail(err)
+	}
+
+	if value := r.value(node, amountProperty); value != "" {
+		price.Money.Amount, err = bigiot.ParseAmount(value)
+		r.fail(err)
+	}
+
+	if value := r.value(node, priceCurrencyProperty); value != "" {
+		price.Money.Currency, err = bigiot.ParseCurrency(value)
+		r.fail(err)
+	}
+
+	return price
+}
+
+// spatialExtent reads a spatial extent.
+func (r *reader) spatialExtent(node Term) *bigiot.SpatialExtent {
+	extent := &bigiot.SpatialExtent{
+		City: r.value(node, cityProperty),
+	}
+
+	if value := r.value(node, boxProperty); value != "" {
+		points, err := parsePoints(value)
+		if err == nil && len(points) != 2 {
+			err = errors.Errorf("invalid box %q, expected two points", value)
+		}
+
+		if err != nil {
+			r.fail(err)
+		} else {
+			extent.BoundingBox = &bigiot.BoundingBox{Location1: points[0], Location2: points[1]}
+		}
+	}
+
+	if value := r.value(node, polygonProperty); value != "" {
+		points, err := parsePoints(value)
+		r.fail(err)
+
+		if len(points) > 1 && points[0] == points[len(points)-1] {
+			points = points[:len(points)-1]
+		}
+
+		extent.Polygon = points
+	}
+
+	return extent
+}
+
+// fail records err if no error has been recorded yet.
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdf_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/rdf"
+)
+
+var description = &bigiot.OfferingDescription{
+	LocalID:  "parking",
+	Name:     "Parking \"sites\"",
+	Category: "urn:big-iot:ParkingSpaceCategory",
+	Inputs: []bigiot.DataField{
+		{Name: "longitude", RdfURI: "http://schema.org/longitude", Datatype: "number", Required: true},
+		{Name: "latitude", RdfURI: "http://schema.org/latitude", Datatype: "number", Required: true},
+		{Name: "radius"},
+	},
+	Outputs: []bigiot.DataField{
+		{Name: "status", RdfURI: "http://schema.big-iot.org/mobility/parkingSpaceStatus"},
+	},
+	Endpoints: []bigiot.Endpoint{
+		{
+			EndpointType:        bigiot.HTTPGet,
+			URI:                 "https://example.com/parking",
+			AccessInterfaceType: bigiot.BIGIoTLib,
+		},
+	},
+	License: bigiot.CreativeCommons,
+	Price: bigiot.Price{
+		Money: bigiot.Money{
+			Amount:   bigiot.MustParseAmount("0.015"),
+			Currency: bigiot.EUR,
+		},
+		PricingModel: bigiot.PerAccess,
+	},
+	SpatialExtent: &bigiot.SpatialExtent{
+		City: "Barcelona",
+		BoundingBox: &bigiot.BoundingBox{
+			Location1: bigiot.Location{Lng: 2.05, Lat: 41.32},
+			Location2: bigiot.Location{Lng: 2.23, Lat: 41.47},
+		},
+		Polygon: []bigiot.Location{
+			{Lng: 2.05, Lat: 41.32},
+			{Lng: 2.23, Lat: 41.32},
+			{Lng: 2.14, Lat: 41.47},
+		},
+	},
+	TemporalExtent: &bigiot.TemporalExtent{
+		From: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC),
+	},
+	Activation: &bigiot.Activation{
+		Status:   true,
+		Duration: 90 * time.Minute,
+	},
+}
+
+func TestOfferingDescriptionRoundTrip(t *testing.T) {
+	testcases := []struct {
+		label       string
+		description *bigiot.OfferingDescription
+	}{
+		{"full", description},
+		{"minimal", &bigiot.OfferingDescription{Name: "minimal"}},
+	}
+
+	for _, testcase := range testcases {
+		for _, format := range []rdf.Format{rdf.Turtle, rdf.NTriples, rdf.JSONLD} {
+			t.Run(testcase.label+" "+string(format), func(t *testing.T) {
+				var buf bytes.Buffer
+
+				err := rdf.WriteOfferingDescription(&buf, testcase.description, format)
+				assert.Nil(t, err)
+
+				got, err := rdf.ReadOfferingDescription(&buf, format)
+				assert.Nil(t, err)
+				assert.Equal(t, testcase.description, got)
+			})
+		}
+	}
+}
+
+func TestWriteOfferingDescriptionTurtle(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := rdf.WriteOfferingDescription(&buf, &bigiot.OfferingDescription{
+		LocalID:  "parking",
+		Name:     "Parking",
+		Category: "urn:big-iot:ParkingSpaceCategory",
+		Inputs: []bigiot.DataField{
+			{Name: "latitude", RdfURI: "http://schema.org/latitude"},
+		},
+		License: bigiot.OpenDataLicense,
+	}, rdf.Turtle)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `@prefix bigiot: <http://schema.big-iot.org/core/> .
+@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix schema: <http://schema.org/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+
+_:b1 a bigiot:Offering ;
+    bigiot:localId "parking" ;
+    schema:name "Parking" ;
+    bigiot:category <urn:big-iot:ParkingSpaceCategory> ;
+    bigiot:hasInput [ schema:name "latitude" ;
+        bigiot:rdfAnnotation schema:latitude ;
+        bigiot:required "false"^^xsd:boolean ] ;
+    bigiot:license "OPEN_DATA_LICENSE" .
+`, buf.String())
+}
+
+func TestWriteOffering(t *testing.T) {
+	offering := &bigiot.Offering{
+		ID:       "Org-Provider-parking",
+		Name:     "Parking",
+		Category: "urn:big-iot:ParkingSpaceCategory",
+		License:  bigiot.OpenDataLicense,
+		Activation: bigiot.Activation{
+			Status:         true,
+			ExpirationTime: time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+		Provider: bigiot.OfferingProvider{
+			ID:   "Org-Provider",
+			Name: "Provider",
+			Organization: bigiot.Organization{
+				ID:   "Org",
+				Name: "Organization",
+			},
+		},
+		Created: time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+
+	err := rdf.WriteOffering(&buf, offering, rdf.NTriples)
+	assert.Nil(t, err)
+	assert.Equal(t, `_:b1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://schema.big-iot.org/core/Offering> .
+_:b1 <http://schema.big-iot.org/core/offeringId> "Org-Provider-parking" .
+_:b1 <http://schema.org/name> "Parking" .
+_:b1 <http://schema.big-iot.org/core/category> <urn:big-iot:ParkingSpaceCategory> .
+_:b1 <http://schema.big-iot.org/core/license> "OPEN_DATA_LICENSE" .
+_:b1 <http://schema.big-iot.org/core/hasActivation> _:b2 .
+_:b2 <http://schema.big-iot.org/core/activated> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+_:b2 <http://schema.big-iot.org/core/expirationTime> "2017-06-01T12:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+_:b1 <http://schema.big-iot.org/core/hasProvider> _:b3 .
+_:b3 <http://schema.big-iot.org/core/providerId> "Org-Provider" .
+_:b3 <http://schema.org/name> "Provider" .
+_:b3 <http://schema.big-iot.org/core/hasOrganization> _:b4 .
+_:b4 <http://schema.big-iot.org/core/organizationId> "Org" .
+_:b4 <http://schema.org/name> "Organization" .
+_:b1 <http://schema.org/dateCreated> "2017-05-01T12:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+`, buf.String())
+
+	// an offering can be read back as a description
+	got, err := rdf.ReadOfferingDescription(&buf, rdf.NTriples)
+	assert.Nil(t, err)
+	assert.Equal(t, &bigiot.OfferingDescription{
+		Name:     "Parking",
+		Category: "urn:big-iot:ParkingSpaceCategory",
+		License:  bigiot.OpenDataLicense,
+		Activation: &bigiot.Activation{
+			Status:         true,
+			ExpirationTime: time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}, got)
+}
+
+func TestReadOfferingDescription(t *testing.T) {
+	input := `
+@prefix bigiot: <http://schema.big-iot.org/core/> .
+@prefix schema: <http://schema.org/> .
+
+<urn:example:offering> a bigiot:Offering ;
+	schema:name "Weather" ;
+	bigiot:category <urn:big-iot:WeatherIndicatorCategory> ;
+	bigiot:hasOutput [ schema:name "temperature" ; bigiot:rdfAnnotation <http://schema.big-iot.org/environment/temperature> ; bigiot:required true ] ;
+	bigiot:hasPrice [ bigiot:pricingModel "PER_MONTH" ; schema:price 1.5 ; schema:priceCurrency "GBP" ] ;
+	schema:spatialCoverage [ schema:box "51.28,-0.51 51.69,0.33" ] ;
+	bigiot:hasActivation [ bigiot:activated false ; bigiot:duration "PT1H30M" ] .
+`
+
+	got, err := rdf.ReadOfferingDescription(strings.NewReader(input), rdf.Turtle)
+	assert.Nil(t, err)
+	assert.Equal(t, &bigiot.OfferingDescription{
+		Name:     "Weather",
+		Category: "urn:big-iot:WeatherIndicatorCategory",
+		Outputs: []bigiot.DataField{
+			{Name: "temperature", RdfURI: "http://schema.big-iot.org/environment/temperature", Required: true},
+		},
+		Price: bigiot.Price{
+			Money: bigiot.Money{
+				Amount:   bigiot.MustParseAmount("1.5"),
+				Currency: bigiot.GBP,
+			},
+			PricingModel: bigiot.PerMonth,
+		},
+		SpatialExtent: &bigiot.SpatialExtent{
+			BoundingBox: &bigiot.BoundingBox{
+				Location1: bigiot.Location{Lng: -0.51, Lat: 51.28},
+				Location2: bigiot.Location{Lng: 0.33, Lat: 51.69},
+			},
+		},
+		Activation: &bigiot.Activation{
+			Duration: 90 * time.Minute,
+		},
+	}, got)
+}
+
+func TestReadOfferingDescriptionInvalid(t *testing.T) {
+	prefixes := `@prefix bigiot: <http://schema.big-iot.org/core/> .
+@prefix schema: <http://schema.org/> .
+`
+
+	testcases := []struct {
+		label string
+		input string
+	}{
+		{"no offering", `<urn:a> schema:name "a" .`},
+		{"multiple offerings", `<urn:a> a bigiot:Offering . <urn:b> a bigiot:Offering .`},
+		{"invalid license", `[] a bigiot:Offering ; bigiot:license "MIT" .`},
+		{"invalid endpoint type", `[] a bigiot:Offering ; bigiot:hasEndpoint [ bigiot:endpointType "FTP" ] .`},
+		{"invalid amount", `[] a bigiot:Offering ; bigiot:hasPrice [ schema:price "cheap" ] .`},
+		{"invalid currency", `[] a bigiot:Offering ; bigiot:hasPrice [ schema:priceCurrency "XXX" ] .`},
+		{"invalid box", `[] a bigiot:Offering ; schema:spatialCoverage [ schema:box "1 2 3" ] .`},
+		{"invalid polygon", `[] a bigiot:Offering ; schema:spatialCoverage [ schema:polygon "1 2 3 north" ] .`},
+		{"invalid time", `[] a bigiot:Offering ; bigiot:hasTemporalExtent [ schema:startDate "yesterday" ] .`},
+		{"invalid duration", `[] a bigiot:Offering ; bigiot:hasActivation [ bigiot:duration "P1D" ] .`},
+		{"invalid boolean", `[] a bigiot:Offering ; bigiot:hasInput [ bigiot:required "maybe" ] .`},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			_, err := rdf.ReadOfferingDescription(strings.NewReader(prefixes+testcase.input), rdf.Turtle)
+			assert.NotNil(t, err)
+		})
+	}
+}
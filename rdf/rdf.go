@@ -0,0 +1,476 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rdf provides a small RDF graph model along with readers and writers
+// for the Turtle, N-Triples and JSON-LD formats, and a mapping between BIG IoT
+// offerings and their RDF form using the BIG IoT core ontology. This allows
+// offering descriptions to be published to linked data portals, and read back
+// again.
+//
+// Example:
+//		err := rdf.WriteOfferingDescription(os.Stdout, description, rdf.Turtle)
+//		if err != nil {
+//			panic(err)
+//		}
+//
+// Only the parts of each format needed to describe offerings are supported.
+// In particular RDF collections, JSON-LD @list values, remote JSON-LD contexts
+// and base IRIs are rejected.
+package rdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/thingful/bigiot"
+)
+
+// Namespaces of the vocabularies used when describing offerings. These are
+// the namespaces defined by the bigiot package.
+const (
+	RDFNamespace    = bigiot.RDFNamespace
+	RDFSNamespace   = bigiot.RDFSNamespace
+	XSDNamespace    = bigiot.XSDNamespace
+	SchemaNamespace = bigiot.SchemaNamespace
+	BigIoTNamespace = bigiot.BigIoTNamespace
+)
+
+const (
+	rdfType     = RDFNamespace + "type"
+	xsdString   = XSDNamespace + "string"
+	xsdBoolean  = XSDNamespace + "boolean"
+	xsdInteger  = XSDNamespace + "integer"
+	xsdDecimal  = XSDNamespace + "decimal"
+	xsdDouble   = XSDNamespace + "double"
+	xsdDateTime = XSDNamespace + "dateTime"
+	xsdDuration = XSDNamespace + "duration"
+)
+
+// DefaultPrefixes returns the prefixes used for graphs created by this
+// package: rdf, rdfs, xsd, schema and bigiot. These are the same prefixes
+// bigiot.JSONLD defines for the records returned by a provider.
+func DefaultPrefixes() map[string]string {
+	return bigiot.DefaultPrefixes()
+}
+
+// TermKind identifies the kind of an RDF term.
+type TermKind int
+
+const (
+	// IRI is the kind of terms identifying a resource by IRI.
+	IRI TermKind = iota
+
+	// BlankNode is the kind of terms identifying an anonymous resource.
+	BlankNode
+
+	// Literal is the kind of terms holding a value such as a string or number.
+	Literal
+)
+
+// Term is a single RDF term: an IRI, a blank node or a literal.
+type Term struct {
+	Kind TermKind
+
+	// Value is the IRI, the blank node label or the lexical form of the literal.
+	Value string
+
+	// Datatype is the datatype IRI of a literal. An empty datatype is
+	// equivalent to xsd:string.
+	Datatype string
+
+	// Language is the language tag of a literal, if any.
+	Language string
+}
+
+// NewIRI returns an IRI term.
+func NewIRI(iri string) Term {
+	return Term{Kind: IRI, Value: iri}
+}
+
+// NewLiteral returns a plain string literal.
+func NewLiteral(value string) Term {
+	return Term{Kind: Literal, Value: value}
+}
+
+// NewTypedLiteral returns a literal with the given datatype IRI.
+func NewTypedLiteral(value, datatype string) Term {
+	if datatype == xsdString {
+		datatype = ""
+	}
+
+	return Term{Kind: Literal, Value: value, Datatype: datatype}
+}
+
+// NewLangLiteral returns a string literal with the given language tag.
+func NewLangLiteral(value, language string) Term {
+	return Term{Kind: Literal, Value: value, Language: language}
+}
+
+// String returns the N-Triples representation of the term.
+func (t Term) String() string {
+	switch t.Kind {
+	case IRI:
+		return "<" + escapeIRI(t.Value) + ">"
+	case BlankNode:
+		return "_:" + t.Value
+	}
+
+	s := quote(t.Value)
+	if t.Language != "" {
+		return s + "@" + t.Language
+	}
+
+	if t.Datatype != "" && t.Datatype != xsdString {
+		return s + "^^<" + escapeIRI(t.Datatype) + ">"
+	}
+
+	return s
+}
+
+// Triple is a single RDF statement.
+type Triple struct {
+	Subject   Term
+	Predicate Term
+	Object    Term
+}
+
+// Graph is an ordered list of triples along with the prefixes used to
+// abbreviate IRIs when it is written as Turtle or JSON-LD. The order of triples
+// is preserved when reading and writing, so the order of repeated values (such
+// as an offering's inputs) survives a round trip through this package.
+type Graph struct {
+	Prefixes map[string]string
+	Triples  []Triple
+
+	blankNodes int
+}
+
+// NewGraph returns an empty graph with the given prefixes, which may be nil.
+func NewGraph(prefixes map[string]string) *Graph {
+	g := &Graph{
+		Prefixes: make(map[string]string, len(prefixes)),
+	}
+
+	for prefix, namespace := range prefixes {
+		g.Prefixes[prefix] = namespace
+	}
+
+	return g
+}
+
+// Add appends a triple to the graph.
+func (g *Graph) Add(subject, predicate, object Term) {
+	g.Triples = append(g.Triples, Triple{Subject: subject, Predicate: predicate, Object: object})
+}
+
+// NewBlankNode returns a blank node with a label not yet used within the graph.
+func (g *Graph) NewBlankNode() Term {
+	used := make(map[string]bool)
+	for _, t := range g.Triples {
+		for _, term := range []Term{t.Subject, t.Object} {
+			if term.Kind == BlankNode {
+				used[term.Value] = true
+			}
+		}
+	}
+
+	for {
+		g.blankNodes++
+		label := "b" + strconv.Itoa(g.blankNodes)
+		if !used[label] {
+			return Term{Kind: BlankNode, Value: label}
+		}
+	}
+}
+
+// Objects returns the objects of every triple with the given subject and
+// predicate, in the order they were added.
+func (g *Graph) Objects(subject, predicate Term) []Term {
+	var objects []Term
+
+	for _, t := range g.Triples {
+		if t.Subject == subject && t.Predicate == predicate {
+			objects = append(objects, t.Object)
+		}
+	}
+
+	return objects
+}
+
+// Object returns the first object of the triples with the given subject and
+// predicate.
+func (g *Graph) Object(subject, predicate Term) (Term, bool) {
+	for _, t := range g.Triples {
+		if t.Subject == subject && t.Predicate == predicate {
+			return t.Object, true
+		}
+	}
+
+	return Term{}, false
+}
+
+// Subjects returns the distinct subjects of the triples with the given
+// predicate and object, in the order they were added.
+func (g *Graph) Subjects(predicate, object Term) []Term {
+	var subjects []Term
+	seen := make(map[Term]bool)
+
+	for _, t := range g.Triples {
+		if t.Predicate == predicate && t.Object == object && !seen[t.Subject] {
+			seen[t.Subject] = true
+			subjects = append(subjects, t.Subject)
+		}
+	}
+
+	return subjects
+}
+
+// Expand returns the full IRI for a prefixed name such as schema:name. If the
+// name does not start with one of the graph's prefixes it is returned
+// unchanged.
+func (g *Graph) Expand(name string) string {
+	idx := strings.Index(name, ":")
+	if idx == -1 {
+		return name
+	}
+
+	namespace, ok := g.Prefixes[name[:idx]]
+	if !ok {
+		return name
+	}
+
+	return namespace + name[idx+1:]
+}
+
+// Compact returns the prefixed name for an IRI using the graph's prefixes,
+// preferring the longest matching namespace. The second return value is false
+// if no prefix produces a valid prefixed name.
+func (g *Graph) Compact(iri string) (string, bool) {
+	var bestPrefix, bestNamespace string
+
+	for prefix, namespace := range g.Prefixes {
+		if !strings.HasPrefix(iri, namespace) || !isLocalName(iri[len(namespace):]) {
+			continue
+		}
+
+		if len(namespace) > len(bestNamespace) || (len(namespace) == len(bestNamespace) && prefix < bestPrefix) {
+			bestPrefix, bestNamespace = prefix, namespace
+		}
+	}
+
+	if bestNamespace == "" {
+		return "", false
+	}
+
+	return bestPrefix + ":" + iri[len(bestNamespace):], true
+}
+
+// subjects returns the distinct subjects of the graph in the order they were
+// first used.
+func (g *Graph) subjects() []Term {
+	var subjects []Term
+	seen := make(map[Term]bool)
+
+	for _, t := range g.Triples {
+		if !seen[t.Subject] {
+			seen[t.Subject] = true
+			subjects = append(subjects, t.Subject)
+		}
+	}
+
+	return subjects
+}
+
+// nested returns the blank nodes which may be written nested within the single
+// triple that refers to them, rather than as separate subjects with a label.
+func (g *Graph) nested() map[Term]bool {
+	references := make(map[Term]int)
+	for _, t := range g.Triples {
+		if t.Object.Kind == BlankNode {
+			references[t.Object]++
+		}
+	}
+
+	nested := make(map[Term]bool)
+	for node, count := range references {
+		if count == 1 {
+			nested[node] = true
+		}
+	}
+
+	return nested
+}
+
+// sortedPrefixes returns the graph's prefixes in alphabetical order.
+func (g *Graph) sortedPrefixes() []string {
+	prefixes := make([]string, 0, len(g.Prefixes))
+	for prefix := range g.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	return prefixes
+}
+
+// Format identifies an RDF serialization format.
+type Format string
+
+const (
+	// Turtle is the Terse RDF Triple Language, typically using a .ttl
+	// extension.
+	Turtle Format = "turtle"
+
+	// NTriples is the line based N-Triples format, typically using a .nt
+	// extension.
+	NTriples Format = "ntriples"
+
+	// JSONLD is the JSON-LD format, typically using a .jsonld extension.
+	JSONLD Format = "jsonld"
+)
+
+// Write writes the graph to w in the given format.
+func Write(w io.Writer, g *Graph, format Format) error {
+	var (
+		b   []byte
+		err error
+	)
+
+	switch format {
+	case Turtle:
+		b = writeTurtle(g)
+	case NTriples:
+		b = writeNTriples(g)
+	case JSONLD:
+		b, err = writeJSONLD(g)
+	default:
+		return errors.Errorf("unsupported RDF format %q", format)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "error encoding RDF")
+	}
+
+	_, err = w.Write(b)
+	if err != nil {
+		return errors.Wrap(err, "error writing RDF")
+	}
+
+	return nil
+}
+
+// Parse reads a graph in the given format. Blank nodes are relabelled, so the
+// labels used in the input are not preserved.
+func Parse(r io.Reader, format Format) (*Graph, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading RDF")
+	}
+
+	g := NewGraph(nil)
+
+	switch format {
+	case Turtle, NTriples:
+		// N-Triples is a subset of Turtle
+		err = parseTurtle(string(b), g)
+	case JSONLD:
+		err = parseJSONLD(b, g)
+	default:
+		return nil, errors.Errorf("unsupported RDF format %q", format)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing RDF")
+	}
+
+	return g, nil
+}
+
+// writeNTriples returns the graph in N-Triples form, one triple per line.
+func writeNTriples(g *Graph) []byte {
+	var buf bytes.Buffer
+
+	for _, t := range g.Triples {
+		fmt.Fprintf(&buf, "%s %s %s .\n", t.Subject, t.Predicate, t.Object)
+	}
+
+	return buf.Bytes()
+}
+
+// quote returns s as a double quoted string with the characters not allowed
+// in N-Triples and Turtle strings escaped.
+func quote(s string) string {
+	var buf bytes.Buffer
+
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+
+	return buf.String()
+}
+
+// escapeIRI escapes the characters which may not appear within an IRI
+// reference using \u escapes.
+func escapeIRI(iri string) string {
+	var buf bytes.Buffer
+
+	for _, r := range iri {
+		if r <= 0x20 || strings.ContainsRune(`<>"{}|^`+"`\\", r) {
+			fmt.Fprintf(&buf, `\u%04X`, r)
+			continue
+		}
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
+
+// isLocalName returns true if s may be used as the local part of a prefixed
+// name. This is a conservative subset of what Turtle allows.
+func isLocalName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+		case (r == '-' || r == '.') && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return !strings.HasSuffix(s, ".")
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdf_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot/rdf"
+)
+
+func TestTermString(t *testing.T) {
+	testcases := []struct {
+		term     rdf.Term
+		expected string
+	}{
+		{rdf.NewIRI("http://example.com/a"), `<http://example.com/a>`},
+		{rdf.NewIRI("http://example.com/a b"), `<http://example.com/a\u0020b>`},
+		{rdf.Term{Kind: rdf.BlankNode, Value: "b1"}, `_:b1`},
+		{rdf.NewLiteral("say \"hi\"\n"), `"say \"hi\"\n"`},
+		{rdf.NewLangLiteral("hola", "es"), `"hola"@es`},
+		{rdf.NewTypedLiteral("1.5", rdf.XSDNamespace+"decimal"), `"1.5"^^<http://www.w3.org/2001/XMLSchema#decimal>`},
+		{rdf.NewTypedLiteral("plain", rdf.XSDNamespace+"string"), `"plain"`},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.expected, func(t *testing.T) {
+			assert.Equal(t, testcase.expected, testcase.term.String())
+		})
+	}
+}
+
+func TestGraph(t *testing.T) {
+	g := rdf.NewGraph(map[string]string{"ex": "http://example.com/"})
+
+	a := rdf.NewIRI("http://example.com/a")
+	p := rdf.NewIRI("http://example.com/p")
+	node := g.NewBlankNode()
+
+	g.Add(a, p, rdf.NewLiteral("1"))
+	g.Add(a, p, node)
+	g.Add(node, p, rdf.NewLiteral("1"))
+
+	assert.Equal(t, []rdf.Term{rdf.NewLiteral("1"), node}, g.Objects(a, p))
+	assert.Len(t, g.Objects(node, rdf.NewIRI("http://example.com/q")), 0)
+
+	object, ok := g.Object(a, p)
+	assert.True(t, ok)
+	assert.Equal(t, rdf.NewLiteral("1"), object)
+
+	_, ok = g.Object(p, a)
+	assert.False(t, ok)
+
+	assert.Equal(t, []rdf.Term{a, node}, g.Subjects(p, rdf.NewLiteral("1")))
+
+	// labels already in use are skipped when creating blank nodes
+	g.Add(rdf.Term{Kind: rdf.BlankNode, Value: "b2"}, p, a)
+	assert.Equal(t, rdf.Term{Kind: rdf.BlankNode, Value: "b3"}, g.NewBlankNode())
+}
+
+func TestExpandAndCompact(t *testing.T) {
+	g := rdf.NewGraph(map[string]string{
+		"ex":  "http://example.com/",
+		"sub": "http://example.com/sub/",
+	})
+
+	assert.Equal(t, "http://example.com/a", g.Expand("ex:a"))
+	assert.Equal(t, "other:a", g.Expand("other:a"))
+	assert.Equal(t, "plain", g.Expand("plain"))
+
+	testcases := []struct {
+		iri      string
+		expected string
+		ok       bool
+	}{
+		{"http://example.com/a", "ex:a", true},
+		{"http://example.com/sub/b", "sub:b", true},
+		{"http://example.com/a-b.c", "ex:a-b.c", true},
+		{"http://example.com/", "", false},
+		{"http://example.com/a/b", "", false},
+		{"http://example.com/a.", "", false},
+		{"http://example.org/a", "", false},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.iri, func(t *testing.T) {
+			name, ok := g.Compact(testcase.iri)
+			assert.Equal(t, testcase.ok, ok)
+			assert.Equal(t, testcase.expected, name)
+		})
+	}
+}
+
+func TestWriteAndParseUnsupportedFormat(t *testing.T) {
+	err := rdf.Write(&bytes.Buffer{}, rdf.NewGraph(nil), rdf.Format("rdfxml"))
+	assert.NotNil(t, err)
+
+	_, err = rdf.Parse(strings.NewReader(""), rdf.Format("rdfxml"))
+	assert.NotNil(t, err)
+}
+
+func TestNTriples(t *testing.T) {
+	g := rdf.NewGraph(nil)
+	node := g.NewBlankNode()
+
+	g.Add(rdf.NewIRI("http://example.com/a"), rdf.NewIRI("http://example.com/p"), node)
+	g.Add(node, rdf.NewIRI("http://example.com/q"), rdf.NewLangLiteral("tab\there", "en-GB"))
+
+	var buf bytes.Buffer
+
+	err := rdf.Write(&buf, g, rdf.NTriples)
+	assert.Nil(t, err)
+	assert.Equal(t, `<http://example.com/a> <http://example.com/p> _:b1 .
+_:b1 <http://example.com/q> "tab\there"@en-GB .
+`, buf.String())
+
+	parsed, err := rdf.Parse(&buf, rdf.NTriples)
+	assert.Nil(t, err)
+	assert.Equal(t, g.Triples, parsed.Triples)
+}
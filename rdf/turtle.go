@@ -0,0 +1,716 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// writeTurtle returns the graph in Turtle form. Blank nodes referred to by a
+// single triple are written nested within square brackets, other blank nodes
+// are written with a label.
+func writeTurtle(g *Graph) []byte {
+	var buf bytes.Buffer
+
+	for _, prefix := range g.sortedPrefixes() {
+		fmt.Fprintf(&buf, "@prefix %s: <%s> .\n", prefix, escapeIRI(g.Prefixes[prefix]))
+	}
+
+	w := &turtleWriter{
+		graph:   g,
+		buf:     &buf,
+		nested:  g.nested(),
+		written: make(map[Term]bool),
+	}
+
+	subjects := g.subjects()
+
+	for _, subject := range subjects {
+		if !w.nested[subject] {
+			w.writeSubject(subject)
+		}
+	}
+
+	// nested nodes which refer to each other in a cycle are never reached from
+	// a top level subject, so are written with labels instead
+	for _, subject := range subjects {
+		if !w.written[subject] {
+			w.writeSubject(subject)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// turtleWriter holds the state used while writing a graph as Turtle.
+type turtleWriter struct {
+	graph   *Graph
+	buf     *bytes.Buffer
+	nested  map[Term]bool
+	written map[Term]bool
+}
+
+// writeSubject writes all the triples of a top level subject as a single
+// statement.
+func (w *turtleWriter) writeSubject(subject Term) {
+	w.written[subject] = true
+
+	if w.buf.Len() > 0 {
+		w.buf.WriteString("\n")
+	}
+
+	w.buf.WriteString(w.term(subject))
+	w.writePredicates(subject, 1)
+	w.buf.WriteString(" .\n")
+}
+
+// writePredicates writes the predicate object list of a subject, grouping the
+// objects of each predicate.
+func (w *turtleWriter) writePredicates(subject Term, depth int) {
+	var predicates []Term
+	objects := make(map[Term][]Term)
+
+	for _, t := range w.graph.Triples {
+		if t.Subject != subject {
+			continue
+		}
+
+		if _, ok := objects[t.Predicate]; !ok {
+			predicates = append(predicates, t.Predicate)
+		}
+		objects[t.Predicate] = append(objects[t.Predicate], t.Object)
+	}
+
+	for i, predicate := range predicates {
+		if i == 0 {
+			w.buf.WriteString(" ")
+		} else {
+			w.buf.WriteString(" ;\n")
+			w.buf.WriteString(strings.Repeat("    ", depth))
+		}
+
+		if predicate.Kind == IRI && predicate.Value == rdfType {
+			w.buf.WriteString("a")
+		} else {
+			w.buf.WriteString(w.term(predicate))
+		}
+
+		for j, object := range objects[predicate] {
+			if j == 0 {
+				w.buf.WriteString(" ")
+			} else {
+				w.buf.WriteString(", ")
+			}
+			w.writeObject(object, depth)
+		}
+	}
+}
+
+// writeObject writes an object, nesting it if it is a blank node that is only
+// referred to once.
+func (w *turtleWriter) writeObject(object Term, depth int) {
+	if object.Kind != BlankNode || !w.nested[object] || w.written[object] {
+		w.buf.WriteString(w.term(object))
+		return
+	}
+
+	w.written[object] = true
+
+	w.buf.WriteString("[")
+	start := w.buf.Len()
+	w.writePredicates(object, depth+1)
+	if w.buf.Len() > start {
+		w.buf.WriteString(" ")
+	}
+	w.buf.WriteString("]")
+}
+
+// term returns the Turtle form of a term, using prefixed names where possible.
+func (w *turtleWriter) term(t Term) string {
+	switch t.Kind {
+	case IRI:
+		if name, ok := w.graph.Compact(t.Value); ok {
+			return name
+		}
+	case Literal:
+		if t.Language == "" && t.Datatype != "" && t.Datatype != xsdString {
+			if name, ok := w.graph.Compact(t.Datatype); ok {
+				return quote(t.Value) + "^^" + name
+			}
+		}
+	}
+
+	return t.String()
+}
+
+// parseTurtle parses a Turtle document, adding its prefixes and triples to the
+// graph.
+func parseTurtle(s string, g *Graph) error {
+	tokens, err := tokenizeTurtle(s)
+	if err != nil {
+		return err
+	}
+
+	p := &turtleParser{
+		tokens:     tokens,
+		graph:      g,
+		blankNodes: make(map[string]Term),
+	}
+
+	return p.parse()
+}
+
+// turtleParser holds the state used while parsing a Turtle document.
+type turtleParser struct {
+	tokens     []token
+	pos        int
+	graph      *Graph
+	blankNodes map[string]Term
+}
+
+// parse parses every statement in the document.
+func (p *turtleParser) parse() error {
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+
+		if tok.kind == nameToken {
+			switch {
+			case tok.value == "@prefix" || strings.EqualFold(tok.value, "PREFIX"):
+				err := p.prefix()
+				if err != nil {
+					return err
+				}
+				continue
+			case tok.value == "@base" || strings.EqualFold(tok.value, "BASE"):
+				return tok.errorf("base IRIs are not supported")
+			}
+		}
+
+		subject, err := p.subject()
+		if err != nil {
+			return err
+		}
+
+		// a nested blank node may stand alone as a statement
+		if !(tok.kind == punctToken && tok.value == "[" && p.is(punctToken, ".")) {
+			err = p.predicateObjectList(subject)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = p.expect(punctToken, ".")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prefix parses an @prefix or PREFIX directive.
+func (p *turtleParser) prefix() error {
+	directive := p.tokens[p.pos]
+	p.pos++
+
+	name, err := p.next()
+	if err != nil {
+		return err
+	}
+
+	if name.kind != nameToken || !strings.HasSuffix(name.value, ":") || strings.Count(name.value, ":") != 1 {
+		return name.errorf("malformed prefix directive")
+	}
+
+	iri, err := p.next()
+	if err != nil {
+		return err
+	}
+
+	if iri.kind != iriToken {
+		return iri.errorf("malformed prefix directive")
+	}
+
+	p.graph.Prefixes[strings.TrimSuffix(name.value, ":")] = iri.value
+
+	if directive.value == "@prefix" {
+		_, err = p.expect(punctToken, ".")
+	}
+
+	return err
+}
+
+// subject parses the subject of a statement.
+func (p *turtleParser) subject() (Term, error) {
+	tok, err := p.next()
+	if err != nil {
+		return Term{}, err
+	}
+
+	switch tok.kind {
+	case iriToken:
+		return NewIRI(tok.value), nil
+	case punctToken:
+		if tok.value == "[" {
+			return p.blankNodePropertyList()
+		}
+	case nameToken:
+		return p.resource(tok)
+	}
+
+	return Term{}, tok.errorf("unexpected %q", tok.value)
+}
+
+// predicateObjectList parses the predicates and objects of a subject,
+// separated by ";".
+func (p *turtleParser) predicateObjectList(subject Term) error {
+	for {
+		predicate, err := p.predicate()
+		if err != nil {
+			return err
+		}
+
+		err = p.objectList(subject, predicate)
+		if err != nil {
+			return err
+		}
+
+		if !p.is(punctToken, ";") {
+			return nil
+		}
+
+		// repeated and trailing separators are allowed
+		for p.is(punctToken, ";") {
+			p.pos++
+		}
+
+		if p.is(punctToken, ".") || p.is(punctToken, "]") {
+			return nil
+		}
+	}
+}
+
+// objectList parses the objects of a predicate, separated by ",".
+func (p *turtleParser) objectList(subject, predicate Term) error {
+	for {
+		object, err := p.object()
+		if err != nil {
+			return err
+		}
+
+		p.graph.Add(subject, predicate, object)
+
+		if !p.is(punctToken, ",") {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+// predicate parses a predicate, which must be an IRI.
+func (p *turtleParser) predicate() (Term, error) {
+	tok, err := p.next()
+	if err != nil {
+		return Term{}, err
+	}
+
+	switch tok.kind {
+	case iriToken:
+		return NewIRI(tok.value), nil
+	case nameToken:
+		if tok.value == "a" {
+			return NewIRI(rdfType), nil
+		}
+
+		term, err := p.resource(tok)
+		if err != nil {
+			return Term{}, err
+		}
+
+		if term.Kind == IRI {
+			return term, nil
+		}
+	}
+
+	return Term{}, tok.errorf("unexpected %q, expected a predicate", tok.value)
+}
+
+// object parses an object, which may be an IRI, a blank node or a literal.
+func (p *turtleParser) object() (Term, error) {
+	tok, err := p.next()
+	if err != nil {
+		return Term{}, err
+	}
+
+	switch tok.kind {
+	case iriToken:
+		return NewIRI(tok.value), nil
+
+	case literalToken:
+		if tok.language != "" {
+			return NewLangLiteral(tok.value, tok.language), nil
+		}
+
+		if !p.is(punctToken, "^^") {
+			return NewLiteral(tok.value), nil
+		}
+		p.pos++
+
+		datatype, err := p.next()
+		if err != nil {
+			return Term{}, err
+		}
+
+		switch datatype.kind {
+		case iriToken:
+			return NewTypedLiteral(tok.value, datatype.value), nil
+		case nameToken:
+			term, err := p.resource(datatype)
+			if err != nil {
+				return Term{}, err
+			}
+			if term.Kind == IRI {
+				return NewTypedLiteral(tok.value, term.Value), nil
+			}
+		}
+
+		return Term{}, datatype.errorf("unexpected %q, expected a datatype", datatype.value)
+
+	case punctToken:
+		switch tok.value {
+		case "[":
+			return p.blankNodePropertyList()
+		case "(":
+			return Term{}, tok.errorf("collections are not supported")
+		}
+
+	case nameToken:
+		switch tok.value {
+		case "true", "false":
+			return NewTypedLiteral(tok.value, xsdBoolean), nil
+		}
+
+		if r := tok.value[0]; (r >= '0' && r <= '9') || r == '+' || r == '-' || r == '.' {
+			return number(tok)
+		}
+
+		return p.resource(tok)
+	}
+
+	return Term{}, tok.errorf("unexpected %q", tok.value)
+}
+
+// blankNodePropertyList parses a nested blank node after its opening "[".
+func (p *turtleParser) blankNodePropertyList() (Term, error) {
+	node := p.graph.NewBlankNode()
+
+	if p.is(punctToken, "]") {
+		p.pos++
+		return node, nil
+	}
+
+	err := p.predicateObjectList(node)
+	if err != nil {
+		return Term{}, err
+	}
+
+	_, err = p.expect(punctToken, "]")
+	if err != nil {
+		return Term{}, err
+	}
+
+	return node, nil
+}
+
+// resource resolves a prefixed name or blank node label.
+func (p *turtleParser) resource(tok token) (Term, error) {
+	if strings.HasPrefix(tok.value, "_:") {
+		node, ok := p.blankNodes[tok.value]
+		if !ok {
+			node = p.graph.NewBlankNode()
+			p.blankNodes[tok.value] = node
+		}
+		return node, nil
+	}
+
+	idx := strings.Index(tok.value, ":")
+	if idx == -1 {
+		return Term{}, tok.errorf("unexpected %q", tok.value)
+	}
+
+	namespace, ok := p.graph.Prefixes[tok.value[:idx]]
+	if !ok {
+		return Term{}, tok.errorf("undefined prefix %q", tok.value[:idx])
+	}
+
+	// reserved characters may be escaped with a backslash in local names
+	local := strings.Replace(tok.value[idx+1:], `\`, "", -1)
+
+	return NewIRI(namespace + local), nil
+}
+
+// next returns the next token.
+func (p *turtleParser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, errors.New("unexpected end of input")
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	return tok, nil
+}
+
+// expect returns the next token, which must have the given kind and value.
+func (p *turtleParser) expect(kind tokenKind, value string) (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, errors.Errorf("unexpected end of input, expected %q", value)
+	}
+
+	tok := p.tokens[p.pos]
+	if tok.kind != kind || tok.value != value {
+		return token{}, tok.errorf("unexpected %q, expected %q", tok.value, value)
+	}
+	p.pos++
+
+	return tok, nil
+}
+
+// is returns true if the next token has the given kind and value.
+func (p *turtleParser) is(kind tokenKind, value string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind && p.tokens[p.pos].value == value
+}
+
+// number returns the literal for a numeric token, typed as xsd:integer,
+// xsd:decimal or xsd:double depending on its form.
+func number(tok token) (Term, error) {
+	_, err := strconv.ParseFloat(tok.value, 64)
+	if err != nil {
+		return Term{}, tok.errorf("invalid number %q", tok.value)
+	}
+
+	switch {
+	case strings.ContainsAny(tok.value, "eE"):
+		return NewTypedLiteral(tok.value, xsdDouble), nil
+	case strings.Contains(tok.value, "."):
+		return NewTypedLiteral(tok.value, xsdDecimal), nil
+	}
+
+	return NewTypedLiteral(tok.value, xsdInteger), nil
+}
+
+// tokenKind is the kind of a Turtle token.
+type tokenKind int
+
+const (
+	iriToken tokenKind = iota
+	nameToken
+	literalToken
+	punctToken
+)
+
+// token is a single Turtle token. Names include prefixed names, blank node
+// labels, keywords and numbers.
+type token struct {
+	kind     tokenKind
+	value    string
+	language string
+	line     int
+}
+
+// errorf returns an error including the line of the token.
+func (t token) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+// tokenizeTurtle splits the input into tokens, stripping comments and decoding
+// escape sequences within IRIs and strings.
+func tokenizeTurtle(s string) ([]token, error) {
+	var tokens []token
+
+	runes := []rune(s)
+	line := 1
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case r == '\n':
+			line++
+			i++
+
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case r == '<':
+			end := i + 1
+			for end < len(runes) && runes[end] != '>' {
+				if unicode.IsSpace(runes[end]) || runes[end] == '<' {
+					break
+				}
+				if strings.ContainsRune(`"{}|^`+"`", runes[end]) {
+					return nil, errors.Errorf("line %d: invalid character %q in IRI", line, runes[end])
+				}
+				end++
+			}
+			if end >= len(runes) || runes[end] != '>' {
+				return nil, errors.Errorf("line %d: unterminated IRI", line)
+			}
+
+			value, err := unescape(runes[i+1:end], line)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{kind: iriToken, value: value, line: line})
+			i = end + 1
+
+		case r == '"' || r == '\'':
+			start := line
+
+			// long strings are delimited by three quotes and may span lines
+			delim := []rune{r}
+			if i+2 < len(runes) && runes[i+1] == r && runes[i+2] == r {
+				delim = []rune{r, r, r}
+			}
+			i += len(delim)
+
+			end := i
+			for ; end < len(runes); end++ {
+				if runes[end] == '\\' {
+					end++
+					continue
+				}
+				if runes[end] == '\n' {
+					if len(delim) == 1 {
+						break
+					}
+					line++
+				}
+				if runes[end] == r && end+len(delim) <= len(runes) && string(runes[end:end+len(delim)]) == string(delim) {
+					break
+				}
+			}
+			if end >= len(runes) || runes[end] != r {
+				return nil, errors.Errorf("line %d: unterminated string literal", start)
+			}
+
+			value, err := unescape(runes[i:end], start)
+			if err != nil {
+				return nil, err
+			}
+
+			tok := token{kind: literalToken, value: value, line: start}
+			i = end + len(delim)
+
+			if i < len(runes) && runes[i] == '@' {
+				end = i + 1
+				for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '-') {
+					end++
+				}
+				tok.language = string(runes[i+1 : end])
+				i = end
+			}
+
+			tokens = append(tokens, tok)
+
+		case r == '^' && i+1 < len(runes) && runes[i+1] == '^':
+			tokens = append(tokens, token{kind: punctToken, value: "^^", line: line})
+			i += 2
+
+		case strings.ContainsRune(".;,[]()", r) && !(r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			tokens = append(tokens, token{kind: punctToken, value: string(r), line: line})
+			i++
+
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(";,<>\"'[]()#^", runes[end]) {
+				// a . only continues a name if followed by another name character
+				if runes[end] == '.' && (end+1 >= len(runes) || unicode.IsSpace(runes[end+1]) || strings.ContainsRune(".;,[]()#", runes[end+1])) {
+					break
+				}
+				end++
+			}
+			if end == i {
+				return nil, errors.Errorf("line %d: unexpected %q", line, string(r))
+			}
+			tokens = append(tokens, token{kind: nameToken, value: string(runes[i:end]), line: line})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+// unescape decodes the escape sequences within an IRI or string.
+func unescape(runes []rune, line int) (string, error) {
+	var buf bytes.Buffer
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\\' {
+			buf.WriteRune(runes[i])
+			continue
+		}
+
+		i++
+		if i >= len(runes) {
+			return "", errors.Errorf("line %d: invalid escape sequence", line)
+		}
+
+		switch runes[i] {
+		case 't':
+			buf.WriteRune('\t')
+		case 'b':
+			buf.WriteRune('\b')
+		case 'n':
+			buf.WriteRune('\n')
+		case 'r':
+			buf.WriteRune('\r')
+		case 'f':
+			buf.WriteRune('\f')
+		case '"', '\'', '\\':
+			buf.WriteRune(runes[i])
+		case 'u', 'U':
+			size := 4
+			if runes[i] == 'U' {
+				size = 8
+			}
+			if i+size >= len(runes) {
+				return "", errors.Errorf("line %d: invalid escape sequence", line)
+			}
+			code, err := strconv.ParseUint(string(runes[i+1:i+1+size]), 16, 32)
+			if err != nil {
+				return "", errors.Errorf("line %d: invalid escape sequence", line)
+			}
+			buf.WriteRune(rune(code))
+			i += size
+		default:
+			return "", errors.Errorf("line %d: invalid escape sequence \\%c", line, runes[i])
+		}
+	}
+
+	return buf.String(), nil
+}
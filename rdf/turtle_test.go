@@ -0,0 +1,156 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdf_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot/rdf"
+)
+
+func TestParseTurtle(t *testing.T) {
+	input := `
+@prefix ex: <http://example.com/> .
+PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+
+# comments are ignored
+ex:a a ex:Thing ;
+	ex:name "A", 'a'@en ;
+	ex:description """spans
+lines""" ;
+	ex:count 3 ; ex:ratio -0.5 ; ex:size 1.5e3 ;
+	ex:active true ;
+	ex:created "2017-06-01T00:00:00Z"^^xsd:dateTime ;
+	ex:link <http://example.com/b!> ;
+	ex:child [ ex:name "child" ], _:shared ;
+	ex:empty [] ;
+	.
+
+_:shared ex:name "shared\té" .
+
+[ ex:name "standalone" ] .
+`
+
+	g, err := rdf.Parse(strings.NewReader(input), rdf.Turtle)
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]string{
+		"ex":  "http://example.com/",
+		"xsd": "http://www.w3.org/2001/XMLSchema#",
+	}, g.Prefixes)
+
+	ex := func(name string) rdf.Term {
+		return rdf.NewIRI("http://example.com/" + name)
+	}
+	blank := func(label string) rdf.Term {
+		return rdf.Term{Kind: rdf.BlankNode, Value: label}
+	}
+
+	a := ex("a")
+
+	assert.Equal(t, []rdf.Triple{
+		{a, rdf.NewIRI(rdf.RDFNamespace + "type"), ex("Thing")},
+		{a, ex("name"), rdf.NewLiteral("A")},
+		{a, ex("name"), rdf.NewLangLiteral("a", "en")},
+		{a, ex("description"), rdf.NewLiteral("spans\nlines")},
+		{a, ex("count"), rdf.NewTypedLiteral("3", rdf.XSDNamespace+"integer")},
+		{a, ex("ratio"), rdf.NewTypedLiteral("-0.5", rdf.XSDNamespace+"decimal")},
+		{a, ex("size"), rdf.NewTypedLiteral("1.5e3", rdf.XSDNamespace+"double")},
+		{a, ex("active"), rdf.NewTypedLiteral("true", rdf.XSDNamespace+"boolean")},
+		{a, ex("created"), rdf.NewTypedLiteral("2017-06-01T00:00:00Z", rdf.XSDNamespace+"dateTime")},
+		{a, ex("link"), ex("b!")},
+		{blank("b1"), ex("name"), rdf.NewLiteral("child")},
+		{a, ex("child"), blank("b1")},
+		{a, ex("child"), blank("b2")},
+		{a, ex("empty"), blank("b3")},
+		{blank("b2"), ex("name"), rdf.NewLiteral("shared\té")},
+		{blank("b4"), ex("name"), rdf.NewLiteral("standalone")},
+	}, g.Triples)
+}
+
+func TestParseTurtleInvalid(t *testing.T) {
+	testcases := []struct {
+		label string
+		input string
+		err   string
+	}{
+		{"undefined prefix", `ex:a ex:b ex:c .`, `line 1: undefined prefix "ex"`},
+		{"missing terminator", "<urn:a> <urn:b> <urn:c>", `unexpected end of input, expected "."`},
+		{"unterminated iri", `<urn:a <urn:b> <urn:c> .`, `line 1: unterminated IRI`},
+		{"unterminated literal", "<urn:a> <urn:b> \"c .\n", `line 1: unterminated string literal`},
+		{"invalid escape", `<urn:a> <urn:b> "\q" .`, `line 1: invalid escape sequence \q`},
+		{"malformed prefix", `@prefix ex <http://example.com/> .`, `line 1: malformed prefix directive`},
+		{"base", `@base <http://example.com/> .`, `line 1: base IRIs are not supported`},
+		{"collection", `<urn:a> <urn:b> ( <urn:c> ) .`, `line 1: collections are not supported`},
+		{"literal predicate", `<urn:a> "b" <urn:c> .`, `line 1: unexpected "b", expected a predicate`},
+		{"literal subject", "\n\"a\" <urn:b> <urn:c> .", `line 2: unexpected "a"`},
+		{"unclosed blank node", `<urn:a> <urn:b> [ <urn:c> <urn:d> .`, `line 1: unexpected ".", expected "]"`},
+		{"invalid number", `<urn:a> <urn:b> 1.2.3 .`, `line 1: invalid number "1.2.3"`},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			_, err := rdf.Parse(strings.NewReader(testcase.input), rdf.Turtle)
+			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), testcase.err)
+		})
+	}
+}
+
+func TestWriteTurtle(t *testing.T) {
+	g := rdf.NewGraph(map[string]string{"ex": "http://example.com/"})
+
+	a := rdf.NewIRI("http://example.com/a")
+	p := rdf.NewIRI("http://example.com/p")
+	nested := g.NewBlankNode()
+	shared := g.NewBlankNode()
+	cycle1 := g.NewBlankNode()
+	cycle2 := g.NewBlankNode()
+
+	g.Add(a, rdf.NewIRI(rdf.RDFNamespace+"type"), rdf.NewIRI("http://example.com/Thing"))
+	g.Add(a, p, nested)
+	g.Add(a, p, shared)
+	g.Add(nested, p, rdf.NewTypedLiteral("1", rdf.XSDNamespace+"integer"))
+	g.Add(nested, p, g.NewBlankNode())
+	g.Add(shared, p, rdf.NewIRI("http://example.org/other"))
+	g.Add(a, rdf.NewIRI("http://example.com/q"), shared)
+	g.Add(cycle1, p, cycle2)
+	g.Add(cycle2, p, cycle1)
+
+	var buf bytes.Buffer
+
+	err := rdf.Write(&buf, g, rdf.Turtle)
+	assert.Nil(t, err)
+	assert.Equal(t, `@prefix ex: <http://example.com/> .
+
+ex:a a ex:Thing ;
+    ex:p [ ex:p "1"^^<http://www.w3.org/2001/XMLSchema#integer>, [] ], _:b2 ;
+    ex:q _:b2 .
+
+_:b2 ex:p <http://example.org/other> .
+
+_:b3 ex:p [ ex:p _:b3 ] .
+`, buf.String())
+
+	// the output is parsed back into the same graph, though blank nodes are
+	// relabelled
+	parsed, err := rdf.Parse(&buf, rdf.Turtle)
+	assert.Nil(t, err)
+	assert.Len(t, parsed.Triples, len(g.Triples))
+	assert.Equal(t, g.Prefixes, parsed.Prefixes)
+}
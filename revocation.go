@@ -0,0 +1,428 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRevoked is the error returned when validating a token which is otherwise
+// valid, but which a RevocationChecker reports has been revoked.
+var ErrRevoked = errors.New("access token revoked")
+
+// RevocationChecker is the interface used to check whether access has been
+// revoked for a consumer presenting an otherwise valid token, so that
+// misbehaving subscribers can be blocked before their tokens expire. It is
+// called with the Subscriber extracted from the token, which includes the
+// token ID (jti) if the token has one.
+type RevocationChecker interface {
+	// IsRevoked reports whether the token presented by the subscriber has been
+	// revoked. If an error is returned the token is rejected. Implementations
+	// must be safe for concurrent use.
+	IsRevoked(ctx context.Context, subscriber Subscriber) (bool, error)
+}
+
+// WithRevocationChecker allows a caller to reject tokens which have been
+// revoked before they expire. Tokens are checked by ValidateToken and by the
+// RequireToken middleware, which responds with a 403 Forbidden error if the
+// token has been revoked.
+//
+// Example:
+// 		provider, _ := bigiot.NewProvider(
+//			providerID,
+//			providerSecret,
+//			bigiot.WithRevocationChecker(bigiot.NewFileRevocationList("/etc/bigiot/revoked")),
+// 		)
+func WithRevocationChecker(checker RevocationChecker) Option {
+	return func(b *base) error {
+		b.revocation = checker
+
+		return nil
+	}
+}
+
+// checkRevocation returns ErrRevoked if the checker reports the token of the
+// subscriber has been revoked.
+func checkRevocation(ctx context.Context, checker RevocationChecker, subscriber Subscriber) error {
+	if checker == nil {
+		return nil
+	}
+
+	revoked, err := checker.IsRevoked(ctx, subscriber)
+	if err != nil {
+		return errors.Wrap(err, "error checking revocation")
+	}
+
+	if revoked {
+		return ErrRevoked
+	}
+
+	return nil
+}
+
+// RevocationList is an in-memory RevocationChecker. Access can be revoked for
+// a subscriber, a single token, or the subscription of a subscriber to one
+// offering. Each offering can also have an allow list, in which case only the
+// subscribers on it may access the offering, and a deny list of subscribers who
+// may not.
+type RevocationList struct {
+	mu            sync.RWMutex
+	subscribers   map[string]bool
+	tokens        map[string]bool
+	subscriptions map[subscription]bool
+	allowed       map[string]map[string]bool
+	denied        map[string]map[string]bool
+}
+
+// subscription identifies the subscription of a subscriber to an offering.
+type subscription struct {
+	subscriberID string
+	offeringID   string
+}
+
+// NewRevocationList returns an empty RevocationList.
+func NewRevocationList() *RevocationList {
+	return &RevocationList{
+		subscribers:   make(map[string]bool),
+		tokens:        make(map[string]bool),
+		subscriptions: make(map[subscription]bool),
+		allowed:       make(map[string]map[string]bool),
+		denied:        make(map[string]map[string]bool),
+	}
+}
+
+// RevokeSubscriber revokes access to all offerings for the given subscriber.
+func (l *RevocationList) RevokeSubscriber(subscriberID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.subscribers[subscriberID] = true
+}
+
+// RevokeToken revokes the token with the given ID (jti).
+func (l *RevocationList) RevokeToken(tokenID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens[tokenID] = true
+}
+
+// RevokeSubscription revokes access to a single offering for the given
+// subscriber.
+func (l *RevocationList) RevokeSubscription(subscriberID, offeringID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.subscriptions[subscription{subscriberID, offeringID}] = true
+}
+
+// Allow adds subscribers to the allow list of an offering. Once an offering
+// has an allow list, access is revoked for every subscriber not on it.
+func (l *RevocationList) Allow(offeringID string, subscriberIDs ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	addToList(l.allowed, offeringID, subscriberIDs)
+}
+
+// Deny adds subscribers to the deny list of an offering.
+func (l *RevocationList) Deny(offeringID string, subscriberIDs ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	addToList(l.denied, offeringID, subscriberIDs)
+}
+
+// IsRevoked is our implementation of RevocationChecker.
+func (l *RevocationList) IsRevoked(ctx context.Context, subscriber Subscriber) (bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.subscribers[subscriber.ID] {
+		return true, nil
+	}
+
+	if subscriber.TokenID != "" && l.tokens[subscriber.TokenID] {
+		return true, nil
+	}
+
+	if l.subscriptions[subscription{subscriber.ID, subscriber.OfferingID}] {
+		return true, nil
+	}
+
+	if l.denied[subscriber.OfferingID][subscriber.ID] {
+		return true, nil
+	}
+
+	if allowed, ok := l.allowed[subscriber.OfferingID]; ok && !allowed[subscriber.ID] {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// addToList adds subscribers to the list for an offering.
+func addToList(lists map[string]map[string]bool, offeringID string, subscriberIDs []string) {
+	list, ok := lists[offeringID]
+	if !ok {
+		list = make(map[string]bool)
+		lists[offeringID] = list
+	}
+
+	for _, subscriberID := range subscriberIDs {
+		list[subscriberID] = true
+	}
+}
+
+// ReadRevocationList reads a RevocationList from r. Each line of the input
+// contains a single entry, and blank lines and lines starting with # are
+// ignored. The entries are:
+//
+//		subscriber <subscriberID>
+//		token <tokenID>
+//		subscription <subscriberID> <offeringID>
+//		allow <offeringID> <subscriberID>...
+//		deny <offeringID> <subscriberID>...
+func ReadRevocationList(r io.Reader) (*RevocationList, error) {
+	l := NewRevocationList()
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch {
+		case fields[0] == "subscriber" && len(fields) == 2:
+			l.RevokeSubscriber(fields[1])
+		case fields[0] == "token" && len(fields) == 2:
+			l.RevokeToken(fields[1])
+		case fields[0] == "subscription" && len(fields) == 3:
+			l.RevokeSubscription(fields[1], fields[2])
+		case fields[0] == "allow" && len(fields) >= 3:
+			l.Allow(fields[1], fields[2:]...)
+		case fields[0] == "deny" && len(fields) >= 3:
+			l.Deny(fields[1], fields[2:]...)
+		default:
+			return nil, errors.Errorf("line %d: invalid revocation entry", line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading revocation list")
+	}
+
+	return l, nil
+}
+
+// DefaultRevocationReloadInterval is the default interval at which a
+// FileRevocationList checks whether its file has changed.
+const DefaultRevocationReloadInterval = time.Second
+
+// FileRevocationList is a RevocationChecker backed by a file in the format
+// read by ReadRevocationList. The file is checked in the background and read
+// again whenever its modification time or size changes, so operators can
+// block subscribers within moments by editing it. Tokens are checked against
+// the last list read successfully, so a half-edited file containing an invalid
+// entry doesn't interrupt access; the error is reported via Err and the
+// WithRevocationErrorHandler option instead. If the file has never been read
+// successfully, IsRevoked returns an error, so that tokens are rejected rather
+// than accepted without the check.
+type FileRevocationList struct {
+	path     string
+	interval time.Duration
+	errFn    func(error)
+
+	// reloadMu serialises reloads of the file
+	reloadMu sync.Mutex
+	modTime  time.Time
+	size     int64
+
+	mu   sync.RWMutex
+	list *RevocationList
+	err  error
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// FileRevocationOption is a functional configuration type used to configure
+// optional behaviour of a FileRevocationList.
+type FileRevocationOption func(*FileRevocationList)
+
+// WithReloadInterval is a FileRevocationOption setting how often the file is
+// checked for changes. The default is DefaultRevocationReloadInterval.
+func WithReloadInterval(interval time.Duration) FileRevocationOption {
+	return func(l *FileRevocationList) {
+		l.interval = interval
+	}
+}
+
+// WithRevocationErrorHandler is a FileRevocationOption allowing a caller to be
+// notified when the file can't be read or contains an invalid entry, for
+// example in order to log it. It is called once for each failed change to the
+// file.
+func WithRevocationErrorHandler(fn func(error)) FileRevocationOption {
+	return func(l *FileRevocationList) {
+		l.errFn = fn
+	}
+}
+
+// NewFileRevocationList returns a FileRevocationList reading the file at the
+// given path. The file is read immediately, and then checked for changes in
+// the background until Close is called.
+//
+// Example:
+//		revocations := bigiot.NewFileRevocationList(
+//			"/etc/bigiot/revoked",
+//			bigiot.WithRevocationErrorHandler(func(err error) {
+//				log.Printf("revocation list not reloaded: %v", err)
+//			}),
+//		)
+//		defer revocations.Close()
+func NewFileRevocationList(path string, options ...FileRevocationOption) *FileRevocationList {
+	l := &FileRevocationList{
+		path:     path,
+		interval: DefaultRevocationReloadInterval,
+		done:     make(chan struct{}),
+	}
+
+	for _, opt := range options {
+		opt(l)
+	}
+
+	_ = l.Reload()
+
+	go l.run()
+
+	return l
+}
+
+// IsRevoked is our implementation of RevocationChecker.
+func (l *FileRevocationList) IsRevoked(ctx context.Context, subscriber Subscriber) (bool, error) {
+	l.mu.RLock()
+	list, err := l.list, l.err
+	l.mu.RUnlock()
+
+	if list == nil {
+		return false, err
+	}
+
+	return list.IsRevoked(ctx, subscriber)
+}
+
+// Err returns the error from the most recent attempt to read the file, or nil
+// if the list currently in use is up to date.
+func (l *FileRevocationList) Err() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.err
+}
+
+// Reload reads the file again if it has changed since it was last read. If
+// the file can't be read or is invalid, the error is returned and the
+// previous list remains in use. Reload is called periodically in the
+// background, but may also be called directly, for example on SIGHUP.
+func (l *FileRevocationList) Reload() error {
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
+
+	info, err := os.Stat(l.path)
+	if err != nil {
+		// read the file again when it reappears, whatever its modification time
+		l.modTime, l.size = time.Time{}, 0
+		return l.failed(errors.Wrap(err, "error reading revocation list"))
+	}
+
+	if info.ModTime().Equal(l.modTime) && info.Size() == l.size {
+		// unchanged since the last attempt, whether or not it succeeded
+		return l.Err()
+	}
+
+	l.modTime = info.ModTime()
+	l.size = info.Size()
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return l.failed(errors.Wrap(err, "error reading revocation list"))
+	}
+	defer f.Close()
+
+	list, err := ReadRevocationList(f)
+	if err != nil {
+		return l.failed(errors.Wrap(err, "error reading revocation list"))
+	}
+
+	l.mu.Lock()
+	l.list = list
+	l.err = nil
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Close stops checking the file for changes. The last list read remains in
+// use.
+func (l *FileRevocationList) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+
+	return nil
+}
+
+// run checks the file for changes every interval until the list is closed.
+func (l *FileRevocationList) run() {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = l.Reload()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// failed records an error reading the file, reporting it to the error handler
+// if it differs from the previous error. The caller must hold reloadMu.
+func (l *FileRevocationList) failed(err error) error {
+	l.mu.Lock()
+	previous := l.err
+	l.err = err
+	l.mu.Unlock()
+
+	if l.errFn != nil && (previous == nil || previous.Error() != err.Error()) {
+		l.errFn(err)
+	}
+
+	return err
+}
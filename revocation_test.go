@@ -0,0 +1,238 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/mocks"
+)
+
+func TestRevocationList(t *testing.T) {
+	list := bigiot.NewRevocationList()
+	list.RevokeSubscriber("Abuser")
+	list.RevokeToken("token-1")
+	list.RevokeSubscription("Consumer", "Provider-Parking")
+	list.Allow("Provider-Private", "Partner")
+	list.Deny("Provider-Weather", "Scraper")
+
+	testcases := []struct {
+		name       string
+		subscriber bigiot.Subscriber
+		revoked    bool
+	}{
+		{"subscriber", bigiot.Subscriber{ID: "Abuser", OfferingID: "Provider-Weather"}, true},
+		{"token", bigiot.Subscriber{ID: "Consumer", OfferingID: "Provider-Weather", TokenID: "token-1"}, true},
+		{"other token", bigiot.Subscriber{ID: "Consumer", OfferingID: "Provider-Weather", TokenID: "token-2"}, false},
+		{"subscription", bigiot.Subscriber{ID: "Consumer", OfferingID: "Provider-Parking"}, true},
+		{"other subscription", bigiot.Subscriber{ID: "Consumer", OfferingID: "Provider-Weather"}, false},
+		{"allowed", bigiot.Subscriber{ID: "Partner", OfferingID: "Provider-Private"}, false},
+		{"not allowed", bigiot.Subscriber{ID: "Consumer", OfferingID: "Provider-Private"}, true},
+		{"denied", bigiot.Subscriber{ID: "Scraper", OfferingID: "Provider-Weather"}, true},
+		{"denied for other offering", bigiot.Subscriber{ID: "Scraper", OfferingID: "Provider-Parking"}, false},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			revoked, err := list.IsRevoked(context.Background(), testcase.subscriber)
+			assert.Nil(t, err)
+			assert.Equal(t, testcase.revoked, revoked)
+		})
+	}
+}
+
+func TestReadRevocationList(t *testing.T) {
+	list, err := bigiot.ReadRevocationList(strings.NewReader(`
+# blocked for scraping
+subscriber Abuser
+subscription Consumer Provider-Parking
+allow Provider-Private Partner Other-Partner
+`))
+	assert.Nil(t, err)
+
+	revoked, err := list.IsRevoked(context.Background(), bigiot.Subscriber{ID: "Abuser"})
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = list.IsRevoked(context.Background(), bigiot.Subscriber{ID: "Other-Partner", OfferingID: "Provider-Private"})
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	invalid := []string{
+		"subscriber",
+		"subscriber a b",
+		"subscription Consumer",
+		"allow Provider-Private",
+		"block Abuser",
+	}
+
+	for _, input := range invalid {
+		t.Run(input, func(t *testing.T) {
+			_, err := bigiot.ReadRevocationList(strings.NewReader(input))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestFileRevocationList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bigiot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var errs []error
+
+	path := filepath.Join(dir, "revoked")
+	list := bigiot.NewFileRevocationList(
+		path,
+		bigiot.WithReloadInterval(time.Hour),
+		bigiot.WithRevocationErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+	defer list.Close()
+
+	subscriber := bigiot.Subscriber{ID: "Abuser", OfferingID: "Provider-Parking"}
+
+	// a missing file is an error so that tokens are not accepted unchecked
+	_, err = list.IsRevoked(context.Background(), subscriber)
+	assert.NotNil(t, err)
+	assert.NotNil(t, list.Err())
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("subscriber Other\n"), 0600))
+	assert.Nil(t, list.Reload())
+
+	revoked, err := list.IsRevoked(context.Background(), subscriber)
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("subscriber Other\nsubscriber Abuser\n"), 0600))
+	assert.Nil(t, list.Reload())
+
+	revoked, err = list.IsRevoked(context.Background(), subscriber)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	// an invalid file is reported, and the last valid list remains in use
+	assert.Nil(t, ioutil.WriteFile(path, []byte("invalid\n"), 0600))
+	assert.NotNil(t, list.Reload())
+	assert.NotNil(t, list.Reload())
+
+	revoked, err = list.IsRevoked(context.Background(), subscriber)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+	assert.NotNil(t, list.Err())
+	assert.Len(t, errs, 2)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("subscriber Other\n"), 0600))
+	assert.Nil(t, list.Reload())
+
+	revoked, err = list.IsRevoked(context.Background(), subscriber)
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+	assert.Nil(t, list.Err())
+}
+
+func TestFileRevocationListReloadsInBackground(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bigiot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "revoked")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("subscriber Other\n"), 0600))
+
+	list := bigiot.NewFileRevocationList(path, bigiot.WithReloadInterval(5*time.Millisecond))
+	defer list.Close()
+
+	subscriber := bigiot.Subscriber{ID: "Abuser", OfferingID: "Provider-Parking"}
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("subscriber Other\nsubscriber Abuser\n"), 0600))
+
+	var revoked bool
+	for i := 0; i < 100 && !revoked; i++ {
+		time.Sleep(5 * time.Millisecond)
+		revoked, err = list.IsRevoked(context.Background(), subscriber)
+		assert.Nil(t, err)
+	}
+
+	assert.True(t, revoked)
+}
+
+func TestRequireTokenRevoked(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+
+	list := bigiot.NewRevocationList()
+	list.Deny("Provider-Offering", "Abuser")
+
+	p, err := bigiot.NewProvider(
+		"Provider",
+		testSecret,
+		bigiot.WithClock(mocks.Clock{T: now}),
+		bigiot.WithRevocationChecker(list),
+	)
+	assert.Nil(t, err)
+
+	validator := bigiot.NewTokenValidator(
+		bigiot.WithValidatorClock(mocks.Clock{T: now}),
+		bigiot.WithValidatorRevocationChecker(list),
+	)
+	validator.AddProvider("Provider", bigiot.StaticCredentials(testSecret))
+
+	allowed := signToken(t, testSecret, "Provider-Offering", "Consumer", now)
+	denied := signToken(t, testSecret, "Provider-Offering", "Abuser", now)
+
+	_, err = p.ValidateToken(allowed)
+	assert.Nil(t, err)
+
+	_, err = p.ValidateToken(denied)
+	assert.Equal(t, bigiot.ErrRevoked, errors.Cause(err))
+
+	_, err = validator.ValidateToken(denied)
+	assert.Equal(t, bigiot.ErrRevoked, errors.Cause(err))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, handler := range []http.Handler{p.RequireToken(next), validator.RequireToken(next)} {
+		req := httptest.NewRequest(http.MethodGet, "/offering", nil)
+		req.Header.Set("Authorization", "Bearer "+allowed)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/offering", nil)
+		req.Header.Set("Authorization", "Bearer "+denied)
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	// subscribers are blocked as soon as they are added to the list
+	list.RevokeSubscriber("Consumer")
+
+	_, err = p.ValidateToken(allowed)
+	assert.Equal(t, bigiot.ErrRevoked, errors.Cause(err))
+}
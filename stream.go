@@ -0,0 +1,223 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamBufferSize is the number of records we buffer for each connected
+	// subscriber. If a subscriber falls further behind than this they are
+	// disconnected rather than being allowed to block delivery to everyone
+	// else.
+	streamBufferSize = 64
+
+	// streamWriteTimeout is the maximum time we allow for writing a single
+	// message to a subscriber.
+	streamWriteTimeout = 10 * time.Second
+)
+
+// StreamHandler returns an http.Handler that serves an offering registered
+// with an EndpointType of WebSocket. The consumer's token is validated before
+// the connection is upgraded, after which every Record received on the given
+// channel is encoded according to the description's Outputs and pushed to all
+// connected subscribers as a JSON message. When the records channel is closed
+// all subscriber connections are closed, and any subsequent connection
+// attempts are closed immediately after upgrading. Subscribers which fall too
+// far behind are disconnected with a "try again later" close code, so that
+// consumers using Subscription.Stream reconnect.
+//
+// Example:
+//		records := make(chan bigiot.Record)
+//
+//		http.Handle("/traffic", provider.StreamHandler(offering.ID, description, records))
+func (p *Provider) StreamHandler(offeringID string, description *OfferingDescription, records <-chan Record) http.Handler {
+	h := &streamHandler{
+		offeringID:  offeringID,
+		description: description,
+		subscribers: make(map[*streamSubscriber]struct{}),
+	}
+
+	go h.run(records)
+
+	return p.RequireToken(h)
+}
+
+// streamHandler is our unexported http.Handler implementation that upgrades
+// requests from consumers to WebSocket connections, and then broadcasts
+// records to each connected subscriber.
+type streamHandler struct {
+	offeringID  string
+	description *OfferingDescription
+	upgrader    websocket.Upgrader
+
+	mu          sync.Mutex
+	subscribers map[*streamSubscriber]struct{}
+	closed      bool
+}
+
+// streamSubscriber represents a single connected consumer. Records to be sent
+// to the consumer are written to the send channel. If the consumer is dropped
+// for falling behind, dropped is set before send is closed.
+type streamSubscriber struct {
+	send    chan Record
+	dropped bool
+}
+
+// ServeHTTP is our implementation of http.Handler.
+func (h *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	subscriber, ok := SubscriberFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing access token")
+		return
+	}
+
+	if subscriber.OfferingID != h.offeringID {
+		writeError(w, http.StatusForbidden, "access token not valid for this offering")
+		return
+	}
+
+	// the upgrader writes an error response to the client if the upgrade fails
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	s, ok := h.subscribe()
+	if !ok {
+		closeConn(conn, websocket.CloseNormalClosure, "stream closed")
+		return
+	}
+
+	// we must read from the connection in order to process control messages
+	// from the client, and to notice when the client goes away
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				h.unsubscribe(s)
+				return
+			}
+		}
+	}()
+
+	for record := range s.send {
+		err = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err == nil {
+			err = conn.WriteJSON(record)
+		}
+
+		if err != nil {
+			h.unsubscribe(s)
+			return
+		}
+	}
+
+	if s.dropped {
+		// the consumer should reconnect, unlike when the stream has ended
+		closeConn(conn, websocket.CloseTryAgainLater, "subscriber too slow")
+		return
+	}
+
+	closeConn(conn, websocket.CloseNormalClosure, "stream closed")
+}
+
+// run reads records from the given channel and broadcasts them to all
+// currently connected subscribers, until the channel is closed.
+func (h *streamHandler) run(records <-chan Record) {
+	for record := range records {
+		encoded, err := EncodeRecords(h.description.Outputs, record)
+		if err != nil {
+			// the record doesn't match the declared outputs, so we skip it
+			continue
+		}
+
+		h.mu.Lock()
+		for s := range h.subscribers {
+			select {
+			case s.send <- encoded[0]:
+			default:
+				// this subscriber isn't keeping up, so drop them rather than
+				// blocking everyone else
+				delete(h.subscribers, s)
+				s.dropped = true
+				close(s.send)
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for s := range h.subscribers {
+		delete(h.subscribers, s)
+		close(s.send)
+	}
+}
+
+// subscribe registers a new subscriber, returning false if the stream has
+// already been closed.
+func (h *streamHandler) subscribe() (*streamSubscriber, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, false
+	}
+
+	s := &streamSubscriber{
+		send: make(chan Record, streamBufferSize),
+	}
+
+	h.subscribers[s] = struct{}{}
+
+	return s, true
+}
+
+// unsubscribe removes a subscriber, closing its send channel if it is still
+// registered.
+func (h *streamHandler) unsubscribe(s *streamSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.send)
+	}
+}
+
+// closeConn attempts to send a close message to the client. Errors are
+// ignored as the connection is being torn down regardless.
+func closeConn(conn *websocket.Conn, code int, text string) {
+	_ = conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, text),
+		time.Now().Add(streamWriteTimeout),
+	)
+}
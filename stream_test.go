@@ -0,0 +1,100 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+func TestStreamHandler(t *testing.T) {
+	p, err := bigiot.NewProvider("id", testSecret)
+	assert.Nil(t, err)
+
+	description := &bigiot.OfferingDescription{
+		Outputs: []bigiot.DataField{
+			{Name: "speed", RdfURI: "schema:speed"},
+		},
+	}
+
+	records := make(chan bigiot.Record)
+	done := make(chan struct{})
+
+	// keep publishing until the test tells us to stop, as we can't know exactly
+	// when our subscriber has been registered
+	go func() {
+		defer close(records)
+		for {
+			select {
+			case records <- bigiot.Record{"speed": 42, "internal": true}:
+			case <-done:
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	server := httptest.NewServer(p.StreamHandler("Provider-Offering", description, records))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	t.Run("unauthorized", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("wrong offering", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+signToken(t, testSecret, "Provider-OtherOffering", "Consumer-Query", time.Now()))
+
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("receives records", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+signToken(t, testSecret, "Provider-Offering", "Consumer-Query", time.Now()))
+
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		_, msg, err := conn.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, `{"speed":42}`, strings.TrimSpace(string(msg)))
+
+		close(done)
+
+		// drain until the server closes the stream
+		for {
+			_, _, err = conn.ReadMessage()
+			if err != nil {
+				break
+			}
+		}
+
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+	})
+}
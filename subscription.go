@@ -0,0 +1,440 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultReconnectDelay is the default delay before a stream reconnects
+	// after its connection fails. The delay doubles after each failed attempt
+	// up to DefaultMaxReconnectDelay.
+	DefaultReconnectDelay = time.Second
+
+	// DefaultMaxReconnectDelay is the default maximum delay between attempts to
+	// reconnect a stream.
+	DefaultMaxReconnectDelay = 30 * time.Second
+
+	// DefaultMaxResponseSize is the default maximum size in bytes of a response
+	// read by Subscription.Access.
+	DefaultMaxResponseSize = 10 * 1024 * 1024
+
+	// streamHandshakeTimeout is the maximum time allowed for the WebSocket
+	// handshake when connecting a stream.
+	streamHandshakeTimeout = 10 * time.Second
+)
+
+// errStreamClosed is returned internally when the provider closes a stream
+// normally, after which we don't reconnect.
+var errStreamClosed = errors.New("stream closed by provider")
+
+// Subscription is a consumer's subscription to an offering. It contains the
+// endpoint of the offering, and the access token issued by the marketplace
+// for the subscription which is presented to the provider on every request.
+// The ID and Price of the subscription are used to track its cost when
+// accessed with the WithCostTracker option.
+type Subscription struct {
+	ID          string
+	OfferingID  string
+	AccessToken string
+	Endpoint    Endpoint
+	Price       Price
+}
+
+// RequestOption is a functional configuration type used to configure optional
+// behaviour of Subscription.Access.
+type RequestOption func(*accessCall)
+
+// WithCostTracker is a RequestOption which reserves the estimated cost of the
+// access against the subscription's budget before the request is made, failing
+// with ErrBudgetExceeded without making the request if the budget would be
+// exceeded, and records the actual cost once the response has been received.
+func WithCostTracker(tracker *CostTracker) RequestOption {
+	return func(c *accessCall) {
+		c.tracker = tracker
+	}
+}
+
+// WithRequestHTTPClient is a RequestOption setting the http.Client used to
+// make the request. The default is http.DefaultClient.
+func WithRequestHTTPClient(client *http.Client) RequestOption {
+	return func(c *accessCall) {
+		c.client = client
+	}
+}
+
+// WithMaxResponseSize is a RequestOption setting the maximum size in bytes of
+// the provider's response. Larger responses fail with an error rather than
+// being read into memory. The default is DefaultMaxResponseSize.
+func WithMaxResponseSize(size int64) RequestOption {
+	return func(c *accessCall) {
+		c.maxResponseSize = size
+	}
+}
+
+// accessCall holds the configuration of a single call to Subscription.Access.
+type accessCall struct {
+	tracker         *CostTracker
+	client          *http.Client
+	maxResponseSize int64
+}
+
+// Access requests records from an offering with an EndpointType of HTTPGet or
+// HTTPPost. Inputs are sent as query parameters to HTTPGet endpoints, and as a
+// JSON object to HTTPPost endpoints, and the records returned by the provider
+// are decoded from JSON. An error is returned if the provider responds with
+// an error, using the first message of the error response.
+//
+// Example:
+//		tracker := bigiot.NewCostTracker(nil)
+//		tracker.SetBudget(subscription.ID, budget)
+//
+//		records, err := subscription.Access(ctx, map[string]interface{}{
+//			"latitude":  52.52,
+//			"longitude": 13.4,
+//		}, bigiot.WithCostTracker(tracker))
+//		if err != nil {
+//			panic(err) // handle error properly
+//		}
+func (s *Subscription) Access(ctx context.Context, inputs map[string]interface{}, options ...RequestOption) ([]Record, error) {
+	c := &accessCall{
+		client:          http.DefaultClient,
+		maxResponseSize: DefaultMaxResponseSize,
+	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	req, err := s.accessRequest(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	var reservation *Reservation
+
+	if c.tracker != nil {
+		reservation, err = c.tracker.Reserve(s.ID, s.Price)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := c.do(ctx, req)
+	if err != nil {
+		if reservation != nil {
+			reservation.Release()
+		}
+
+		return nil, err
+	}
+
+	if reservation != nil {
+		reservation.Commit(int64(len(body)))
+	}
+
+	var records []Record
+
+	err = json.Unmarshal(body, &records)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding records")
+	}
+
+	return records, nil
+}
+
+// accessRequest builds the request for an access of the subscription with the
+// given inputs.
+func (s *Subscription) accessRequest(inputs map[string]interface{}) (*http.Request, error) {
+	var (
+		req *http.Request
+		err error
+	)
+
+	switch s.Endpoint.EndpointType {
+	case HTTPGet:
+		req, err = http.NewRequest(http.MethodGet, s.Endpoint.URI, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid endpoint uri")
+		}
+
+		query := req.URL.Query()
+		for name, value := range inputs {
+			query.Set(name, FormatInput(value))
+		}
+		req.URL.RawQuery = query.Encode()
+
+	case HTTPPost:
+		b, err := json.Marshal(inputs)
+		if err != nil {
+			return nil, errors.Wrap(err, "error encoding inputs")
+		}
+
+		req, err = http.NewRequest(http.MethodPost, s.Endpoint.URI, bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid endpoint uri")
+		}
+
+		req.Header.Set(contentTypeHeader, applicationJSON)
+
+	default:
+		return nil, errors.Errorf("endpoint type %s does not support access requests", s.Endpoint.EndpointType)
+	}
+
+	req.Header.Set(authorizationHeader, bearerPrefix+s.AccessToken)
+
+	return req, nil
+}
+
+// do makes the request, returning the body of a successful response.
+func (c *accessCall) do(ctx context.Context, req *http.Request) ([]byte, error) {
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "error making access request")
+	}
+
+	defer resp.Body.Close()
+
+	// read one byte more than the limit so that we can tell it was exceeded
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.maxResponseSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading access response")
+	}
+
+	if int64(len(body)) > c.maxResponseSize {
+		return nil, errors.Errorf("access response exceeds the maximum size of %d bytes", c.maxResponseSize)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errorResp := ErrorResponse{}
+
+		err = json.Unmarshal(body, &errorResp)
+		if err != nil || len(errorResp.Errors) == 0 {
+			return nil, errors.Errorf("access request failed with status %d", resp.StatusCode)
+		}
+
+		return nil, errors.New(errorResp.Errors[0].Message)
+	}
+
+	return body, nil
+}
+
+// StreamOption is a functional configuration type used to configure optional
+// behaviour of Subscription.Stream.
+type StreamOption func(*streamClient)
+
+// WithReconnectDelay is a StreamOption setting the delay before the first
+// attempt to reconnect a stream, and the maximum delay between subsequent
+// attempts. The defaults are DefaultReconnectDelay and
+// DefaultMaxReconnectDelay.
+func WithReconnectDelay(delay, max time.Duration) StreamOption {
+	return func(c *streamClient) {
+		c.delay = delay
+		c.maxDelay = max
+	}
+}
+
+// WithStreamErrorHandler is a StreamOption allowing a caller to be notified of
+// the errors which cause a stream to reconnect, or to stop if the provider
+// rejects the access token.
+func WithStreamErrorHandler(fn func(error)) StreamOption {
+	return func(c *streamClient) {
+		c.errFn = fn
+	}
+}
+
+// Stream connects to an offering with an EndpointType of WebSocket, and
+// returns a channel on which every record pushed by the provider is delivered,
+// decoded from JSON. If the connection fails the stream reconnects
+// automatically, waiting longer after each failed attempt. The channel is
+// closed when the context is cancelled, when the provider closes the stream
+// normally, or when the provider rejects the access token. An error is returned
+// immediately if the subscription's endpoint is not a WebSocket endpoint.
+//
+// Example:
+//		records, err := subscription.Stream(ctx)
+//		if err != nil {
+//			panic(err) // handle error properly
+//		}
+//
+//		for record := range records {
+//			fmt.Println(record["speed"])
+//		}
+func (s *Subscription) Stream(ctx context.Context, options ...StreamOption) (<-chan Record, error) {
+	if s.Endpoint.EndpointType != WebSocket {
+		return nil, errors.Errorf("endpoint type %s does not support streaming", s.Endpoint.EndpointType)
+	}
+
+	u, err := url.Parse(s.Endpoint.URI)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid endpoint uri")
+	}
+
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return nil, errors.Errorf("unsupported endpoint uri scheme %s", u.Scheme)
+	}
+
+	c := &streamClient{
+		url:      u.String(),
+		header:   http.Header{},
+		dialer:   &websocket.Dialer{HandshakeTimeout: streamHandshakeTimeout},
+		delay:    DefaultReconnectDelay,
+		maxDelay: DefaultMaxReconnectDelay,
+		records:  make(chan Record),
+	}
+
+	c.header.Set(authorizationHeader, bearerPrefix+s.AccessToken)
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	go c.run(ctx)
+
+	return c.records, nil
+}
+
+// streamClient is our unexported type which maintains the connection of a
+// stream, reconnecting as required.
+type streamClient struct {
+	url      string
+	header   http.Header
+	dialer   *websocket.Dialer
+	delay    time.Duration
+	maxDelay time.Duration
+	errFn    func(error)
+	records  chan Record
+}
+
+// run connects to the stream and delivers records until the stream ends,
+// reconnecting with an increasing delay whenever the connection fails.
+func (c *streamClient) run(ctx context.Context) {
+	defer close(c.records)
+
+	delay := c.delay
+
+	for {
+		received, err := c.receive(ctx)
+		if ctx.Err() != nil || err == errStreamClosed {
+			return
+		}
+
+		if c.errFn != nil {
+			c.errFn(err)
+		}
+
+		if _, ok := err.(*streamRejectedError); ok {
+			return
+		}
+
+		// a connection which delivered records was healthy, so start backing off
+		// from the initial delay again
+		if received {
+			delay = c.delay
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > c.maxDelay {
+			delay = c.maxDelay
+		}
+	}
+}
+
+// receive makes a single connection to the stream and delivers the records
+// received until it ends. It returns true if any records were received.
+func (c *streamClient) receive(ctx context.Context) (bool, error) {
+	conn, resp, err := c.dialer.Dial(c.url, c.header)
+	if err != nil {
+		if err == websocket.ErrBadHandshake && resp != nil &&
+			(resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return false, &streamRejectedError{statusCode: resp.StatusCode}
+		}
+
+		return false, errors.Wrap(err, "error connecting to stream")
+	}
+
+	defer conn.Close()
+
+	// closing the connection unblocks the read below when the context is
+	// cancelled
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	received := false
+
+	for {
+		var record Record
+
+		err := conn.ReadJSON(&record)
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return received, errStreamClosed
+			}
+
+			return received, errors.Wrap(err, "error reading from stream")
+		}
+
+		received = true
+
+		select {
+		case c.records <- record:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+}
+
+// streamRejectedError is the error reported when the provider refuses the
+// access token of a stream, in which case we don't reconnect.
+type streamRejectedError struct {
+	statusCode int
+}
+
+// Error is our implementation of the error interface.
+func (e *streamRejectedError) Error() string {
+	return fmt.Sprintf("stream rejected with status %d", e.statusCode)
+}
@@ -0,0 +1,346 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+)
+
+func TestSubscriptionStream(t *testing.T) {
+	p, err := bigiot.NewProvider("id", testSecret)
+	assert.Nil(t, err)
+
+	description := &bigiot.OfferingDescription{
+		Outputs: []bigiot.DataField{
+			{Name: "speed", RdfURI: "schema:speed"},
+		},
+	}
+
+	records := make(chan bigiot.Record)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(records)
+		for {
+			select {
+			case records <- bigiot.Record{"speed": 42}:
+			case <-done:
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	server := httptest.NewServer(p.StreamHandler("Provider-Offering", description, records))
+	defer server.Close()
+
+	subscription := &bigiot.Subscription{
+		OfferingID:  "Provider-Offering",
+		AccessToken: signToken(t, testSecret, "Provider-Offering", "Consumer-Query", time.Now()),
+		Endpoint:    bigiot.Endpoint{EndpointType: bigiot.WebSocket, URI: server.URL},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := subscription.Stream(ctx)
+	assert.Nil(t, err)
+
+	assert.Equal(t, bigiot.Record{"speed": float64(42)}, <-stream)
+
+	cancel()
+
+	// the channel is closed once the context is cancelled
+	for range stream {
+	}
+}
+
+func TestSubscriptionStreamReconnects(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		connections int
+	)
+
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		mu.Lock()
+		connections++
+		n := connections
+		mu.Unlock()
+
+		conn.WriteJSON(bigiot.Record{"connection": n})
+
+		if n == 1 {
+			// drop the connection without a close message
+			return
+		}
+
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "stream closed"))
+	}))
+	defer server.Close()
+
+	subscription := &bigiot.Subscription{
+		AccessToken: "token",
+		Endpoint:    bigiot.Endpoint{EndpointType: bigiot.WebSocket, URI: "ws" + server.URL[len("http"):]},
+	}
+
+	var errs []error
+
+	stream, err := subscription.Stream(
+		context.Background(),
+		bigiot.WithReconnectDelay(10*time.Millisecond, 20*time.Millisecond),
+		bigiot.WithStreamErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+	assert.Nil(t, err)
+
+	var received []bigiot.Record
+	for record := range stream {
+		received = append(received, record)
+	}
+
+	assert.Equal(t, []bigiot.Record{{"connection": float64(1)}, {"connection": float64(2)}}, received)
+	assert.Len(t, errs, 1)
+}
+
+func TestSubscriptionStreamReconnectsWhenDropped(t *testing.T) {
+	p, err := bigiot.NewProvider("id", testSecret)
+	assert.Nil(t, err)
+
+	description := &bigiot.OfferingDescription{
+		Outputs: []bigiot.DataField{
+			{Name: "seq", RdfURI: "schema:position"},
+			{Name: "payload", RdfURI: "schema:text"},
+		},
+	}
+
+	records := make(chan bigiot.Record)
+	defer close(records)
+
+	server := httptest.NewServer(p.StreamHandler("Provider-Offering", description, records))
+	defer server.Close()
+
+	subscription := &bigiot.Subscription{
+		AccessToken: signToken(t, testSecret, "Provider-Offering", "Consumer-Query", time.Now()),
+		Endpoint:    bigiot.Endpoint{EndpointType: bigiot.WebSocket, URI: server.URL},
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := subscription.Stream(
+		ctx,
+		bigiot.WithReconnectDelay(10*time.Millisecond, 20*time.Millisecond),
+		bigiot.WithStreamErrorHandler(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}),
+	)
+	assert.Nil(t, err)
+
+	// publish the given record until it is received, as we can't know exactly
+	// when the subscriber has been registered
+	await := func(seq int) {
+		timeout := time.After(5 * time.Second)
+
+		for {
+			select {
+			case records <- bigiot.Record{"seq": seq, "payload": ""}:
+			case <-timeout:
+				t.Fatalf("record %d not received", seq)
+			}
+
+			select {
+			case record := <-stream:
+				if record["seq"] == float64(seq) {
+					return
+				}
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}
+
+	await(1)
+
+	// without reading from the stream, publish more large records than can be
+	// buffered for the subscriber, so that it is dropped
+	payload := strings.Repeat("x", 64*1024)
+	for i := 0; i < 1000; i++ {
+		records <- bigiot.Record{"seq": 0, "payload": payload}
+	}
+
+	// the consumer reconnects and receives records published afterwards
+	await(2)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if assert.NotEmpty(t, errs) {
+		assert.Contains(t, errs[0].Error(), "subscriber too slow")
+	}
+}
+
+func TestSubscriptionStreamRejected(t *testing.T) {
+	p, err := bigiot.NewProvider("id", testSecret)
+	assert.Nil(t, err)
+
+	records := make(chan bigiot.Record)
+	defer close(records)
+
+	server := httptest.NewServer(p.StreamHandler("Provider-Offering", &bigiot.OfferingDescription{}, records))
+	defer server.Close()
+
+	subscription := &bigiot.Subscription{
+		AccessToken: "invalid",
+		Endpoint:    bigiot.Endpoint{EndpointType: bigiot.WebSocket, URI: server.URL},
+	}
+
+	var errs []error
+
+	stream, err := subscription.Stream(
+		context.Background(),
+		bigiot.WithStreamErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+	assert.Nil(t, err)
+
+	// the stream ends without reconnecting
+	_, ok := <-stream
+	assert.False(t, ok)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "stream rejected with status 401", errs[0].Error())
+
+	subscription.Endpoint.EndpointType = bigiot.HTTPGet
+
+	_, err = subscription.Stream(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestSubscriptionAccess(t *testing.T) {
+	p, err := bigiot.NewProvider("id", testSecret)
+	assert.Nil(t, err)
+
+	description := &bigiot.OfferingDescription{
+		Inputs: []bigiot.DataField{
+			{Name: "latitude", RdfURI: "schema:latitude", Datatype: bigiot.XSDDouble},
+		},
+		Outputs: []bigiot.DataField{
+			{Name: "speed", RdfURI: "schema:speed"},
+		},
+	}
+
+	var calls int
+
+	handler := p.AccessHandler("Provider-Offering", description, func(ctx context.Context, req bigiot.AccessRequest) (bigiot.AccessResponse, error) {
+		calls++
+		return bigiot.AccessResponse{
+			Records: []bigiot.Record{
+				{"speed": req.Inputs["latitude"]},
+			},
+		}, nil
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	price := bigiot.Price{
+		PricingModel: bigiot.PerAccess,
+		Money:        bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR},
+	}
+
+	tracker := bigiot.NewCostTracker(nil)
+	tracker.SetBudget("sub", bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR})
+
+	for _, endpointType := range []bigiot.EndpointType{bigiot.HTTPGet, bigiot.HTTPPost} {
+		subscription := &bigiot.Subscription{
+			ID:          "sub",
+			OfferingID:  "Provider-Offering",
+			AccessToken: signToken(t, testSecret, "Provider-Offering", "Consumer-Query", time.Now()),
+			Endpoint:    bigiot.Endpoint{EndpointType: endpointType, URI: server.URL},
+			Price:       price,
+		}
+
+		records, err := subscription.Access(context.Background(), map[string]interface{}{"latitude": 52.5})
+		assert.Nil(t, err)
+		assert.Equal(t, []bigiot.Record{{"speed": 52.5}}, records)
+	}
+
+	subscription := &bigiot.Subscription{
+		ID:          "sub",
+		OfferingID:  "Provider-Offering",
+		AccessToken: signToken(t, testSecret, "Provider-Offering", "Consumer-Query", time.Now()),
+		Endpoint:    bigiot.Endpoint{EndpointType: bigiot.HTTPGet, URI: server.URL},
+		Price:       price,
+	}
+
+	_, err = subscription.Access(context.Background(), map[string]interface{}{"latitude": 52.5}, bigiot.WithCostTracker(tracker))
+	assert.Nil(t, err)
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR}, tracker.Spent("sub"))
+
+	// the budget is spent, so the next access fails without calling the provider
+	_, err = subscription.Access(context.Background(), map[string]interface{}{"latitude": 52.5}, bigiot.WithCostTracker(tracker))
+	assert.IsType(t, &bigiot.ErrBudgetExceeded{}, err)
+	assert.Equal(t, 3, calls)
+
+	// failed accesses release their reservation without being charged
+	tracker.SetBudget("sub", bigiot.Money{Amount: bigiot.NewAmount(2, 0), Currency: bigiot.EUR})
+	subscription.AccessToken = "invalid"
+
+	_, err = subscription.Access(context.Background(), map[string]interface{}{"latitude": 52.5}, bigiot.WithCostTracker(tracker))
+	assert.NotNil(t, err)
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR}, tracker.Spent("sub"))
+	assert.Nil(t, tracker.Check("sub", price))
+
+	// responses larger than the maximum size fail without being charged
+	subscription.AccessToken = signToken(t, testSecret, "Provider-Offering", "Consumer-Query", time.Now())
+
+	_, err = subscription.Access(context.Background(), map[string]interface{}{"latitude": 52.5}, bigiot.WithCostTracker(tracker), bigiot.WithMaxResponseSize(4))
+	assert.NotNil(t, err)
+	assert.Equal(t, "access response exceeds the maximum size of 4 bytes", err.Error())
+	assert.Equal(t, bigiot.Money{Amount: bigiot.NewAmount(1, 0), Currency: bigiot.EUR}, tracker.Spent("sub"))
+	assert.Equal(t, 4, calls)
+}
+
+func TestSubscriptionAccessUnsupportedEndpoint(t *testing.T) {
+	subscription := &bigiot.Subscription{
+		Endpoint: bigiot.Endpoint{EndpointType: bigiot.WebSocket, URI: "ws://example.com"},
+	}
+
+	_, err := subscription.Access(context.Background(), nil)
+	assert.NotNil(t, err)
+}
@@ -32,6 +32,16 @@ func FromEpochMs(v int64) time.Time {
 	return time.Unix(0, nanosec).UTC()
 }
 
+// epochMs returns the time as epoch milliseconds, or 0 for the zero time,
+// matching the handling of zero values when decoding marketplace responses.
+func epochMs(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
 // Clock is an interface used to make it possible to test time related code more
 // easily.
 type Clock interface {
@@ -0,0 +1,193 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// The keys of the attributes we set on spans.
+const (
+	// AttributeOperation is the name of the marketplace operation, e.g.
+	// addOffering.
+	AttributeOperation = "bigiot.operation"
+
+	// AttributeOutcome is the Outcome of a marketplace operation.
+	AttributeOutcome = "bigiot.outcome"
+
+	// AttributeOfferingID is the ID of the offering being activated, deleted or
+	// accessed.
+	AttributeOfferingID = "bigiot.offering.id"
+
+	// AttributeOfferingLocalID is the local ID of an offering being registered.
+	AttributeOfferingLocalID = "bigiot.offering.local_id"
+
+	// AttributeSubscriberID is the ID of the subscription of a consumer
+	// accessing an offering.
+	AttributeSubscriberID = "bigiot.subscriber.id"
+
+	// AttributeProviderID is the ID of the provider whose offering is being
+	// accessed.
+	AttributeProviderID = "bigiot.provider.id"
+)
+
+// Tracer is the interface used to create trace spans for requests made to the
+// marketplace, and for requests from consumers validated by RequireToken. It is
+// deliberately minimal so that it can be adapted to OpenTelemetry or any other
+// tracing library without this package depending on it.
+//
+// Spans named after the operation (e.g. bigiot.addOffering) are started for
+// every request made to the marketplace, and the trace context is injected
+// into the request headers. RequireToken extracts any trace context sent by
+// the consumer and starts a bigiot.access span covering the wrapped handler,
+// so the context passed to an AccessFunc can be used to continue the trace
+// into a data backend.
+//
+// Example:
+//		type otelTracer struct {
+//			tracer     trace.Tracer
+//			propagator propagation.TextMapPropagator
+//		}
+//
+//		func (t *otelTracer) Start(ctx context.Context, name string) (context.Context, bigiot.Span) {
+//			ctx, span := t.tracer.Start(ctx, name)
+//			return ctx, &otelSpan{span}
+//		}
+//
+//		func (t *otelTracer) Inject(ctx context.Context, header http.Header) {
+//			t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+//		}
+//
+//		func (t *otelTracer) Extract(ctx context.Context, header http.Header) context.Context {
+//			return t.propagator.Extract(ctx, propagation.HeaderCarrier(header))
+//		}
+type Tracer interface {
+	// Start starts a new span with the given name, as a child of any span
+	// contained in ctx. It returns a context containing the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+
+	// Inject adds the trace context of the span contained in ctx to the
+	// headers of an outgoing request.
+	Inject(ctx context.Context, header http.Header)
+
+	// Extract returns a context containing any trace context sent in the
+	// headers of an incoming request.
+	Extract(ctx context.Context, header http.Header) context.Context
+}
+
+// Span is a single span started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute on the span.
+	SetAttribute(key, value string)
+
+	// RecordError records an error which occurred during the span.
+	RecordError(err error)
+
+	// End completes the span.
+	End()
+}
+
+// WithTracer allows a caller to trace the requests made to the marketplace,
+// and the requests validated by RequireToken.
+//
+// Example:
+// 		provider, _ := bigiot.NewProvider(
+//			providerID,
+//			providerSecret,
+//			bigiot.WithTracer(tracer),
+// 		)
+func WithTracer(tracer Tracer) Option {
+	return func(b *base) error {
+		b.tracer = tracer
+
+		return nil
+	}
+}
+
+// noopTracer is the Tracer implementation used when the caller doesn't supply
+// one.
+type noopTracer struct{}
+
+// Start is our implementation of Tracer, returning a span that does nothing.
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// Inject is our implementation of Tracer, and does nothing.
+func (noopTracer) Inject(ctx context.Context, header http.Header) {}
+
+// Extract is our implementation of Tracer, returning ctx unchanged.
+func (noopTracer) Extract(ctx context.Context, header http.Header) context.Context {
+	return ctx
+}
+
+// noopSpan is the Span returned by noopTracer.
+type noopSpan struct{}
+
+// SetAttribute is our implementation of Span, and does nothing.
+func (noopSpan) SetAttribute(key, value string) {}
+
+// RecordError is our implementation of Span, and does nothing.
+func (noopSpan) RecordError(err error) {}
+
+// End is our implementation of Span, and does nothing.
+func (noopSpan) End() {}
+
+// call tracks a single request made to the marketplace, so that metrics, logs
+// and a span can be recorded for it. The outcome should be updated as the request
+// progresses, and end called once it completes.
+type call struct {
+	b         *base
+	operation string
+	span      Span
+	start     time.Time
+	outcome   Outcome
+}
+
+// startCall starts tracking a request to the marketplace, returning a context
+// containing the span for the request.
+func (b *base) startCall(ctx context.Context, operation string) (context.Context, *call) {
+	ctx, span := b.tracer.Start(ctx, "bigiot."+operation)
+	span.SetAttribute(AttributeOperation, operation)
+
+	return ctx, &call{
+		b:         b,
+		operation: operation,
+		span:      span,
+		start:     b.clock.Now(),
+		outcome:   OutcomeFailed,
+	}
+}
+
+// end records metrics for the request, logs its outcome and ends its span.
+func (c *call) end(err error) {
+	duration := c.b.clock.Now().Sub(c.start)
+
+	c.b.metrics.ObserveRequest(c.operation, c.outcome, duration)
+
+	if err != nil {
+		c.b.logger.Error("request failed", "operation", c.operation, "outcome", string(c.outcome), "duration", duration, "error", err)
+	} else {
+		c.b.logger.Info("request completed", "operation", c.operation, "outcome", string(c.outcome), "duration", duration)
+	}
+
+	c.span.SetAttribute(AttributeOutcome, string(c.outcome))
+	if err != nil {
+		c.span.RecordError(err)
+	}
+	c.span.End()
+}
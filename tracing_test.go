@@ -0,0 +1,215 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/mocks"
+	"github.com/thingful/simular"
+)
+
+type spanKey struct{}
+
+type recordedSpan struct {
+	id         string
+	parent     string
+	name       string
+	attributes map[string]string
+	errors     []error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttribute(key, value string) {
+	s.attributes[key] = value
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.errors = append(s.errors, err)
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+// recordingTracer records every span started, propagating the ID of the
+// current span in a Traceparent header.
+type recordingTracer struct {
+	sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, bigiot.Span) {
+	t.Lock()
+	defer t.Unlock()
+
+	parent, _ := ctx.Value(spanKey{}).(string)
+
+	span := &recordedSpan{
+		id:         "span-" + strconv.Itoa(len(t.spans)+1),
+		parent:     parent,
+		name:       name,
+		attributes: make(map[string]string),
+	}
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, spanKey{}, span.id), span
+}
+
+func (t *recordingTracer) Inject(ctx context.Context, header http.Header) {
+	if id, ok := ctx.Value(spanKey{}).(string); ok {
+		header.Set("Traceparent", id)
+	}
+}
+
+func (t *recordingTracer) Extract(ctx context.Context, header http.Header) context.Context {
+	if id := header.Get("Traceparent"); id != "" {
+		return context.WithValue(ctx, spanKey{}, id)
+	}
+	return ctx
+}
+
+func TestTracingMarketplaceRequests(t *testing.T) {
+	simular.Activate()
+	defer simular.DeactivateAndReset()
+
+	simular.RegisterStubRequests(
+		simular.NewStubRequest(
+			http.MethodGet,
+			"https://market.big-iot.org/accessToken?clientId=Provider&clientSecret=secret",
+			simular.NewStringResponder(200, "1234abcd"),
+			simular.WithHeader(
+				&http.Header{
+					"Traceparent": []string{"span-1"},
+				},
+			),
+		),
+		simular.NewStubRequest(
+			http.MethodPost,
+			"https://market.big-iot.org/graphql",
+			simular.NewStringResponder(400, `{"data":null,"errors":[{"message":"offering not found"}]}`),
+			simular.WithHeader(
+				&http.Header{
+					"Traceparent": []string{"span-3"},
+				},
+			),
+		),
+	)
+
+	tracer := &recordingTracer{}
+
+	provider, err := bigiot.NewProvider(
+		"Provider",
+		"secret",
+		bigiot.WithTracer(tracer),
+	)
+	assert.Nil(t, err)
+
+	err = provider.Authenticate()
+	assert.Nil(t, err)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+
+	err = provider.DeleteOffering(ctx, &bigiot.DeleteOffering{ID: "Provider-Offering"})
+	assert.NotNil(t, err)
+
+	parent.End()
+
+	assert.Nil(t, simular.AllStubsCalled())
+	assert.Len(t, tracer.spans, 3)
+
+	auth := tracer.spans[0]
+	assert.Equal(t, "bigiot.accessToken", auth.name)
+	assert.Equal(t, "", auth.parent)
+	assert.Equal(t, map[string]string{
+		bigiot.AttributeOperation: bigiot.OperationAccessToken,
+		bigiot.AttributeOutcome:   string(bigiot.OutcomeSuccess),
+	}, auth.attributes)
+	assert.Len(t, auth.errors, 0)
+	assert.True(t, auth.ended)
+
+	del := tracer.spans[2]
+	assert.Equal(t, "bigiot.deleteOffering", del.name)
+	assert.Equal(t, "span-2", del.parent)
+	assert.Equal(t, map[string]string{
+		bigiot.AttributeOperation:  bigiot.OperationDeleteOffering,
+		bigiot.AttributeOutcome:    string(bigiot.OutcomeRejected),
+		bigiot.AttributeOfferingID: "Provider-Offering",
+	}, del.attributes)
+	assert.Len(t, del.errors, 1)
+	assert.True(t, del.ended)
+}
+
+func TestTracingRequireToken(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	tracer := &recordingTracer{}
+
+	p, err := bigiot.NewProvider(
+		"id",
+		testSecret,
+		bigiot.WithClock(mocks.Clock{T: now}),
+		bigiot.WithTracer(tracer),
+	)
+	assert.Nil(t, err)
+
+	var handlerSpan string
+
+	handler := p.RequireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan, _ = r.Context().Value(spanKey{}).(string)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/offering", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, testSecret, "Provider-Offering", "Consumer-Query", now))
+	req.Header.Set("Traceparent", "remote-span")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, tracer.spans, 1)
+
+	span := tracer.spans[0]
+	assert.Equal(t, "bigiot.access", span.name)
+	assert.Equal(t, "remote-span", span.parent)
+	assert.Equal(t, map[string]string{
+		bigiot.AttributeSubscriberID: "Consumer-Query",
+		bigiot.AttributeOfferingID:   "Provider-Offering",
+		bigiot.AttributeProviderID:   "id",
+	}, span.attributes)
+	assert.True(t, span.ended)
+
+	// the wrapped handler is called within the span
+	assert.Equal(t, span.id, handlerSpan)
+
+	// invalid tokens are recorded as errors
+	req = httptest.NewRequest(http.MethodGet, "/offering", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Len(t, tracer.spans, 2)
+	assert.Len(t, tracer.spans[1].errors, 1)
+	assert.True(t, tracer.spans[1].ended)
+}
@@ -16,6 +16,7 @@ package bigiot
 
 import (
 	"bytes"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/pkg/errors"
@@ -24,23 +25,30 @@ import (
 // authTransport is an internal implementation of the RoundTripper interface that
 // we use to wrap the transport on the http.Client used for making requests to
 // the BIGIoT marketplace. This custom transport adds auth credentials if any
-// are set, and also adds a user-agent string to send to the server.
+// are set, trace context headers if a Tracer is configured, and also adds a
+// user-agent string to send to the server.
 type authTransport struct {
 	bigiot  *base
 	proxied http.RoundTripper
 }
 
 // RoundTrip is our implementation of RoundTripper, which does the job of adding
-// auth credentials if any are present. We also supply a user agent if the
+// auth credentials if any are present and the request doesn't already carry
+// its own. We also supply a user agent if the
 // caller hasn't explicitly set one when calling the library.
 func (t authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if t.bigiot.accessToken != "" {
+	// don't replace credentials sent by an Authenticator
+	token := t.bigiot.token()
+	if token != "" && req.Header.Get(authorizationHeader) == "" {
 		var buf bytes.Buffer
 		buf.WriteString("Bearer ")
-		buf.WriteString(t.bigiot.accessToken)
+		buf.WriteString(token)
 		req.Header.Set(authorizationHeader, buf.String())
 	}
 
+	// propagate the trace context of the request
+	t.bigiot.tracer.Inject(req.Context(), req.Header)
+
 	// set our internal user agent if the client hasn't supplied one
 	if req.Header.Get(userAgentHeader) == "" {
 		req.Header.Set(userAgentHeader, t.bigiot.userAgent)
@@ -53,3 +61,42 @@ func (t authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 
 	return res, err
 }
+
+// loggingTransport is an internal implementation of the RoundTripper interface
+// used to log the requests an Authenticator makes to obtain an access token,
+// which unlike our GraphQL requests are not made by us directly. The body of a
+// response is only logged if the request was rejected, as a successful response
+// contains the access token.
+type loggingTransport struct {
+	logger    Logger
+	operation string
+	proxied   http.RoundTripper
+}
+
+// RoundTrip is our implementation of RoundTripper, which logs the URL of the
+// request and the status of the response at debug level.
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.logger.Debug("sending request", "operation", t.operation, "url", req.URL.String())
+
+	res, err := t.proxied.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusOK {
+		t.logger.Debug("received response", "operation", t.operation, "status", res.StatusCode)
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading response")
+	}
+
+	t.logger.Debug("received response", "operation", t.operation, "status", res.StatusCode, "body", body)
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return res, nil
+}
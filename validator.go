@@ -0,0 +1,326 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// TokenValidator validates the access tokens presented by consumers on behalf
+// of several provider accounts, each with its own secret. This allows a single
+// gateway to protect the offerings of all of those providers with one
+// middleware, where Provider.RequireToken only accepts tokens signed with the
+// secret of a single provider.
+//
+// The secret used to validate a token is selected from the offering ID in its
+// claims. Offerings can be mapped to a provider explicitly by means of
+// AddOffering, otherwise an offering belongs to the provider whose ID is the
+// longest prefix of the offering ID, as is the case for IDs assigned by the
+// marketplace (e.g. Organization-Provider-Offering). The claims are only
+// trusted once the signature has been verified with that provider's secret.
+// Tokens for offerings that can't be attributed to a provider are checked
+// against the secret of each provider in turn.
+type TokenValidator struct {
+	mu         sync.RWMutex
+	clock      Clock
+	tracer     Tracer
+	revocation RevocationChecker
+	grace      time.Duration
+	providers  map[string]*keyring
+	offerings  map[string]string
+}
+
+// ValidatorOption is a functional configuration type used to configure
+// optional behaviour of a TokenValidator.
+type ValidatorOption func(*TokenValidator)
+
+// WithValidatorClock is a ValidatorOption allowing a caller to specify a
+// custom Clock implementation. Typically this will only be used within tests.
+func WithValidatorClock(clock Clock) ValidatorOption {
+	return func(v *TokenValidator) {
+		v.clock = clock
+	}
+}
+
+// WithValidatorTracer is a ValidatorOption allowing a caller to trace the
+// requests validated by RequireToken, in the same way as WithTracer does for a
+// Provider.
+func WithValidatorTracer(tracer Tracer) ValidatorOption {
+	return func(v *TokenValidator) {
+		v.tracer = tracer
+	}
+}
+
+// WithValidatorGracePeriod is a ValidatorOption setting how long tokens signed
+// with the previous secret of a provider are still accepted after the secret
+// returned by its CredentialSource changes, in the same way as
+// WithRotationGracePeriod does for a Provider. The default is
+// DefaultRotationGracePeriod. As a ValidatorOption can't return an error,
+// negative periods are treated as zero rather than rejected, so only the
+// current secret is accepted.
+func WithValidatorGracePeriod(period time.Duration) ValidatorOption {
+	return func(v *TokenValidator) {
+		if period < 0 {
+			period = 0
+		}
+
+		v.grace = period
+	}
+}
+
+// WithValidatorRevocationChecker is a ValidatorOption allowing a caller to
+// reject tokens which have been revoked before they expire, in the same way as
+// WithRevocationChecker does for a Provider.
+func WithValidatorRevocationChecker(checker RevocationChecker) ValidatorOption {
+	return func(v *TokenValidator) {
+		v.revocation = checker
+	}
+}
+
+// NewTokenValidator returns a TokenValidator with no providers. Providers must
+// be added by means of AddProvider before any tokens will be accepted.
+//
+// Example:
+//
+//	validator := bigiot.NewTokenValidator()
+//	validator.AddProvider("Org-ParkingProvider", bigiot.EnvCredentials("PARKING_SECRET"))
+//	validator.AddProvider("Org-WeatherProvider", bigiot.EnvCredentials("WEATHER_SECRET"))
+//
+//	http.Handle("/", validator.RequireToken(handler))
+func NewTokenValidator(options ...ValidatorOption) *TokenValidator {
+	v := &TokenValidator{
+		clock:     &realClock{},
+		tracer:    noopTracer{},
+		grace:     DefaultRotationGracePeriod,
+		providers: make(map[string]*keyring),
+		offerings: make(map[string]string),
+	}
+
+	for _, opt := range options {
+		opt(v)
+	}
+
+	return v
+}
+
+// AddProvider adds the secret of a provider to the keyset, replacing any
+// existing secret for the provider.
+func (v *TokenValidator) AddProvider(providerID string, source CredentialSource) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.providers[providerID] = &keyring{
+		source: source,
+		grace:  v.grace,
+	}
+}
+
+// RemoveProvider removes the secret of a provider from the keyset, along with
+// any offerings mapped to it, so that tokens for its offerings are no longer
+// accepted.
+func (v *TokenValidator) RemoveProvider(providerID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	delete(v.providers, providerID)
+
+	for offeringID, id := range v.offerings {
+		if id == providerID {
+			delete(v.offerings, offeringID)
+		}
+	}
+}
+
+// AddOffering maps an offering to the provider whose secret should be used to
+// validate tokens issued for it. This is only required for offerings whose ID
+// is not prefixed with the ID of the provider.
+func (v *TokenValidator) AddOffering(offeringID, providerID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.offerings[offeringID] = providerID
+}
+
+// ValidateToken validates a token presented by a consumer, returning the
+// Subscriber it identifies, including the ID of the provider the token
+// belongs to, or an error if the token isn't valid for any of the providers or
+// has been revoked.
+func (v *TokenValidator) ValidateToken(tokenStr string) (Subscriber, error) {
+	return v.validateSubscriber(context.Background(), tokenStr)
+}
+
+// validateSubscriber does the work of validating a token, and checking it
+// hasn't been revoked.
+func (v *TokenValidator) validateSubscriber(ctx context.Context, tokenStr string) (Subscriber, error) {
+	subscriber, err := v.parseToken(tokenStr)
+	if err != nil {
+		return Subscriber{}, err
+	}
+
+	err = checkRevocation(ctx, v.revocation, subscriber)
+	if err != nil {
+		return Subscriber{}, err
+	}
+
+	return subscriber, nil
+}
+
+// parseToken verifies a token with the secret of the provider it belongs to.
+func (v *TokenValidator) parseToken(tokenStr string) (Subscriber, error) {
+	token, err := jwt.ParseSigned(tokenStr)
+	if err != nil {
+		return Subscriber{}, errors.Wrap(err, "error parsing token string")
+	}
+
+	now := v.clock.Now()
+
+	// the unverified claims are only used to select the key to verify the token
+	// with, so if they can't be read we fall back to trying every key
+	if unverified, err := unverifiedClaims(tokenStr); err == nil {
+		if providerID, ok := v.providerFor(unverified.SubscribableID); ok {
+			return v.validate(token, providerID, now)
+		}
+	}
+
+	for _, providerID := range v.providerIDs() {
+		subscriber, err := v.validate(token, providerID, now)
+		if err == nil {
+			return subscriber, nil
+		}
+	}
+
+	return Subscriber{}, errors.New("token not valid for any provider")
+}
+
+// RequireToken is an http middleware that validates the access token presented
+// by a consumer in the same way as Provider.RequireToken, but accepting tokens
+// for the offerings of any provider added to the TokenValidator. The
+// Subscriber stored in the request context contains the ID of the provider the
+// token belongs to.
+//
+// Example:
+//
+//	http.Handle("/", validator.RequireToken(handler))
+func (v *TokenValidator) RequireToken(next http.Handler) http.Handler {
+	return requireToken(v.tracer, v.validateSubscriber, next)
+}
+
+// validate verifies a token using the secret of the given provider, and
+// checks the offering in its verified claims belongs to that provider.
+func (v *TokenValidator) validate(token *jwt.JSONWebToken, providerID string, now time.Time) (Subscriber, error) {
+	v.mu.RLock()
+	keys, ok := v.providers[providerID]
+	v.mu.RUnlock()
+
+	if !ok {
+		return Subscriber{}, errors.Errorf("unknown provider %s", providerID)
+	}
+
+	validationKeys, err := keys.validationKeys(now)
+	if err != nil {
+		return Subscriber{}, err
+	}
+
+	cl, err := verifySignature(token, validationKeys)
+	if err != nil {
+		return Subscriber{}, err
+	}
+
+	if id, ok := v.providerFor(cl.SubscribableID); ok && id != providerID {
+		return Subscriber{}, errors.Errorf("token for offering %s signed by provider %s", cl.SubscribableID, providerID)
+	}
+
+	err = validateClaims(cl, now)
+	if err != nil {
+		return Subscriber{}, err
+	}
+
+	return Subscriber{
+		ID:         cl.SubscriberID,
+		OfferingID: cl.SubscribableID,
+		ProviderID: providerID,
+		TokenID:    cl.ID,
+	}, nil
+}
+
+// providerFor returns the ID of the provider an offering belongs to, either
+// mapped explicitly or the longest provider ID prefixing the offering ID.
+func (v *TokenValidator) providerFor(offeringID string) (string, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if providerID, ok := v.offerings[offeringID]; ok {
+		return providerID, true
+	}
+
+	match := ""
+	for providerID := range v.providers {
+		if len(providerID) > len(match) && strings.HasPrefix(offeringID, providerID+"-") {
+			match = providerID
+		}
+	}
+
+	return match, match != ""
+}
+
+// providerIDs returns the IDs of all providers in the keyset in a stable
+// order.
+func (v *TokenValidator) providerIDs() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	ids := make([]string, 0, len(v.providers))
+	for providerID := range v.providers {
+		ids = append(ids, providerID)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// unverifiedClaims decodes the claims of a compact serialized token without
+// verifying its signature. The version of the jwt library we depend on doesn't
+// expose this, and the claims must never be trusted until the signature has
+// been verified.
+func unverifiedClaims(tokenStr string) (*claims, error) {
+	parts := strings.Split(tokenStr, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not in compact serialization")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding token payload")
+	}
+
+	cl := &claims{}
+	err = json.Unmarshal(payload, cl)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling token payload")
+	}
+
+	return cl, nil
+}
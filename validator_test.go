@@ -0,0 +1,173 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigiot_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/mocks"
+)
+
+func TestTokenValidator(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+
+	validator := bigiot.NewTokenValidator(bigiot.WithValidatorClock(mocks.Clock{T: now}))
+	validator.AddProvider("Org-Parking", bigiot.StaticCredentials(testSecret))
+	validator.AddProvider("Org-Parking-Garages", bigiot.StaticCredentials(rotatedSecret))
+
+	testcases := []struct {
+		name       string
+		secret     string
+		offeringID string
+		providerID string
+		valid      bool
+	}{
+		{"provider prefix", testSecret, "Org-Parking-Spaces", "Org-Parking", true},
+		{"longest provider prefix", rotatedSecret, "Org-Parking-Garages-Spaces", "Org-Parking-Garages", true},
+		{"signed by another provider", rotatedSecret, "Org-Parking-Spaces", "", false},
+		{"unknown offering", rotatedSecret, "Other-Offering", "Org-Parking-Garages", true},
+		{"unknown secret", "dW5rbm93bg==", "Other-Offering", "", false},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			token := signToken(t, testcase.secret, testcase.offeringID, "Consumer-Query", now)
+
+			subscriber, err := validator.ValidateToken(token)
+			if testcase.valid {
+				assert.Nil(t, err)
+				assert.Equal(t, bigiot.Subscriber{
+					ID:         "Consumer-Query",
+					OfferingID: testcase.offeringID,
+					ProviderID: testcase.providerID,
+				}, subscriber)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+
+	_, err := validator.ValidateToken("invalid")
+	assert.NotNil(t, err)
+
+	// offerings mapped explicitly are only valid for their provider
+	token := signToken(t, rotatedSecret, "Other-Offering", "Consumer-Query", now)
+
+	validator.AddOffering("Other-Offering", "Org-Parking")
+
+	_, err = validator.ValidateToken(token)
+	assert.NotNil(t, err)
+
+	validator.AddOffering("Other-Offering", "Org-Parking-Garages")
+
+	subscriber, err := validator.ValidateToken(token)
+	assert.Nil(t, err)
+	assert.Equal(t, "Org-Parking-Garages", subscriber.ProviderID)
+
+	// tokens are rejected once their provider has been removed
+	validator.RemoveProvider("Org-Parking-Garages")
+
+	_, err = validator.ValidateToken(token)
+	assert.NotNil(t, err)
+
+	_, err = validator.ValidateToken(signToken(t, rotatedSecret, "Org-Parking-Garages-Spaces", "Consumer-Query", now))
+	assert.NotNil(t, err)
+
+	// expired tokens are rejected
+	_, err = validator.ValidateToken(signToken(t, testSecret, "Org-Parking-Spaces", "Consumer-Query", now.Add(-time.Hour)))
+	assert.NotNil(t, err)
+}
+
+func TestTokenValidatorGracePeriod(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	token := signToken(t, testSecret, "Org-Parking-Spaces", "Consumer-Query", now)
+
+	testcases := []struct {
+		name     string
+		period   time.Duration
+		accepted bool
+	}{
+		{"grace period", time.Minute, true},
+		{"no grace period", 0, false},
+		{"negative grace period", -time.Minute, false},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			source := &rotatingCredentials{secret: testSecret}
+
+			validator := bigiot.NewTokenValidator(
+				bigiot.WithValidatorClock(mocks.Clock{T: now}),
+				bigiot.WithValidatorGracePeriod(testcase.period),
+			)
+			validator.AddProvider("Org-Parking", source)
+
+			_, err := validator.ValidateToken(token)
+			assert.Nil(t, err)
+
+			source.rotate(rotatedSecret)
+
+			_, err = validator.ValidateToken(token)
+			assert.Equal(t, testcase.accepted, err == nil)
+		})
+	}
+}
+
+func TestTokenValidatorRequireToken(t *testing.T) {
+	now := time.Date(2018, 1, 1, 9, 4, 0, 0, time.UTC)
+	tracer := &recordingTracer{}
+
+	validator := bigiot.NewTokenValidator(
+		bigiot.WithValidatorClock(mocks.Clock{T: now}),
+		bigiot.WithValidatorTracer(tracer),
+	)
+	validator.AddProvider("Parking", bigiot.StaticCredentials(testSecret))
+	validator.AddProvider("Weather", bigiot.StaticCredentials(rotatedSecret))
+
+	var subscriber bigiot.Subscriber
+
+	handler := validator.RequireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subscriber, _ = bigiot.SubscriberFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, rotatedSecret, "Weather-Forecast", "Consumer-Query", now))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, bigiot.Subscriber{
+		ID:         "Consumer-Query",
+		OfferingID: "Weather-Forecast",
+		ProviderID: "Weather",
+	}, subscriber)
+
+	assert.Len(t, tracer.spans, 1)
+	assert.Equal(t, "Weather", tracer.spans[0].attributes[bigiot.AttributeProviderID])
+
+	req = httptest.NewRequest(http.MethodGet, "/weather", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, testSecret, "Weather-Forecast", "Consumer-Query", now))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vocabulary
+
+import (
+	"strings"
+)
+
+// Default returns a new Vocabulary containing the bundled set of common BIG
+// IoT categories and schema.org properties, along with the prefixes schema,
+// xsd, rdf, rdfs, bigiot, mobility and environment. A new Vocabulary is
+// returned on every call, so callers are free to add terms to it.
+func Default() *Vocabulary {
+	v, err := Parse(strings.NewReader(bundled), JSONLD)
+	if err != nil {
+		// the bundled vocabulary is fixed at compile time and covered by our
+		// tests, so this can only be a programming error
+		panic(err)
+	}
+
+	return v
+}
+
+// bundled is the JSON-LD source of the default vocabulary.
+const bundled = `{
+  "@context": {
+    "schema": "http://schema.org/",
+    "xsd": "http://www.w3.org/2001/XMLSchema#",
+    "rdf": "http://www.w3.org/1999/02/22-rdf-syntax-ns#",
+    "rdfs": "http://www.w3.org/2000/01/rdf-schema#",
+    "bigiot": "http://schema.big-iot.org/core/",
+    "mobility": "http://schema.big-iot.org/mobility/",
+    "environment": "http://schema.big-iot.org/environment/"
+  },
+  "@graph": [
+    { "@id": "urn:big-iot:ParkingSpaces", "@type": "bigiot:Category", "rdfs:label": "Parking spaces" },
+    { "@id": "urn:big-iot:ParkingSpace", "@type": "bigiot:Category", "rdfs:label": "Parking space" },
+    { "@id": "urn:big-iot:TrafficData", "@type": "bigiot:Category", "rdfs:label": "Traffic data" },
+    { "@id": "urn:big-iot:ChargingStations", "@type": "bigiot:Category", "rdfs:label": "Charging stations" },
+    { "@id": "urn:big-iot:BikeSharing", "@type": "bigiot:Category", "rdfs:label": "Bike sharing" },
+    { "@id": "urn:big-iot:PublicTransport", "@type": "bigiot:Category", "rdfs:label": "Public transport" },
+    { "@id": "urn:big-iot:WeatherIndicators", "@type": "bigiot:Category", "rdfs:label": "Weather indicators" },
+    { "@id": "urn:big-iot:AirQuality", "@type": "bigiot:Category", "rdfs:label": "Air quality" },
+    { "@id": "urn:big-iot:NoiseLevels", "@type": "bigiot:Category", "rdfs:label": "Noise levels" },
+    { "@id": "urn:big-iot:Locations", "@type": "bigiot:Category", "rdfs:label": "Locations" },
+
+    { "@id": "schema:latitude", "@type": "rdf:Property", "rdfs:label": "latitude" },
+    { "@id": "schema:longitude", "@type": "rdf:Property", "rdfs:label": "longitude" },
+    { "@id": "schema:elevation", "@type": "rdf:Property", "rdfs:label": "elevation" },
+    { "@id": "schema:geoCoordinates", "@type": "rdf:Property", "rdfs:label": "geo coordinates" },
+    { "@id": "schema:geoRadius", "@type": "rdf:Property", "rdfs:label": "geo radius" },
+    { "@id": "schema:address", "@type": "rdf:Property", "rdfs:label": "address" },
+    { "@id": "schema:name", "@type": "rdf:Property", "rdfs:label": "name" },
+    { "@id": "schema:description", "@type": "rdf:Property", "rdfs:label": "description" },
+    { "@id": "schema:identifier", "@type": "rdf:Property", "rdfs:label": "identifier" },
+    { "@id": "schema:url", "@type": "rdf:Property", "rdfs:label": "url" },
+    { "@id": "schema:dateCreated", "@type": "rdf:Property", "rdfs:label": "date created" },
+    { "@id": "schema:dateModified", "@type": "rdf:Property", "rdfs:label": "date modified" },
+    { "@id": "schema:startDate", "@type": "rdf:Property", "rdfs:label": "start date" },
+    { "@id": "schema:endDate", "@type": "rdf:Property", "rdfs:label": "end date" },
+    { "@id": "schema:speed", "@type": "rdf:Property", "rdfs:label": "speed" },
+    { "@id": "schema:value", "@type": "rdf:Property", "rdfs:label": "value" },
+    { "@id": "schema:minValue", "@type": "rdf:Property", "rdfs:label": "minimum value" },
+    { "@id": "schema:maxValue", "@type": "rdf:Property", "rdfs:label": "maximum value" },
+    { "@id": "schema:unitCode", "@type": "rdf:Property", "rdfs:label": "unit code" },
+    { "@id": "schema:unitText", "@type": "rdf:Property", "rdfs:label": "unit text" },
+    { "@id": "schema:price", "@type": "rdf:Property", "rdfs:label": "price" },
+    { "@id": "schema:priceCurrency", "@type": "rdf:Property", "rdfs:label": "price currency" },
+
+    { "@id": "mobility:parkingSpaceStatus", "@type": "rdf:Property", "rdfs:label": "parking space status" },
+    { "@id": "mobility:freeParkingSpaces", "@type": "rdf:Property", "rdfs:label": "free parking spaces" },
+    { "@id": "mobility:totalParkingSpaces", "@type": "rdf:Property", "rdfs:label": "total parking spaces" },
+    { "@id": "mobility:trafficFlow", "@type": "rdf:Property", "rdfs:label": "traffic flow" },
+    { "@id": "mobility:averageSpeed", "@type": "rdf:Property", "rdfs:label": "average speed" },
+    { "@id": "mobility:availableBikes", "@type": "rdf:Property", "rdfs:label": "available bikes" },
+    { "@id": "mobility:availableChargingPoints", "@type": "rdf:Property", "rdfs:label": "available charging points" },
+
+    { "@id": "environment:temperature", "@type": "rdf:Property", "rdfs:label": "temperature" },
+    { "@id": "environment:humidity", "@type": "rdf:Property", "rdfs:label": "humidity" },
+    { "@id": "environment:pressure", "@type": "rdf:Property", "rdfs:label": "pressure" },
+    { "@id": "environment:windSpeed", "@type": "rdf:Property", "rdfs:label": "wind speed" },
+    { "@id": "environment:precipitation", "@type": "rdf:Property", "rdfs:label": "precipitation" },
+    { "@id": "environment:pm10", "@type": "rdf:Property", "rdfs:label": "PM10 concentration" },
+    { "@id": "environment:pm25", "@type": "rdf:Property", "rdfs:label": "PM2.5 concentration" },
+    { "@id": "environment:no2", "@type": "rdf:Property", "rdfs:label": "NO2 concentration" },
+    { "@id": "environment:noiseLevel", "@type": "rdf:Property", "rdfs:label": "noise level" }
+  ]
+}`
@@ -0,0 +1,124 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vocabulary
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/thingful/bigiot/rdf"
+)
+
+// Format identifies the file format of a vocabulary. Values match those of the
+// corresponding rdf.Format.
+type Format string
+
+const (
+	// Turtle is the RDF Turtle format, typically using a .ttl extension.
+	Turtle Format = "turtle"
+
+	// JSONLD is the JSON-LD format, typically using a .jsonld extension.
+	JSONLD Format = "jsonld"
+)
+
+const (
+	rdfType      = rdf.RDFNamespace + "type"
+	rdfProperty  = rdf.RDFNamespace + "Property"
+	rdfsLabel    = rdf.RDFSNamespace + "label"
+	categoryType = rdf.BigIoTNamespace + "Category"
+)
+
+// Load reads a vocabulary from the file at the given path. The format is
+// determined from the file extension: .ttl for Turtle, and .jsonld or .json
+// for JSON-LD.
+func Load(path string) (*Vocabulary, error) {
+	var format Format
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ttl":
+		format = Turtle
+	case ".jsonld", ".json":
+		format = JSONLD
+	default:
+		return nil, errors.Errorf("unable to determine vocabulary format of %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening vocabulary file")
+	}
+	defer f.Close()
+
+	return Parse(f, format)
+}
+
+// Parse reads a vocabulary in the given format. Subjects typed as
+// bigiot:Category become Category terms, subjects typed as rdf:Property become
+// Property terms, and any other typed subjects become Class terms. The
+// rdfs:label of each subject is used as the label of its term. Blank nodes are
+// ignored.
+func Parse(r io.Reader, format Format) (*Vocabulary, error) {
+	g, err := rdf.Parse(r, rdf.Format(format))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing vocabulary")
+	}
+
+	v := New()
+	for prefix, namespace := range g.Prefixes {
+		v.AddPrefix(prefix, namespace)
+	}
+
+	terms := make(map[string]*Term)
+
+	for _, t := range g.Triples {
+		if t.Subject.Kind != rdf.IRI {
+			continue
+		}
+
+		term, ok := terms[t.Subject.Value]
+		if !ok {
+			term = &Term{IRI: t.Subject.Value}
+			terms[t.Subject.Value] = term
+		}
+
+		switch t.Predicate.Value {
+		case rdfType:
+			switch t.Object.Value {
+			case categoryType:
+				term.Kind = Category
+			case rdfProperty:
+				term.Kind = Property
+			default:
+				if term.Kind == "" {
+					term.Kind = Class
+				}
+			}
+		case rdfsLabel:
+			term.Label = t.Object.Value
+		}
+	}
+
+	for _, term := range terms {
+		// subjects which were never typed are not terms we know how to use
+		if term.Kind != "" {
+			v.Add(*term)
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vocabulary_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot/vocabulary"
+)
+
+const turtleVocabulary = `
+@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix bigiot: <http://schema.big-iot.org/core/> .
+PREFIX ex: <http://example.com/terms/>
+
+# a category
+<urn:example:Sensors> a bigiot:Category ;
+	rdfs:label "Sensors"@en .
+
+ex:reading a rdf:Property ;
+	rdfs:label "A \"reading\"" ;
+	rdfs:comment "ignored" .
+
+ex:Thing a rdfs:Class, ex:Other .
+
+ex:untyped rdfs:label "not a term" .
+`
+
+const jsonLDVocabulary = `{
+  "@context": {
+    "rdf": "http://www.w3.org/1999/02/22-rdf-syntax-ns#",
+    "rdfs": "http://www.w3.org/2000/01/rdf-schema#",
+    "bigiot": "http://schema.big-iot.org/core/",
+    "ex": "http://example.com/terms/"
+  },
+  "@graph": [
+    { "@id": "urn:example:Sensors", "@type": "bigiot:Category", "rdfs:label": { "@value": "Sensors", "@language": "en" } },
+    { "@id": "ex:reading", "@type": ["rdf:Property"], "rdfs:label": "A \"reading\"" },
+    { "@id": "ex:Thing", "@type": ["rdfs:Class", "ex:Other"] },
+    { "@id": "ex:untyped", "rdfs:label": "not a term" }
+  ]
+}`
+
+var expectedTerms = []vocabulary.Term{
+	{IRI: "http://example.com/terms/Thing", Kind: vocabulary.Class},
+	{IRI: "http://example.com/terms/reading", Kind: vocabulary.Property, Label: `A "reading"`},
+	{IRI: "urn:example:Sensors", Kind: vocabulary.Category, Label: "Sensors"},
+}
+
+func TestParse(t *testing.T) {
+	testcases := []struct {
+		label  string
+		input  string
+		format vocabulary.Format
+	}{
+		{"turtle", turtleVocabulary, vocabulary.Turtle},
+		{"json-ld", jsonLDVocabulary, vocabulary.JSONLD},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			vocab, err := vocabulary.Parse(strings.NewReader(testcase.input), testcase.format)
+			assert.Nil(t, err)
+			assert.Equal(t, expectedTerms, vocab.Terms())
+			assert.Equal(t, "ex:reading", vocab.Compact("http://example.com/terms/reading"))
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	testcases := []struct {
+		label  string
+		input  string
+		format vocabulary.Format
+	}{
+		{"undefined prefix", `ex:reading a ex:Property .`, vocabulary.Turtle},
+		{"missing terminator", `<urn:a> <urn:b> <urn:c>`, vocabulary.Turtle},
+		{"unterminated iri", `<urn:a <urn:b> <urn:c> .`, vocabulary.Turtle},
+		{"unterminated literal", `<urn:a> <urn:b> "c .`, vocabulary.Turtle},
+		{"malformed prefix", `@prefix ex <http://example.com/> .`, vocabulary.Turtle},
+		{"invalid json", `{"@graph": [`, vocabulary.JSONLD},
+		{"invalid id", `{"@graph": [{"@id": 5, "@type": "rdf:Property"}]}`, vocabulary.JSONLD},
+		{"unknown format", `{}`, vocabulary.Format("rdfxml")},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.label, func(t *testing.T) {
+			_, err := vocabulary.Parse(strings.NewReader(testcase.input), testcase.format)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vocabulary")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"terms.ttl":    turtleVocabulary,
+		"terms.jsonld": jsonLDVocabulary,
+	}
+
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0600))
+
+		vocab, err := vocabulary.Load(path)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedTerms, vocab.Terms())
+	}
+
+	_, err = vocabulary.Load(filepath.Join(dir, "terms.xml"))
+	assert.NotNil(t, err)
+
+	_, err = vocabulary.Load(filepath.Join(dir, "missing.ttl"))
+	assert.NotNil(t, err)
+}
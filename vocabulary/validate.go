@@ -0,0 +1,141 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vocabulary
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thingful/bigiot"
+)
+
+// maxSuggestions is the number of suggestions included in a ValidationError.
+const maxSuggestions = 3
+
+// ValidationError describes a single unknown term used by an offering
+// description.
+type ValidationError struct {
+	// Field describes where in the description the term was used, e.g.
+	// "category" or "input latitude".
+	Field string
+
+	// Term is the unknown term.
+	Term string
+
+	// Suggestions are known terms close to the unknown term, in compact form.
+	Suggestions []string
+}
+
+// Error is our implementation of the error interface.
+func (e ValidationError) Error() string {
+	kind := "RDF URI"
+	if e.Field == "category" {
+		kind = "category"
+	}
+
+	msg := fmt.Sprintf("unknown %s %q for %s", kind, e.Term, e.Field)
+	if len(e.Suggestions) > 0 {
+		msg += ", did you mean " + strings.Join(e.Suggestions, " or ") + "?"
+	}
+
+	return msg
+}
+
+// ValidationErrors is the error returned by Validate, listing every unknown
+// term used by an offering description.
+type ValidationErrors []ValidationError
+
+// Error is our implementation of the error interface.
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// ValidateCategory returns a ValidationError if the category is not a known
+// Category term. Proposed categories (those starting with urn:proposed:) are
+// always valid.
+func (v *Vocabulary) ValidateCategory(category string) error {
+	return v.validate("category", category, Category)
+}
+
+// ValidateRdfURI returns a ValidationError if the RDF URI is not a known
+// Property term. The field describes where the RDF URI is used, and is
+// reported as the Field of the error, e.g. "input latitude" as used by
+// Validate. Proposed terms (those starting with urn:proposed:) are always
+// valid.
+func (v *Vocabulary) ValidateRdfURI(field, rdfURI string) error {
+	return v.validate(field, rdfURI, Property)
+}
+
+// Validate checks the category of the description, and the RdfURI of each of
+// its inputs and outputs, against the vocabulary. If any are unknown a
+// ValidationErrors value is returned listing them along with suggested
+// corrections. Empty values are not validated.
+func (v *Vocabulary) Validate(description *bigiot.OfferingDescription) error {
+	var errs ValidationErrors
+
+	check := func(field, name string, kind Kind) {
+		if name == "" {
+			return
+		}
+
+		if err := v.validate(field, name, kind); err != nil {
+			errs = append(errs, err.(ValidationError))
+		}
+	}
+
+	check("category", description.Category, Category)
+
+	for _, input := range description.Inputs {
+		check("input "+input.Name, input.RdfURI, Property)
+	}
+
+	for _, output := range description.Outputs {
+		check("output "+output.Name, output.RdfURI, Property)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validate returns a ValidationError if the name is not a known term of the
+// given kind.
+func (v *Vocabulary) validate(field, name string, kind Kind) error {
+	if strings.HasPrefix(name, ProposedPrefix) {
+		return nil
+	}
+
+	if term, ok := v.Lookup(name); ok && term.Kind == kind {
+		return nil
+	}
+
+	err := ValidationError{
+		Field: field,
+		Term:  name,
+	}
+
+	for _, term := range v.Suggest(name, kind, maxSuggestions) {
+		err.Suggestions = append(err.Suggestions, v.Compact(term.IRI))
+	}
+
+	return err
+}
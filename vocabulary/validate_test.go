@@ -0,0 +1,83 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vocabulary_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/vocabulary"
+)
+
+func TestValidate(t *testing.T) {
+	vocab := vocabulary.Default()
+
+	valid := &bigiot.OfferingDescription{
+		Category: "urn:big-iot:ParkingSpaces",
+		Inputs: []bigiot.DataField{
+			{Name: "longitude", RdfURI: "schema:longitude"},
+			{Name: "latitude", RdfURI: "http://schema.org/latitude"},
+			{Name: "radius"},
+		},
+		Outputs: []bigiot.DataField{
+			{Name: "status", RdfURI: "mobility:parkingSpaceStatus"},
+			{Name: "value", RdfURI: "urn:proposed:RandomValue"},
+		},
+	}
+
+	assert.Nil(t, vocab.Validate(valid))
+
+	invalid := &bigiot.OfferingDescription{
+		Category: "urn:big-iot:ParkingSpaes",
+		Inputs: []bigiot.DataField{
+			{Name: "lat", RdfURI: "schema:lattitude"},
+		},
+		Outputs: []bigiot.DataField{
+			{Name: "parking", RdfURI: "urn:big-iot:ParkingSpaces"},
+			{Name: "value", RdfURI: "schema:random"},
+		},
+	}
+
+	err := vocab.Validate(invalid)
+	assert.NotNil(t, err)
+
+	errs, ok := err.(vocabulary.ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, vocabulary.ValidationErrors{
+		{Field: "category", Term: "urn:big-iot:ParkingSpaes", Suggestions: []string{"urn:big-iot:ParkingSpaces", "urn:big-iot:ParkingSpace"}},
+		{Field: "input lat", Term: "schema:lattitude", Suggestions: []string{"schema:latitude"}},
+		{Field: "output parking", Term: "urn:big-iot:ParkingSpaces"},
+		{Field: "output value", Term: "schema:random"},
+	}, errs)
+
+	assert.Equal(t, `unknown category "urn:big-iot:ParkingSpaes" for category, did you mean urn:big-iot:ParkingSpaces or urn:big-iot:ParkingSpace?; unknown RDF URI "schema:lattitude" for input lat, did you mean schema:latitude?; unknown RDF URI "urn:big-iot:ParkingSpaces" for output parking; unknown RDF URI "schema:random" for output value`, err.Error())
+}
+
+func TestValidateTerm(t *testing.T) {
+	vocab := vocabulary.Default()
+
+	assert.Nil(t, vocab.ValidateCategory("urn:big-iot:TrafficData"))
+	assert.Nil(t, vocab.ValidateCategory("urn:proposed:RandomValues"))
+	assert.NotNil(t, vocab.ValidateCategory("schema:latitude"))
+
+	assert.Nil(t, vocab.ValidateRdfURI("output temperature", "environment:temperature"))
+	assert.Nil(t, vocab.ValidateRdfURI("output humidity", "http://schema.big-iot.org/environment/humidity"))
+
+	err := vocab.ValidateRdfURI("output temperature", "environment:temprature")
+	assert.NotNil(t, err)
+	assert.Equal(t, "output temperature", err.(vocabulary.ValidationError).Field)
+	assert.Equal(t, `unknown RDF URI "environment:temprature" for output temperature, did you mean environment:temperature?`, err.Error())
+}
@@ -0,0 +1,272 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vocabulary provides a registry of the semantic terms used to describe
+// BIG IoT offerings: the categories used for OfferingDescription.Category, and
+// the properties used for the RdfURI of inputs and outputs. It can expand and
+// compact prefixed names (e.g. schema:latitude <-> http://schema.org/latitude),
+// validate the terms used by an OfferingDescription, and suggest close matches
+// for unknown terms so that typos are caught before registering an offering
+// rather than when discovery finds nothing.
+//
+// A set of common BIG IoT and schema.org terms is bundled with the package and
+// returned by Default. Additional terms can be loaded from Turtle or JSON-LD
+// files via Load and merged in.
+//
+// Example:
+//		vocab := vocabulary.Default()
+//
+//		err := vocab.Validate(description)
+//		if err != nil {
+//			log.Fatal(err) // e.g. unknown RDF URI "schema:lattitude" for input lat, did you mean schema:latitude?
+//		}
+package vocabulary
+
+import (
+	"sort"
+	"strings"
+)
+
+// Kind identifies the kind of a vocabulary term.
+type Kind string
+
+const (
+	// Category is the kind of terms used as the category of an offering.
+	Category Kind = "category"
+
+	// Property is the kind of terms used as the RdfURI of an offering's inputs
+	// and outputs.
+	Property Kind = "property"
+
+	// Class is the kind of any other terms defined in a vocabulary.
+	Class Kind = "class"
+)
+
+// ProposedPrefix is the prefix of terms which have been proposed, but are not
+// yet part of the BIG IoT vocabulary. The marketplace accepts these, so they
+// are always treated as valid.
+const ProposedPrefix = "urn:proposed:"
+
+// Term is a single term within a vocabulary.
+type Term struct {
+	// IRI is the full IRI of the term, e.g. http://schema.org/latitude.
+	IRI string
+
+	// Kind is the kind of the term.
+	Kind Kind
+
+	// Label is an optional human readable label for the term.
+	Label string
+}
+
+// Vocabulary is a set of terms along with the prefixes used to abbreviate their
+// IRIs. A Vocabulary is not safe for concurrent modification, but may be read
+// concurrently once loaded.
+type Vocabulary struct {
+	prefixes map[string]string
+	terms    map[string]Term
+}
+
+// New returns an empty Vocabulary with no prefixes or terms.
+func New() *Vocabulary {
+	return &Vocabulary{
+		prefixes: make(map[string]string),
+		terms:    make(map[string]Term),
+	}
+}
+
+// AddPrefix adds a prefix, so that names of the form prefix:name are expanded
+// to namespace + name.
+func (v *Vocabulary) AddPrefix(prefix, namespace string) {
+	v.prefixes[prefix] = namespace
+}
+
+// Prefixes returns a copy of the vocabulary's prefix mappings.
+func (v *Vocabulary) Prefixes() map[string]string {
+	prefixes := make(map[string]string, len(v.prefixes))
+	for prefix, namespace := range v.prefixes {
+		prefixes[prefix] = namespace
+	}
+
+	return prefixes
+}
+
+// Add adds a term to the vocabulary, replacing any existing term with the same
+// IRI. The term's IRI may be given in prefixed form.
+func (v *Vocabulary) Add(term Term) {
+	term.IRI = v.Expand(term.IRI)
+	v.terms[term.IRI] = term
+}
+
+// Merge adds all the prefixes and terms of other to the vocabulary.
+func (v *Vocabulary) Merge(other *Vocabulary) {
+	for prefix, namespace := range other.prefixes {
+		v.prefixes[prefix] = namespace
+	}
+
+	for iri, term := range other.terms {
+		v.terms[iri] = term
+	}
+}
+
+// Terms returns every term in the vocabulary ordered by IRI.
+func (v *Vocabulary) Terms() []Term {
+	terms := make([]Term, 0, len(v.terms))
+	for _, term := range v.terms {
+		terms = append(terms, term)
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].IRI < terms[j].IRI
+	})
+
+	return terms
+}
+
+// Expand returns the full IRI for a prefixed name such as schema:latitude. If
+// the name does not start with a known prefix it is returned unchanged.
+func (v *Vocabulary) Expand(name string) string {
+	idx := strings.Index(name, ":")
+	if idx == -1 {
+		return name
+	}
+
+	namespace, ok := v.prefixes[name[:idx]]
+	if !ok {
+		return name
+	}
+
+	return namespace + name[idx+1:]
+}
+
+// Compact returns the shortest prefixed name for the given IRI, e.g.
+// schema:latitude for http://schema.org/latitude. If no prefix matches the IRI
+// it is returned unchanged.
+func (v *Vocabulary) Compact(iri string) string {
+	var bestPrefix, bestNamespace string
+
+	for prefix, namespace := range v.prefixes {
+		if !strings.HasPrefix(iri, namespace) || len(iri) == len(namespace) {
+			continue
+		}
+
+		if len(namespace) > len(bestNamespace) || (len(namespace) == len(bestNamespace) && prefix < bestPrefix) {
+			bestPrefix, bestNamespace = prefix, namespace
+		}
+	}
+
+	if bestNamespace == "" {
+		return iri
+	}
+
+	return bestPrefix + ":" + iri[len(bestNamespace):]
+}
+
+// Lookup returns the term identified by the given name, which may be a full IRI
+// or a prefixed name.
+func (v *Vocabulary) Lookup(name string) (Term, bool) {
+	term, ok := v.terms[v.Expand(name)]
+	return term, ok
+}
+
+// Suggest returns up to limit terms of the given kind whose names are close to
+// the given name, closest first. A limit of zero or less returns every close
+// term. This is intended for suggesting corrections for unknown terms, so only
+// terms within a small edit distance are returned.
+func (v *Vocabulary) Suggest(name string, kind Kind, limit int) []Term {
+	target := strings.ToLower(v.Compact(v.Expand(name)))
+
+	// allow roughly one typo for every six characters, and at least two
+	threshold := len(target) / 6
+	if threshold < 2 {
+		threshold = 2
+	}
+
+	type candidate struct {
+		term     Term
+		distance int
+	}
+
+	var candidates []candidate
+
+	for _, term := range v.terms {
+		if term.Kind != kind {
+			continue
+		}
+
+		distance := levenshtein(target, strings.ToLower(v.Compact(term.IRI)))
+		if distance <= threshold {
+			candidates = append(candidates, candidate{term: term, distance: distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].term.IRI < candidates[j].term.IRI
+	})
+
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	suggestions := make([]Term, len(candidates))
+	for i, c := range candidates {
+		suggestions[i] = c.term
+	}
+
+	return suggestions
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// minInt returns the smallest of the given ints.
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 Thingful Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vocabulary_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/bigiot"
+	"github.com/thingful/bigiot/vocabulary"
+)
+
+func TestDefaultPrefixes(t *testing.T) {
+	prefixes := vocabulary.Default().Prefixes()
+
+	// the bundled vocabulary defines the same prefixes as the bigiot package
+	for prefix, namespace := range bigiot.DefaultPrefixes() {
+		assert.Equal(t, namespace, prefixes[prefix], prefix)
+	}
+}
+
+func TestExpandAndCompact(t *testing.T) {
+	vocab := vocabulary.Default()
+
+	testcases := []struct {
+		compact  string
+		expanded string
+	}{
+		{"schema:latitude", "http://schema.org/latitude"},
+		{"xsd:double", "http://www.w3.org/2001/XMLSchema#double"},
+		{"mobility:trafficFlow", "http://schema.big-iot.org/mobility/trafficFlow"},
+		{"urn:big-iot:ParkingSpaces", "urn:big-iot:ParkingSpaces"},
+		{"unknown:term", "unknown:term"},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.compact, func(t *testing.T) {
+			assert.Equal(t, testcase.expanded, vocab.Expand(testcase.compact))
+			assert.Equal(t, testcase.compact, vocab.Compact(testcase.expanded))
+		})
+	}
+
+	// the longest matching namespace wins
+	vocab.AddPrefix("core", "http://schema.big-iot.org/")
+	assert.Equal(t, "mobility:trafficFlow", vocab.Compact("http://schema.big-iot.org/mobility/trafficFlow"))
+	assert.Equal(t, "core:other/term", vocab.Compact("http://schema.big-iot.org/other/term"))
+}
+
+func TestLookup(t *testing.T) {
+	vocab := vocabulary.Default()
+
+	term, ok := vocab.Lookup("schema:latitude")
+	assert.True(t, ok)
+	assert.Equal(t, vocabulary.Term{IRI: "http://schema.org/latitude", Kind: vocabulary.Property, Label: "latitude"}, term)
+
+	term, ok = vocab.Lookup("http://schema.org/longitude")
+	assert.True(t, ok)
+	assert.Equal(t, vocabulary.Property, term.Kind)
+
+	term, ok = vocab.Lookup("urn:big-iot:ParkingSpaces")
+	assert.True(t, ok)
+	assert.Equal(t, vocabulary.Category, term.Kind)
+
+	_, ok = vocab.Lookup("schema:lattitude")
+	assert.False(t, ok)
+}
+
+func TestAddAndMerge(t *testing.T) {
+	vocab := vocabulary.New()
+	vocab.AddPrefix("ex", "http://example.com/")
+	vocab.Add(vocabulary.Term{IRI: "ex:reading", Kind: vocabulary.Property})
+
+	other := vocabulary.New()
+	other.AddPrefix("other", "http://example.org/")
+	other.Add(vocabulary.Term{IRI: "http://example.org/Sensors", Kind: vocabulary.Category})
+
+	vocab.Merge(other)
+
+	assert.Equal(t, map[string]string{"ex": "http://example.com/", "other": "http://example.org/"}, vocab.Prefixes())
+	assert.Equal(t, []vocabulary.Term{
+		{IRI: "http://example.com/reading", Kind: vocabulary.Property},
+		{IRI: "http://example.org/Sensors", Kind: vocabulary.Category},
+	}, vocab.Terms())
+}
+
+func TestSuggest(t *testing.T) {
+	vocab := vocabulary.Default()
+
+	suggestions := vocab.Suggest("schema:lattitude", vocabulary.Property, 3)
+	assert.Len(t, suggestions, 1)
+	assert.Equal(t, "http://schema.org/latitude", suggestions[0].IRI)
+
+	suggestions = vocab.Suggest("urn:big-iot:parkingspace", vocabulary.Category, 3)
+	assert.Len(t, suggestions, 2)
+	assert.Equal(t, "urn:big-iot:ParkingSpace", suggestions[0].IRI)
+	assert.Equal(t, "urn:big-iot:ParkingSpaces", suggestions[1].IRI)
+
+	// the number of suggestions is limited, unless the limit isn't positive
+	assert.Len(t, vocab.Suggest("urn:big-iot:parkingspace", vocabulary.Category, 1), 1)
+	assert.Len(t, vocab.Suggest("urn:big-iot:parkingspace", vocabulary.Category, 0), 2)
+	assert.Len(t, vocab.Suggest("urn:big-iot:parkingspace", vocabulary.Category, -1), 2)
+
+	// only terms of the requested kind are suggested
+	assert.Len(t, vocab.Suggest("schema:latitude", vocabulary.Category, 3), 0)
+
+	// nothing close
+	assert.Len(t, vocab.Suggest("schema:somethingElseEntirely", vocabulary.Property, 3), 0)
+}